@@ -9,14 +9,22 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
 	"gorm.io/gorm"
 
+	"github.com/MoSed3/otp-server/internal/audit"
 	"github.com/MoSed3/otp-server/internal/config"
 	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/encryption"
 	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/password"
+	"github.com/MoSed3/otp-server/internal/redis"
 	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+	"github.com/MoSed3/otp-server/internal/token"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 // Helper function to securely prompt for password
@@ -45,12 +53,13 @@ func promptForInput(prompt string, defaultValue string) (string, error) {
 	return input, nil
 }
 
-func handleCreate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
+func handleCreate(tx *gorm.DB, adminRepo repository.Admin, roleRepo repository.Role, args []string) {
+	available := availableRoles(tx, roleRepo)
+
 	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
 	createUsernameFlag := createCmd.String("username", "", "Username for the new admin")
 	createCmd.StringVar(createUsernameFlag, "u", "", "Username for the new admin (shorthand)")
-	createRoleFlag := createCmd.String("role", "", fmt.Sprintf("Role for the new admin (available roles: %s, %s, %s)",
-		models.RoleSuperAdmin.String(), models.RoleSudoAdmin.String(), models.RoleVisitorAdmin.String()))
+	createRoleFlag := createCmd.String("role", "", fmt.Sprintf("Role for the new admin (available roles: %s)", available))
 	createCmd.StringVar(createRoleFlag, "r", "", "Role for the new admin (shorthand)")
 
 	createCmd.Parse(args)
@@ -66,6 +75,11 @@ func handleCreate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 			log.Fatal("Username cannot be empty")
 		}
 	}
+	if taken, err := adminRepo.UsernameExists(tx, username); err != nil {
+		log.Fatalf("Error checking username: %v", err)
+	} else if taken {
+		log.Fatalf("Username %s is already taken, deleted admins keep their username", username)
+	}
 
 	password, err := promptForPassword("Enter Password: ")
 	if err != nil {
@@ -78,32 +92,36 @@ func handleCreate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 	roleStr := *createRoleFlag
 	if roleStr == "" {
 		var err error
-		roleStr, err = promptForInput(fmt.Sprintf("Enter Role (available roles: %s, %s, %s)",
-			models.RoleSuperAdmin.String(), models.RoleSudoAdmin.String(), models.RoleVisitorAdmin.String()), models.RoleVisitorAdmin.String())
+		roleStr, err = promptForInput(fmt.Sprintf("Enter Role (available roles: %s)", available), models.RoleVisitorAdmin.String())
 		if err != nil {
 			log.Fatalf("Error getting role: %v", err)
 		}
 	}
 
-	role, err := models.ParseAdminRole(roleStr)
-	if err != nil {
-		log.Fatalf("Invalid role: %s", roleStr)
-	}
+	role := resolveRole(tx, roleRepo, roleStr)
 
-	admin, err := adminRepo.Create(tx, username, password, role)
+	admin, err := adminRepo.Create(tx, username, password, models.AdminRole(role.ID))
 	if err != nil {
 		log.Fatalf("Error creating admin: %v", err)
 	}
-	fmt.Printf("Admin created successfully: ID=%d, Username=%s, Role: %s\n", admin.ID, admin.Username, admin.Role)
+	event := audit.On(audit.BySystem("cli", models.AuditAdminCreated), audit.TargetAdmin, admin.ID)
+	event.Changes = map[string]models.AuditChange{
+		"username": {After: admin.Username},
+		"role":     {After: admin.Role},
+	}
+	appendAuditEvent(tx, event)
+	fmt.Printf("Admin created successfully: ID=%d, Username=%s, Role: %s\n", admin.ID, admin.Username, role.Name)
 }
 
-func handleUpdate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
+func handleUpdate(tx *gorm.DB, adminRepo repository.Admin, roleRepo repository.Role, args []string) {
+	available := availableRoles(tx, roleRepo)
+
 	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
 	updateID := updateCmd.Uint("id", 0, "ID of the admin to update")
 	updateCmd.UintVar(updateID, "i", 0, "ID of the admin to update (shorthand)")
 	updateUsernameFlag := updateCmd.String("username", "", "New username for the admin (optional)")
 	updateCmd.StringVar(updateUsernameFlag, "u", "", "New username for the admin (shorthand, optional)")
-	updateRoleFlag := updateCmd.String("role", "", fmt.Sprintf("New role for the admin (optional, available roles: %s, %s, %s)", models.RoleSuperAdmin.String(), models.RoleSudoAdmin.String(), models.RoleVisitorAdmin.String()))
+	updateRoleFlag := updateCmd.String("role", "", fmt.Sprintf("New role for the admin (optional, available roles: %s)", available))
 	updateCmd.StringVar(updateRoleFlag, "r", "", "New role for the admin (shorthand, optional)")
 
 	updateCmd.Parse(args)
@@ -128,6 +146,11 @@ func handleUpdate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 		}
 	}
 	if username != admin.Username {
+		if taken, err := adminRepo.UsernameExists(tx, username); err != nil {
+			log.Fatalf("Error checking username: %v", err)
+		} else if taken {
+			log.Fatalf("Username %s is already taken, deleted admins keep their username", username)
+		}
 		updates.Username = &username
 	}
 
@@ -141,19 +164,16 @@ func handleUpdate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 	}
 
 	// Handle role update
+	currentRole := roleNames(tx, roleRepo)[uint(admin.Role)]
 	roleStr := *updateRoleFlag
 	if roleStr == "" {
-		roleStr, err = promptForInput(fmt.Sprintf("Enter New Role (available roles: %s, %s, %s)",
-			models.RoleSuperAdmin.String(), models.RoleSudoAdmin.String(), models.RoleVisitorAdmin.String()), admin.Role.String())
+		roleStr, err = promptForInput(fmt.Sprintf("Enter New Role (available roles: %s)", available), currentRole)
 		if err != nil {
 			log.Fatalf("Error getting new role: %v", err)
 		}
 	}
-	if roleStr != admin.Role.String() {
-		role, err := models.ParseAdminRole(roleStr)
-		if err != nil {
-			log.Fatalf("Invalid role: %s", roleStr)
-		}
+	if roleStr != currentRole {
+		role := models.AdminRole(resolveRole(tx, roleRepo, roleStr).ID)
 		updates.Role = &role
 	}
 
@@ -161,6 +181,18 @@ func handleUpdate(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 	if err != nil {
 		log.Fatalf("Error updating admin: %v", err)
 	}
+	event := audit.On(audit.BySystem("cli", models.AuditAdminUpdated), audit.TargetAdmin, admin.ID)
+	event.Changes = make(map[string]models.AuditChange)
+	if updates.Username != nil {
+		event.Changes["username"] = models.AuditChange{Before: admin.Username, After: *updates.Username}
+	}
+	if updates.Role != nil && *updates.Role != admin.Role {
+		event.Changes["role"] = models.AuditChange{Before: admin.Role, After: *updates.Role}
+	}
+	if updates.NewPassword != nil {
+		event.Changes["password"] = models.AuditChange{Before: audit.Redacted, After: audit.Redacted}
+	}
+	appendAuditEvent(tx, event)
 	fmt.Printf("Admin ID %d updated successfully\n", *updateID)
 }
 
@@ -174,14 +206,361 @@ func handleDelete(tx *gorm.DB, adminRepo repository.Admin, args []string) {
 		os.Exit(1)
 	}
 
-	err := adminRepo.Delete(tx, *deleteID)
-	if err != nil {
+	admin, err := adminRepo.GetByID(tx, *deleteID)
+	if err != nil || admin == nil {
+		log.Fatalf("Admin with ID %d not found: %v", *deleteID, err)
+	}
+	if err = adminRepo.Delete(tx, admin.ID); err != nil {
 		log.Fatalf("Error deleting admin: %v", err)
 	}
+	event := audit.On(audit.BySystem("cli", models.AuditAdminDeleted), audit.TargetAdmin, admin.ID)
+	event.Changes = map[string]models.AuditChange{
+		"username": {Before: admin.Username},
+		"role":     {Before: admin.Role},
+	}
+	appendAuditEvent(tx, event)
 	fmt.Printf("Admin ID %d deleted successfully\n", *deleteID)
 }
 
-func handleList(tx *gorm.DB, adminRepo repository.Admin) {
+func handleTotp(tx *gorm.DB, adminRepo repository.Admin, adminTotpRepo repository.AdminTotp, totpManager *totp.Manager, args []string) {
+	totpCmd := flag.NewFlagSet("totp", flag.ExitOnError)
+	totpID := totpCmd.Uint("id", 0, "ID of the admin to enroll")
+	totpCmd.UintVar(totpID, "i", 0, "ID of the admin to enroll (shorthand)")
+	totpDisable := totpCmd.Bool("disable", false, "Remove the admin's TOTP enrollment instead of enrolling")
+
+	totpCmd.Parse(args)
+	if *totpID == 0 {
+		totpCmd.PrintDefaults()
+		os.Exit(1)
+	}
+
+	admin, err := adminRepo.GetByID(tx, *totpID)
+	if err != nil || admin == nil {
+		log.Fatalf("Admin with ID %d not found: %v", *totpID, err)
+	}
+
+	if *totpDisable {
+		if err = adminTotpRepo.DeleteByAdminID(tx, admin.ID); err != nil {
+			log.Fatalf("Error disabling TOTP: %v", err)
+		}
+		appendAuditEvent(tx, audit.On(audit.BySystem("cli", models.AuditAdminTotpDisabled), audit.TargetAdmin, admin.ID))
+		fmt.Printf("TOTP disabled for admin %s\n", admin.Username)
+		return
+	}
+
+	existing, err := adminTotpRepo.GetByAdminID(tx, admin.ID)
+	if err != nil {
+		log.Fatalf("Error getting TOTP enrollment: %v", err)
+	}
+	if existing != nil && existing.ConfirmedAt.Valid {
+		log.Fatalf("Admin %s is already enrolled, use 'admin totp -id %d -disable' first", admin.Username, admin.ID)
+	}
+
+	enrollment, err := totpManager.Enroll(admin.Username)
+	if err != nil {
+		log.Fatalf("Error generating TOTP secret: %v", err)
+	}
+	secret, err := adminTotpRepo.Create(tx, admin.ID, enrollment.EncryptedSecret)
+	if err != nil {
+		log.Fatalf("Error saving TOTP secret: %v", err)
+	}
+
+	fmt.Println("Add this account to your authenticator app:")
+	fmt.Printf("  Secret: %s\n", enrollment.Secret)
+	fmt.Printf("  URI:    %s\n", enrollment.URI)
+
+	code, err := promptForInput("Enter the code shown by the app", "")
+	if err != nil {
+		log.Fatalf("Error getting code: %v", err)
+	}
+
+	step, err := totpManager.Verify(secret.EncryptedSecret, code, secret.LastUsedStep)
+	if err != nil {
+		log.Fatalf("Error verifying code: %v", err)
+	}
+	if err = adminTotpRepo.Confirm(tx, secret, step); err != nil {
+		log.Fatalf("Error confirming TOTP enrollment: %v", err)
+	}
+	appendAuditEvent(tx, audit.On(audit.BySystem("cli", models.AuditAdminTotpEnrolled), audit.TargetAdmin, admin.ID))
+	fmt.Printf("TOTP enabled for admin %s\n", admin.Username)
+}
+
+func handleKeys(tx *gorm.DB, signingKeyRepo repository.SigningKey, keyCipher *encryption.Cipher, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: admin keys <list|rotate|retire> [arguments]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		keys, err := signingKeyRepo.ListAll(tx)
+		if err != nil {
+			log.Fatalf("Error listing signing keys: %v", err)
+		}
+		if len(keys) == 0 {
+			fmt.Println("No signing keys found, tokens are signed with the HS256 secret.")
+			return
+		}
+		for _, key := range keys {
+			fmt.Printf("%s  %-5s  %-8s  created %s\n", key.Kid, key.Algorithm, key.Status.String(), key.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+	case "rotate":
+		rotateCmd := flag.NewFlagSet("rotate", flag.ExitOnError)
+		algFlag := rotateCmd.String("alg", token.AlgorithmES256, fmt.Sprintf("Algorithm of the new key (available: %s, %s, %s)",
+			token.AlgorithmRS256, token.AlgorithmES256, token.AlgorithmEdDSA))
+		rotateCmd.Parse(args[1:])
+
+		key, err := token.GenerateSigningKey(*algFlag, keyCipher)
+		if err != nil {
+			log.Fatalf("Error generating signing key: %v", err)
+		}
+		existing, err := signingKeyRepo.ListAll(tx)
+		if err != nil {
+			log.Fatalf("Error listing signing keys: %v", err)
+		}
+		if err = signingKeyRepo.DemoteActive(tx); err != nil {
+			log.Fatalf("Error demoting active signing key: %v", err)
+		}
+		if err = signingKeyRepo.Create(tx, key); err != nil {
+			log.Fatalf("Error saving signing key: %v", err)
+		}
+		event := audit.On(audit.BySystem("cli", models.AuditSigningKeyRotated), audit.TargetSigningKey, key.ID)
+		event.Changes = map[string]models.AuditChange{
+			"kid":       {After: key.Kid},
+			"algorithm": {After: key.Algorithm},
+		}
+		for _, previous := range existing {
+			if previous.Status == models.SigningKeyActive {
+				event.Changes["kid"] = models.AuditChange{Before: previous.Kid, After: key.Kid}
+			}
+		}
+		appendAuditEvent(tx, event)
+		fmt.Printf("Signing key %s (%s) is now active, the previous key is retiring\n", key.Kid, key.Algorithm)
+		fmt.Println("Retire it with 'admin keys retire -kid <kid>' once its tokens have expired.")
+		if len(existing) == 0 {
+			fmt.Println("HS256 tokens stop being accepted once the secret key grace period has passed.")
+		}
+	case "retire":
+		retireCmd := flag.NewFlagSet("retire", flag.ExitOnError)
+		kidFlag := retireCmd.String("kid", "", "Kid of the retiring key")
+		retireCmd.Parse(args[1:])
+		if *kidFlag == "" {
+			retireCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		key, err := signingKeyRepo.GetByKid(tx, *kidFlag)
+		if err != nil {
+			log.Fatalf("Signing key %s not found: %v", *kidFlag, err)
+		}
+		if key.Status != models.SigningKeyRetiring {
+			log.Fatalf("Signing key %s is %s, only retiring keys can be retired", key.Kid, key.Status.String())
+		}
+		if err = signingKeyRepo.Retire(tx, key); err != nil {
+			log.Fatalf("Error retiring signing key: %v", err)
+		}
+		event := audit.On(audit.BySystem("cli", models.AuditSigningKeyRetired), audit.TargetSigningKey, key.ID)
+		event.Changes = map[string]models.AuditChange{
+			"kid":    {Before: key.Kid},
+			"status": {Before: models.SigningKeyRetiring.String(), After: key.Status.String()},
+		}
+		appendAuditEvent(tx, event)
+		fmt.Printf("Signing key %s retired\n", key.Kid)
+	default:
+		fmt.Printf("Unknown keys command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleSecret(tx *gorm.DB, settingRepo repository.Setting, args []string) {
+	if len(args) < 1 || args[0] != "rotate" {
+		fmt.Println("Usage: admin secret rotate [-grace minutes]")
+		os.Exit(1)
+	}
+
+	current, err := settingRepo.Get(tx)
+	if err != nil {
+		log.Fatalf("Error getting settings: %v", err)
+	}
+
+	rotateCmd := flag.NewFlagSet("rotate", flag.ExitOnError)
+	graceFlag := rotateCmd.Uint("grace", current.SecretKeyGracePeriod, "Minutes the previous secret key keeps verifying tokens")
+	rotateCmd.Parse(args[1:])
+
+	settings, err := settingRepo.RotateSecretKey(tx, time.Duration(*graceFlag)*time.Minute)
+	if err != nil {
+		log.Fatalf("Error rotating secret key: %v", err)
+	}
+	event := audit.On(audit.BySystem("cli", models.AuditSecretKeyRotated), audit.TargetSettings, settings.ID)
+	event.Changes = map[string]models.AuditChange{
+		"secret_key_id": {Before: current.SecretKeyID, After: settings.SecretKeyID},
+		"grace_period":  {After: *graceFlag},
+	}
+	appendAuditEvent(tx, event)
+	fmt.Printf("Secret key rotated, new kid: %s\n", settings.SecretKeyID)
+	if *graceFlag > 0 {
+		fmt.Printf("The previous key stays valid for %d minutes\n", *graceFlag)
+	} else {
+		fmt.Println("The previous key was revoked immediately, every issued token is now invalid")
+	}
+}
+
+func handleLockout(tx *gorm.DB, adminRepo repository.Admin, redisConfig config.RedisConfig, args []string) {
+	if len(args) < 1 || (args[0] != "show" && args[0] != "unlock") {
+		fmt.Println("Usage: admin lockout <show|unlock> -username <username>")
+		os.Exit(1)
+	}
+
+	lockoutCmd := flag.NewFlagSet(args[0], flag.ExitOnError)
+	usernameFlag := lockoutCmd.String("username", "", "Username of the admin")
+	lockoutCmd.StringVar(usernameFlag, "u", "", "Username of the admin (shorthand)")
+	lockoutCmd.Parse(args[1:])
+	if *usernameFlag == "" {
+		lockoutCmd.PrintDefaults()
+		os.Exit(1)
+	}
+
+	redisCli := redis.New(redisConfig)
+	if err := redisCli.Start(); err != nil {
+		log.Fatalf("Failed to connect to redis: %v", err)
+	}
+	defer redisCli.Stop()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "show":
+		state, err := redisCli.GetAdminLoginState(ctx, *usernameFlag)
+		if err != nil {
+			log.Fatalf("Error reading lockout state: %v", err)
+		}
+		now := time.Now()
+		fmt.Printf("Failed logins: %d\n", state.Failures)
+		if state.Locked(now) {
+			fmt.Printf("Locked until %s\n", state.LockedUntil.Local().Format(time.RFC3339))
+		} else if retryAfter := state.RetryAfter(now); retryAfter > 0 {
+			fmt.Printf("Next attempt allowed in %s\n", retryAfter.Round(time.Second))
+		} else {
+			fmt.Println("Not locked")
+		}
+	case "unlock":
+		event := audit.BySystem("cli", models.AuditAdminUnlocked)
+		admin, err := adminRepo.GetByUsername(tx, *usernameFlag)
+		if err != nil {
+			log.Fatalf("Error looking up admin: %v", err)
+		}
+		if admin != nil {
+			audit.On(event, audit.TargetAdmin, admin.ID)
+		}
+		appendAuditEvent(tx, event)
+		if err = redisCli.ResetAdminLoginFailures(ctx, *usernameFlag); err != nil {
+			log.Fatalf("Error unlocking admin: %v", err)
+		}
+		fmt.Printf("Admin %s unlocked\n", *usernameFlag)
+	}
+}
+
+// handleIPList runs an iplist command. Changes to a list are audited in tx and made by the returned
+// function, which must run once tx has committed.
+func handleIPList(tx *gorm.DB, redisConfig config.RedisConfig, args []string) func() {
+	if len(args) < 1 || (args[0] != "show" && args[0] != "add" && args[0] != "remove") {
+		fmt.Println("Usage: admin iplist <show|add|remove> -list <allow|deny> [-network <address or CIDR>] [-reason <reason>] [-expires <seconds>]")
+		os.Exit(1)
+	}
+
+	ipListCmd := flag.NewFlagSet(args[0], flag.ExitOnError)
+	listFlag := ipListCmd.String("list", "", "List to manage (allow or deny)")
+	ipListCmd.StringVar(listFlag, "l", "", "List to manage (shorthand)")
+	networkFlag := ipListCmd.String("network", "", "Address or CIDR (add and remove)")
+	ipListCmd.StringVar(networkFlag, "n", "", "Address or CIDR (shorthand)")
+	reasonFlag := ipListCmd.String("reason", "", "Why the network is listed (add, optional)")
+	expiresFlag := ipListCmd.Uint("expires", 0, "Seconds until the entry expires (add, optional, 0 for never)")
+	ipListCmd.Parse(args[1:])
+
+	list := redis.IPList(*listFlag)
+	if !list.IsValid() || (args[0] != "show" && *networkFlag == "") {
+		ipListCmd.PrintDefaults()
+		os.Exit(1)
+	}
+
+	redisCli := redis.New(redisConfig)
+	if err := redisCli.Start(); err != nil {
+		log.Fatalf("Failed to connect to redis: %v", err)
+	}
+
+	ctx := context.Background()
+	if args[0] == "show" {
+		defer redisCli.Stop()
+		entries, err := redisCli.GetIPListEntries(ctx, list)
+		if err != nil {
+			log.Fatalf("Error reading %s list: %v", list, err)
+		}
+		if len(entries) == 0 {
+			fmt.Printf("The %s list is empty\n", list)
+			return nil
+		}
+		fmt.Printf("%-43s  %-20s  %-25s  %s\n", "Network", "Created By", "Expires", "Reason")
+		for _, entry := range entries {
+			expires := "never"
+			if entry.ExpiresAt != nil {
+				expires = entry.ExpiresAt.Local().Format(time.RFC3339)
+			}
+			fmt.Printf("%-43s  %-20s  %-25s  %s\n", entry.Network, entry.CreatedBy, expires, entry.Reason)
+		}
+		return nil
+	}
+
+	_, network, err := redis.ParseNetwork(*networkFlag)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	action := models.AuditIPListAdded
+	if args[0] == "remove" {
+		action = models.AuditIPListRemoved
+	}
+	event := audit.OnNetwork(audit.BySystem("cli", action), string(list), network)
+
+	switch args[0] {
+	case "add":
+		now := time.Now().UTC()
+		entry := redis.IPListEntry{Network: network, Reason: *reasonFlag, CreatedBy: "cli", CreatedAt: now}
+		if *expiresFlag > 0 {
+			expiresAt := now.Add(time.Duration(*expiresFlag) * time.Second)
+			entry.ExpiresAt = &expiresAt
+		}
+		event.Changes = map[string]models.AuditChange{
+			"reason":     {After: entry.Reason},
+			"expires_at": {After: entry.ExpiresAt},
+		}
+		appendAuditEvent(tx, event)
+		return func() {
+			defer redisCli.Stop()
+			if err := redisCli.AddIPListEntry(ctx, list, entry); err != nil {
+				log.Fatalf("Error adding %s to the %s list: %v", network, list, err)
+			}
+			fmt.Printf("Added %s to the %s list\n", network, list)
+		}
+	default:
+		listed, err := redisCli.HasIPListEntry(ctx, list, network)
+		if err != nil {
+			log.Fatalf("Error reading %s list: %v", list, err)
+		}
+		if !listed {
+			log.Fatalf("%s is not on the %s list", network, list)
+		}
+		appendAuditEvent(tx, event)
+		return func() {
+			defer redisCli.Stop()
+			if _, err := redisCli.RemoveIPListEntry(ctx, list, network); err != nil {
+				log.Fatalf("Error removing %s from the %s list: %v", network, list, err)
+			}
+			fmt.Printf("Removed %s from the %s list\n", network, list)
+		}
+	}
+}
+
+func handleList(tx *gorm.DB, adminRepo repository.Admin, roleRepo repository.Role) {
+	names := roleNames(tx, roleRepo)
 	admins, err := adminRepo.ListAll(tx)
 	if err != nil {
 		log.Fatalf("Error listing admins: %v", err)
@@ -204,7 +583,7 @@ func handleList(tx *gorm.DB, adminRepo repository.Admin) {
 		if usernameLen := len(admin.Username); usernameLen > maxUsernameLen {
 			maxUsernameLen = usernameLen
 		}
-		if roleLen := len(admin.Role.String()); roleLen > maxRoleLen {
+		if roleLen := len(names[uint(admin.Role)]); roleLen > maxRoleLen {
 			maxRoleLen = roleLen
 		}
 	}
@@ -218,7 +597,51 @@ func handleList(tx *gorm.DB, adminRepo repository.Admin) {
 
 	// Print admin data
 	for _, admin := range admins {
-		fmt.Printf("%-*d  %-*s  %-*s\n", maxIDLen, admin.ID, maxUsernameLen, admin.Username, maxRoleLen, admin.Role.String())
+		fmt.Printf("%-*d  %-*s  %-*s\n", maxIDLen, admin.ID, maxUsernameLen, admin.Username, maxRoleLen, names[uint(admin.Role)])
+	}
+}
+
+// resolveRole looks up a role by name and exits when it does not exist.
+func resolveRole(tx *gorm.DB, roleRepo repository.Role, name string) *models.Role {
+	role, err := roleRepo.GetByName(tx, name)
+	if err != nil {
+		log.Fatalf("Error looking up role: %v", err)
+	}
+	if role == nil {
+		log.Fatalf("Invalid role: %s", name)
+	}
+	return role
+}
+
+// roleNames maps role IDs to their names.
+func roleNames(tx *gorm.DB, roleRepo repository.Role) map[uint]string {
+	roles, err := roleRepo.ListAll(tx)
+	if err != nil {
+		log.Fatalf("Error listing roles: %v", err)
+	}
+	names := make(map[uint]string, len(roles))
+	for _, role := range roles {
+		names[role.ID] = role.Name
+	}
+	return names
+}
+
+func availableRoles(tx *gorm.DB, roleRepo repository.Role) string {
+	roles, err := roleRepo.ListAll(tx)
+	if err != nil {
+		log.Fatalf("Error listing roles: %v", err)
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// appendAuditEvent records event, performed through the CLI, in the audit log within tx.
+func appendAuditEvent(tx *gorm.DB, event *models.AuditEvent) {
+	if err := repository.NewAuditEvent().Append(tx, event); err != nil {
+		log.Fatalf("Error recording audit event: %v", err)
 	}
 }
 
@@ -246,18 +669,47 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password policy: %v", err)
+	}
+	passwordHasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hashing: %v", err)
+	}
+
 	tx := database.GetTransaction(ctx)
-	adminRepo := repository.NewAdmin()
+	adminRepo := repository.NewAdmin(passwordPolicy, passwordHasher)
+	// afterCommit, when set, finishes a command with changes that must only be made once tx has committed
+	var afterCommit func()
 
 	switch os.Args[1] {
 	case "create":
-		handleCreate(tx, adminRepo, os.Args[2:])
+		handleCreate(tx, adminRepo, repository.NewRole(), os.Args[2:])
 	case "update":
-		handleUpdate(tx, adminRepo, os.Args[2:])
+		handleUpdate(tx, adminRepo, repository.NewRole(), os.Args[2:])
 	case "delete":
 		handleDelete(tx, adminRepo, os.Args[2:])
 	case "list":
-		handleList(tx, adminRepo)
+		handleList(tx, adminRepo, repository.NewRole())
+	case "totp":
+		totpCipher, err := encryption.NewCipher(cfg.Security.TotpEncryptionKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize TOTP encryption: %v", err)
+		}
+		handleTotp(tx, adminRepo, repository.NewAdminTotp(), totp.NewManager(totpCipher, cfg.Security.TotpIssuer), os.Args[2:])
+	case "keys":
+		keyCipher, err := encryption.NewCipher(cfg.Security.DataEncryptionKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize data encryption: %v", err)
+		}
+		handleKeys(tx, repository.NewSigningKey(), keyCipher, os.Args[2:])
+	case "secret":
+		handleSecret(tx, repository.NewSetting(), os.Args[2:])
+	case "lockout":
+		handleLockout(tx, adminRepo, cfg.Redis, os.Args[2:])
+	case "iplist":
+		afterCommit = handleIPList(tx, cfg.Redis, os.Args[2:])
 	case "help":
 		printUsage()
 		os.Exit(0)
@@ -270,6 +722,34 @@ func main() {
 	if err := tx.Commit().Error; err != nil {
 		log.Fatalf("Failed to commit transaction: %v", err)
 	}
+	if afterCommit != nil {
+		afterCommit()
+	}
+
+	if os.Args[1] == "secret" {
+		announceSettings(cfg.Redis, database)
+	}
+}
+
+// announceSettings tells running servers to reload the settings. Servers that miss the
+// announcement still pick the change up on their next periodic reload.
+func announceSettings(redisConfig config.RedisConfig, database *db.DB) {
+	s, err := repository.NewSetting().Get(database.DB)
+	if err != nil {
+		log.Printf("Failed to read settings version: %v", err)
+		return
+	}
+
+	redisCli := redis.New(redisConfig)
+	if err = redisCli.Start(); err != nil {
+		log.Printf("Failed to connect to redis, servers will pick the change up on their next periodic reload: %v", err)
+		return
+	}
+	defer redisCli.Stop()
+
+	if err = setting.Publish(context.Background(), redisCli, s.Version); err != nil {
+		log.Printf("Failed to announce settings version %d: %v", s.Version, err)
+	}
 }
 
 func printUsage() {
@@ -279,6 +759,11 @@ func printUsage() {
 	fmt.Println("  update    Update an existing admin user. Use 'admin update -h' for more details.")
 	fmt.Println("  delete    Delete an admin user. Use 'admin delete -h' for more details.")
 	fmt.Println("  list      List all admin users. Use 'admin list -h' for more details.")
+	fmt.Println("  totp      Enroll or remove an admin's TOTP second factor. Use 'admin totp -h' for more details.")
+	fmt.Println("  keys      List, rotate or retire JWT signing keys. Use 'admin keys <list|rotate|retire> -h' for more details.")
+	fmt.Println("  secret    Rotate the HS256 secret key. Use 'admin secret rotate -h' for more details.")
+	fmt.Println("  lockout   Show or lift an admin's login lockout. Use 'admin lockout <show|unlock> -h' for more details.")
+	fmt.Println("  iplist    Show or edit the IP allow and deny lists. Use 'admin iplist <show|add|remove> -h' for more details.")
 	fmt.Println("  help      Display this help message.")
 	fmt.Println("\nTo get help for a specific command, use: admin <command> -h")
 }
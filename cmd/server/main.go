@@ -28,10 +28,18 @@ import (
 
 	"github.com/MoSed3/otp-server/internal/config"
 	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/delivery"
+	"github.com/MoSed3/otp-server/internal/encryption"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/otpcode"
+	"github.com/MoSed3/otp-server/internal/outbox"
+	"github.com/MoSed3/otp-server/internal/password"
 	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/repository"
 	"github.com/MoSed3/otp-server/internal/router"
 	"github.com/MoSed3/otp-server/internal/setting"
 	"github.com/MoSed3/otp-server/internal/token"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 func main() {
@@ -45,7 +53,17 @@ func main() {
 	appSettings := setting.New()
 	appSettings.Init(database)
 
-	jwtService := token.NewJWTService(appSettings)
+	dataCipher, err := encryption.NewCipher(cfg.Security.DataEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize data encryption: %v", err)
+	}
+
+	keySet := token.NewKeySet(database, repository.NewSigningKey(), dataCipher)
+	keySet.Init()
+	keySet.Start()
+	defer keySet.Stop()
+
+	jwtService := token.NewJWTService(appSettings, keySet)
 
 	redisClient := redis.New(cfg.Redis)
 	if err := redisClient.Start(); err != nil {
@@ -53,7 +71,51 @@ func main() {
 	}
 	defer redisClient.Stop()
 
-	r := router.New(cfg.Server, database, redisClient, jwtService)
+	settingsWatcher := setting.NewWatcher(appSettings, redisClient)
+	settingsWatcher.Start()
+	defer settingsWatcher.Stop()
+
+	sender, err := delivery.New(cfg.Delivery)
+	if err != nil {
+		log.Fatalf("Failed to initialize OTP delivery: %v", err)
+	}
+
+	deliveryWorker := delivery.NewWorker(database, repository.NewOtpDelivery(), repository.NewOtp(), appSettings, dataCipher, sender, cfg.Delivery)
+	deliveryWorker.Start()
+	defer deliveryWorker.Stop()
+
+	sinks, err := outbox.NewSinks(cfg.Outbox, redisClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox sinks: %v", err)
+	}
+	outboxRelay := outbox.NewRelay(database, repository.NewOutbox(), sinks...)
+	outboxRelay.Start()
+	defer outboxRelay.Stop()
+
+	otpHasher := otpcode.NewHasher(cfg.Security.OtpPepper)
+
+	totpCipher, err := encryption.NewCipher(cfg.Security.TotpEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize TOTP encryption: %v", err)
+	}
+	totpManager := totp.NewManager(totpCipher, cfg.Security.TotpIssuer)
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password policy: %v", err)
+	}
+	passwordHasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hashing: %v", err)
+	}
+
+	clientIP, err := middleware.NewClientIPResolver(cfg.Server.TrustedProxies, cfg.Server.TrustedProxyHeader)
+	if err != nil {
+		log.Fatalf("Failed to initialize trusted proxies: %v", err)
+	}
+	ipFilter := middleware.NewIPFilter(redisClient, cfg.IPBan)
+
+	r := router.New(cfg.Server, database, redisClient, jwtService, otpHasher, dataCipher, totpManager, passwordPolicy, passwordHasher, clientIP, ipFilter, appSettings)
 	server := r.Start()
 
 	log.Println("Server started successfully")
@@ -17,14 +17,34 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/profile": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Returns the public keys of the active and retiring signing keys as a JSON Web Key Set, so other services can verify access tokens by their kid header. Also served at /.well-known/jwks.json outside the API base path.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Get the public signing keys",
+                "responses": {
+                    "200": {
+                        "description": "JSON Web Key Set",
+                        "schema": {
+                            "$ref": "#/definitions/token.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/admins": {
             "get": {
                 "security": [
                     {
                         "BearerAuthAdmin": []
                     }
                 ],
-                "description": "Returns current admin information for authenticated requests",
+                "description": "Lists every admin account (requires admins:manage)",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,16 +54,25 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Get current authenticated admin",
+                "summary": "List admins",
                 "responses": {
                     "200": {
-                        "description": "Current admin information",
+                        "description": "Admin accounts",
                         "schema": {
-                            "$ref": "#/definitions/router.GetCurrentAdminResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/router.AdminResponse"
+                            }
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid or missing JWT token",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
                         "schema": {
                             "type": "string"
                         }
@@ -55,16 +84,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/admin/user/{id}": {
-            "get": {
+            },
+            "post": {
                 "security": [
                     {
                         "BearerAuthAdmin": []
                     }
                 ],
-                "description": "Get a user's details by their ID (admin only)",
+                "description": "Creates a new admin account (requires admins:manage). The caller must hold every permission of the role.",
                 "consumes": [
                     "application/json"
                 ],
@@ -74,25 +101,27 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Get a single user by ID",
+                "summary": "Create an admin",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Username, password and role ID",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.CreateAdminRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "User details",
+                    "201": {
+                        "description": "Created admin",
                         "schema": {
-                            "$ref": "#/definitions/router.UserResponse"
+                            "$ref": "#/definitions/router.AdminResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid request format, validation error, unknown role or password rejected by the policy",
                         "schema": {
                             "type": "string"
                         }
@@ -109,8 +138,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "409": {
+                        "description": "Username is already taken",
                         "schema": {
                             "type": "string"
                         }
@@ -124,14 +153,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/admin/user/{id}/status": {
-            "patch": {
+        "/admin/admins/{id}": {
+            "get": {
                 "security": [
                     {
                         "BearerAuthAdmin": []
                     }
                 ],
-                "description": "Disable or activate a user (sudo/super admin only)",
+                "description": "Gets an admin account by ID (requires admins:manage)",
                 "consumes": [
                     "application/json"
                 ],
@@ -141,34 +170,25 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Update user status",
+                "summary": "Get an admin",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Admin ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "New user status",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/router.UserStatusUpdateRequest"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User details",
+                        "description": "Admin details",
                         "schema": {
-                            "$ref": "#/definitions/router.UserResponse"
+                            "$ref": "#/definitions/router.AdminResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or user ID",
+                        "description": "Invalid admin ID",
                         "schema": {
                             "type": "string"
                         }
@@ -186,7 +206,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Admin not found",
                         "schema": {
                             "type": "string"
                         }
@@ -198,16 +218,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/admin/users": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuthAdmin": []
                     }
                 ],
-                "description": "Search users by phone number, first name, or last name (admin only)",
+                "description": "Deletes an admin account (requires admins:manage). The last super admin cannot be deleted.",
                 "consumes": [
                     "application/json"
                 ],
@@ -217,87 +235,22 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Search users",
+                "summary": "Delete an admin",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Admin ID",
                         "name": "id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "User phone number",
-                        "name": "phone_number",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "User first name",
-                        "name": "first_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "User last name",
-                        "name": "last_name",
-                        "in": "query"
-                    },
-                    {
-                        "enum": [
-                            1,
-                            2
-                        ],
-                        "type": "integer",
-                        "description": "User status (1: Active, 2: Disabled)",
-                        "name": "status",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Limit for pagination (max 100)",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Offset for pagination",
-                        "name": "offset",
-                        "in": "query"
-                    },
-                    {
-                        "enum": [
-                            "id",
-                            "phone_number",
-                            "first_name",
-                            "last_name",
-                            "status"
-                        ],
-                        "type": "string",
-                        "description": "Sort by field",
-                        "name": "sort_by",
-                        "in": "query"
-                    },
-                    {
-                        "enum": [
-                            "asc",
-                            "desc"
-                        ],
-                        "type": "string",
-                        "description": "Sort order",
-                        "name": "sort_order",
-                        "in": "query"
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "List of matching users with total count",
-                        "schema": {
-                            "$ref": "#/definitions/router.SearchUsersResponse"
-                        }
+                    "204": {
+                        "description": "Admin deleted"
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid admin ID",
                         "schema": {
                             "type": "string"
                         }
@@ -314,6 +267,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "404": {
+                        "description": "Admin not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Last super admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -321,11 +286,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/auth/admin": {
-            "post": {
-                "description": "Authenticates an admin user and returns a JWT token",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Changes an admin's username, role or password (requires admins:manage). A new password logs the admin out everywhere. The last super admin cannot be demoted.",
                 "consumes": [
                     "application/json"
                 ],
@@ -335,33 +303,58 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Admin login",
+                "summary": "Update an admin",
                 "parameters": [
                     {
-                        "description": "Admin credentials",
+                        "type": "integer",
+                        "description": "Admin ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/router.AdminLoginRequest"
+                            "$ref": "#/definitions/router.UpdateAdminRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "JWT token for authenticated admin",
+                        "description": "Updated admin",
                         "schema": {
-                            "$ref": "#/definitions/router.AdminLoginResponse"
+                            "$ref": "#/definitions/router.AdminResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or missing credentials",
+                        "description": "Invalid request format, validation error or password rejected by the policy",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "401": {
-                        "description": "Invalid username or password",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Admin not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Username is already taken or last super admin",
                         "schema": {
                             "type": "string"
                         }
@@ -375,9 +368,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/request-otp": {
-            "post": {
-                "description": "Creates or finds user by phone number and sends OTP for authentication",
+        "/admin/admins/{id}/lockout": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Returns the failed logins of an admin and whether its username is locked (requires admins:manage)",
                 "consumes": [
                     "application/json"
                 ],
@@ -385,29 +383,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "Request OTP for phone number",
+                "summary": "Get an admin's lockout state",
                 "parameters": [
                     {
-                        "description": "Phone number in international format",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/router.RequestOTPRequest"
-                        }
+                        "type": "integer",
+                        "description": "Admin ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OTP token generated successfully",
+                        "description": "Lockout state",
                         "schema": {
-                            "$ref": "#/definitions/router.RequestOTPResponse"
+                            "$ref": "#/definitions/router.AdminLockoutResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or phone number",
+                        "description": "Invalid admin ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Admin not found",
                         "schema": {
                             "type": "string"
                         }
@@ -419,11 +433,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/auth/verify-otp": {
-            "post": {
-                "description": "Verifies the OTP code and returns JWT token for authenticated user",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Lifts the lockout of an admin's username and forgets its failed logins (requires admins:manage)",
                 "consumes": [
                     "application/json"
                 ],
@@ -431,42 +448,180 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "Verify OTP code",
+                "summary": "Unlock an admin",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Bearer token from request-otp endpoint",
-                        "name": "Authorization",
-                        "in": "header",
+                        "type": "integer",
+                        "description": "Admin ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Admin unlocked"
                     },
-                    {
-                        "description": "6-character OTP code",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                    "400": {
+                        "description": "Invalid admin ID",
                         "schema": {
-                            "$ref": "#/definitions/router.VerifyOTPRequest"
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Admin not found",
+                        "schema": {
+                            "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Lists audit events, newest first by default (requires audit:read)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Search the audit log",
+                "parameters": [
+                    {
+                        "enum": [
+                            "admin",
+                            "user",
+                            "anonymous",
+                            "system"
+                        ],
+                        "type": "string",
+                        "description": "Actor type",
+                        "name": "actor_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Actor ID",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action, e.g. admin.login or user.status_updated",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "admin",
+                            "role",
+                            "user",
+                            "settings",
+                            "ip_list",
+                            "signing_key"
+                        ],
+                        "type": "string",
+                        "description": "Target type",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target ID",
+                        "name": "target_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events at or after this RFC 3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events before this RFC 3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit for pagination (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort order by ID",
+                        "name": "sort_order",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "JWT token for authenticated user",
+                        "description": "Matching events with total count",
                         "schema": {
-                            "$ref": "#/definitions/router.VerifyOTPResponse"
+                            "$ref": "#/definitions/router.SearchAuditEventsResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or OTP code",
+                        "description": "Invalid query parameters",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "401": {
-                        "description": "Invalid bearer token or OTP code",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
                         "schema": {
                             "type": "string"
                         }
@@ -480,14 +635,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/profile": {
+        "/admin/audit/verify": {
             "get": {
                 "security": [
                     {
-                        "BearerAuthUser": []
+                        "BearerAuthAdmin": []
                     }
                 ],
-                "description": "Returns current user information for authenticated requests",
+                "description": "Recomputes the hash chain of the audit log and reports the first altered event (requires audit:read)",
                 "consumes": [
                     "application/json"
                 ],
@@ -495,18 +650,132 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Admin"
                 ],
-                "summary": "Get current authenticated user",
+                "summary": "Verify the audit log",
                 "responses": {
                     "200": {
-                        "description": "Current user information",
+                        "description": "Verification result",
                         "schema": {
-                            "$ref": "#/definitions/router.UserResponse"
+                            "$ref": "#/definitions/router.VerifyAuditChainResponse"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid or missing JWT token",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/diagnostics": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Returns the settings version loaded by the instance serving the request, to check that every replica picked up a change (requires settings:read)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get instance diagnostics",
+                "responses": {
+                    "200": {
+                        "description": "Instance diagnostics",
+                        "schema": {
+                            "$ref": "#/definitions/router.DiagnosticsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/ip-lists/{list}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Lists the active entries of the allow or deny list (requires ip_lists:manage)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List an IP list",
+                "parameters": [
+                    {
+                        "enum": [
+                            "allow",
+                            "deny"
+                        ],
+                        "type": "string",
+                        "description": "List",
+                        "name": "list",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Entries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/router.IPListEntryResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown list",
                         "schema": {
                             "type": "string"
                         }
@@ -518,149 +787,2840 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "security": [
-                    {
-                        "BearerAuthUser": []
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Puts an address or CIDR on the allow or deny list, replacing any entry for it (requires ip_lists:manage). Allowed clients skip the deny list and rate limits; denied clients are rejected with 403.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Add an IP list entry",
+                "parameters": [
+                    {
+                        "enum": [
+                            "allow",
+                            "deny"
+                        ],
+                        "type": "string",
+                        "description": "List",
+                        "name": "list",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Network, reason and optional expiry",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.AddIPListEntryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Added entry",
+                        "schema": {
+                            "$ref": "#/definitions/router.IPListEntryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown list",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Takes an address or CIDR off the allow or deny list, such as to lift an automatic ban (requires ip_lists:manage)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Remove an IP list entry",
+                "parameters": [
+                    {
+                        "enum": [
+                            "allow",
+                            "deny"
+                        ],
+                        "type": "string",
+                        "description": "List",
+                        "name": "list",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Address or CIDR of the entry",
+                        "name": "network",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Entry removed"
+                    },
+                    "400": {
+                        "description": "Invalid address or CIDR",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown list or entry not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/mfa-policy": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Requires (or stops requiring) a TOTP second factor for every admin login (requires settings:write). The caller must be enrolled to enable enforcement.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Enforce MFA for all admins",
+                "parameters": [
+                    {
+                        "description": "Whether MFA is required",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.MfaPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated MFA policy",
+                        "schema": {
+                            "$ref": "#/definitions/router.MfaPolicyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or caller not enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Lists every permission that can be granted to a role (requires admins:manage)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List permissions",
+                "responses": {
+                    "200": {
+                        "description": "Permissions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Returns current admin information for authenticated requests",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get current authenticated admin",
+                "responses": {
+                    "200": {
+                        "description": "Current admin information",
+                        "schema": {
+                            "$ref": "#/definitions/router.GetCurrentAdminResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profile/totp": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Generates a new authenticator app secret for the authenticated admin. The enrollment is active once confirmed with a first code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Begin admin TOTP enrollment",
+                "responses": {
+                    "200": {
+                        "description": "Secret and otpauth:// URI to add to an authenticator app",
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpEnrollmentResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Removes the authenticated admin's authenticator app enrollment. Not allowed while MFA is enforced.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Disable admin TOTP",
+                "parameters": [
+                    {
+                        "description": "Current authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "TOTP disabled"
+                    },
+                    "400": {
+                        "description": "Invalid request format or TOTP not enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid TOTP code or JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "MFA is enforced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profile/totp/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Activates the pending authenticator app secret with a first code. Later logins require the second factor.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Confirm admin TOTP enrollment",
+                "parameters": [
+                    {
+                        "description": "Current authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "TOTP enabled"
+                    },
+                    "400": {
+                        "description": "Invalid request format or no pending enrollment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid TOTP code or JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/roles": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Lists every admin role with its permissions (requires admins:manage)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List roles",
+                "responses": {
+                    "200": {
+                        "description": "Roles",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/router.RoleResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Creates a custom admin role (requires admins:manage). The caller must hold every permission it grants.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Create a role",
+                "parameters": [
+                    {
+                        "description": "Name, description and permissions",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.CreateRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created role",
+                        "schema": {
+                            "$ref": "#/definitions/router.RoleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Role name is already taken",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/roles/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Deletes a custom role that no admin holds (requires admins:manage)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Delete a role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Role deleted"
+                    },
+                    "400": {
+                        "description": "Invalid role ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Role not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Built-in role or role in use",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Changes a role's name, description or permissions (requires admins:manage). The Super role cannot be changed and built-in roles cannot be renamed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Update a role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.UpdateRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated role",
+                        "schema": {
+                            "$ref": "#/definitions/router.RoleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Role not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Role name is already taken or built-in role",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/secret-key/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Generates a new HS256 secret key with a new kid (requires settings:write). Tokens signed with the previous key keep working for the grace period.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Rotate the JWT secret key",
+                "parameters": [
+                    {
+                        "description": "Grace period in minutes for the previous key",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/router.SecretKeyRotateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New key id and how long the previous key stays valid",
+                        "schema": {
+                            "$ref": "#/definitions/router.SecretKeyRotateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/settings": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Returns the application settings (requires settings:read). The secret key is never returned.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get settings",
+                "responses": {
+                    "200": {
+                        "description": "Current settings",
+                        "schema": {
+                            "$ref": "#/definitions/router.SettingsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Updates the given settings (requires settings:write) and reloads them on every server instance. Use /admin/secret-key/rotate and /admin/mfa-policy for the secret key and MFA enforcement.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Update settings",
+                "parameters": [
+                    {
+                        "description": "Settings to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.SettingsUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated settings",
+                        "schema": {
+                            "$ref": "#/definitions/router.SettingsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or value out of bounds",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/user/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Get a user's details by their ID (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get a single user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User details",
+                        "schema": {
+                            "$ref": "#/definitions/router.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/user/{id}/status": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Disable or activate a user (requires users:write)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Update user status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New user status",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.UserStatusUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User details",
+                        "schema": {
+                            "$ref": "#/definitions/router.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or user ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthAdmin": []
+                    }
+                ],
+                "description": "Search users by phone number, first name, or last name (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Search users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User first name",
+                        "name": "first_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User last name",
+                        "name": "last_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            1,
+                            2
+                        ],
+                        "type": "integer",
+                        "description": "User status (1: Active, 2: Disabled)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit for pagination (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "phone_number",
+                            "first_name",
+                            "last_name",
+                            "status"
+                        ],
+                        "type": "string",
+                        "description": "Sort by field",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort order",
+                        "name": "sort_order",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of matching users with total count",
+                        "schema": {
+                            "$ref": "#/definitions/router.SearchUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: Insufficient privileges",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/admin": {
+            "post": {
+                "description": "Authenticates an admin user and returns a JWT token, or an MFA challenge token when the admin has enrolled TOTP",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Admin login",
+                "parameters": [
+                    {
+                        "description": "Admin credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT and refresh token for authenticated admin, challenge token when MFA is required, or enrollment token when MFA is enforced and the admin has not enrolled",
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or missing credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid username or password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed logins for the username, see Retry-After header",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/admin/mfa": {
+            "post": {
+                "description": "Exchanges the challenge token returned by /auth/admin and a TOTP code for an admin JWT token. Each challenge token can be used once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Complete admin MFA",
+                "parameters": [
+                    {
+                        "description": "Challenge token and authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminMfaRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT and refresh token for authenticated admin",
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired challenge token, or invalid code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed logins for the admin, see Retry-After header",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/admin/mfa/enroll": {
+            "post": {
+                "description": "Generates an authenticator app secret for an admin who must use MFA but has not enrolled, using the enrollment token returned by /auth/admin",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Begin admin TOTP enrollment during login",
+                "parameters": [
+                    {
+                        "description": "Enrollment token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminMfaEnrollRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Secret and otpauth:// URI to add to an authenticator app",
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpEnrollmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired enrollment token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/admin/mfa/enroll/confirm": {
+            "post": {
+                "description": "Activates the authenticator app secret from /auth/admin/mfa/enroll with a first code and completes the login. Wrong codes count towards the admin lockout, and the enrollment token cannot be used again once enrollment is confirmed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Confirm admin TOTP enrollment during login",
+                "parameters": [
+                    {
+                        "description": "Enrollment token and current authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminMfaEnrollConfirmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT and refresh token for authenticated admin",
+                        "schema": {
+                            "$ref": "#/definitions/router.AdminLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or no pending enrollment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired enrollment token, or invalid code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed logins for the admin, see Retry-After header",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "description": "Exchanges a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes every token issued from the same login.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New access and refresh tokens",
+                        "schema": {
+                            "$ref": "#/definitions/router.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired or reused refresh token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "User is disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/request-otp": {
+            "post": {
+                "description": "Creates or finds user by phone number and sends OTP for authentication",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Request OTP for phone number",
+                "parameters": [
+                    {
+                        "description": "Phone number in international format",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.RequestOTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OTP token generated successfully",
+                        "schema": {
+                            "$ref": "#/definitions/router.RequestOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or phone number",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "OTPs cannot be sent to the country of the number (code sms_country_not_allowed)",
+                        "schema": {
+                            "$ref": "#/definitions/router.SmsBlockedResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "An SMS budget is exhausted, see code and Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/router.SmsBlockedResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/totp-login": {
+            "post": {
+                "description": "Logs in a user enrolled in TOTP with the phone number and a code from the authenticator app, without requesting an OTP, so no SMS is sent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Log in with an authenticator app code",
+                "parameters": [
+                    {
+                        "description": "Phone number in international format and authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT and refresh token for authenticated user",
+                        "schema": {
+                            "$ref": "#/definitions/router.VerifyOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, phone number or code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid phone number or code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "User is disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many attempts, see Retry-After header",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/verify-otp": {
+            "post": {
+                "description": "Verifies the OTP code, or an authenticator app code for users enrolled in TOTP, and returns JWT token for authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Verify OTP code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer token from request-otp endpoint",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "OTP code (length and alphabet follow the OTP policy) or totp_code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.VerifyOTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT and refresh token for authenticated user",
+                        "schema": {
+                            "$ref": "#/definitions/router.VerifyOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or OTP code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid bearer token or OTP code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/logout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Revokes the session of the presented access token together with its refresh token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Log out",
+                "responses": {
+                    "204": {
+                        "description": "Logged out"
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Returns current user information for authenticated requests",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get current authenticated user",
+                "responses": {
+                    "200": {
+                        "description": "Current user information",
+                        "schema": {
+                            "$ref": "#/definitions/router.UserResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Updates the authenticated user's first name and last name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Update user profile",
+                "parameters": [
+                    {
+                        "description": "User profile information to update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.UpdateProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated user information",
+                        "schema": {
+                            "$ref": "#/definitions/router.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/profile/totp": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Generates a new authenticator app secret for the authenticated user. The enrollment is active once confirmed with a first code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Begin TOTP enrollment",
+                "responses": {
+                    "200": {
+                        "description": "Secret and otpauth:// URI to add to an authenticator app",
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpEnrollmentResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Removes the authenticated user's authenticator app enrollment. Requires a current code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "description": "Current authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "TOTP disabled"
+                    },
+                    "400": {
+                        "description": "Invalid request format or TOTP not enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid TOTP code or JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/profile/totp/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Activates the pending authenticator app secret with a first code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Confirm TOTP enrollment",
+                "parameters": [
+                    {
+                        "description": "Current authenticator app code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.TotpCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "TOTP enabled"
+                    },
+                    "400": {
+                        "description": "Invalid request format or no pending enrollment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid TOTP code or JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Returns the devices the authenticated user is signed in on, most recently used first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "List active sessions",
+                "responses": {
+                    "200": {
+                        "description": "Active sessions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/router.SessionResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuthUser": []
+                    }
+                ],
+                "description": "Signs the authenticated user out of one device",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Revoke a session",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Session revoked"
+                    },
+                    "400": {
+                        "description": "Invalid session ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing JWT token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.AdminRole": {
+            "type": "integer",
+            "enum": [
+                1,
+                2,
+                3
+            ],
+            "x-enum-varnames": [
+                "RoleSuperAdmin",
+                "RoleSudoAdmin",
+                "RoleVisitorAdmin"
+            ]
+        },
+        "models.AuditAction": {
+            "type": "string",
+            "enum": [
+                "admin.login",
+                "admin.login_pending_mfa",
+                "admin.login_failed",
+                "admin.mfa_verified",
+                "admin.mfa_failed",
+                "admin.locked_out",
+                "admin.unlocked",
+                "admin.totp_enrolled",
+                "admin.totp_disabled",
+                "admin.created",
+                "admin.updated",
+                "admin.deleted",
+                "role.created",
+                "role.updated",
+                "role.deleted",
+                "user.status_updated",
+                "settings.updated",
+                "settings.mfa_policy_updated",
+                "settings.secret_key_rotated",
+                "ip_list.added",
+                "ip_list.removed",
+                "signing_key.rotated",
+                "signing_key.retired"
+            ],
+            "x-enum-comments": {
+                "AuditAdminLogin": "Password and any second factor checked",
+                "AuditAdminLoginPendingMfa": "Password checked, second factor or enrollment pending"
+            },
+            "x-enum-descriptions": [
+                "Password and any second factor checked",
+                "Password checked, second factor or enrollment pending",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                "",
+                ""
+            ],
+            "x-enum-varnames": [
+                "AuditAdminLogin",
+                "AuditAdminLoginPendingMfa",
+                "AuditAdminLoginFailed",
+                "AuditAdminMfaVerified",
+                "AuditAdminMfaFailed",
+                "AuditAdminLockedOut",
+                "AuditAdminUnlocked",
+                "AuditAdminTotpEnrolled",
+                "AuditAdminTotpDisabled",
+                "AuditAdminCreated",
+                "AuditAdminUpdated",
+                "AuditAdminDeleted",
+                "AuditRoleCreated",
+                "AuditRoleUpdated",
+                "AuditRoleDeleted",
+                "AuditUserStatusUpdated",
+                "AuditSettingsUpdated",
+                "AuditMfaPolicyUpdated",
+                "AuditSecretKeyRotated",
+                "AuditIPListAdded",
+                "AuditIPListRemoved",
+                "AuditSigningKeyRotated",
+                "AuditSigningKeyRetired"
+            ]
+        },
+        "models.AuditActorType": {
+            "type": "string",
+            "enum": [
+                "admin",
+                "anonymous",
+                "system"
+            ],
+            "x-enum-comments": {
+                "AuditActorSystem": "Operators using the admin CLI"
+            },
+            "x-enum-descriptions": [
+                "",
+                "",
+                "Operators using the admin CLI"
+            ],
+            "x-enum-varnames": [
+                "AuditActorAdmin",
+                "AuditActorAnonymous",
+                "AuditActorSystem"
+            ]
+        },
+        "models.AuditChange": {
+            "type": "object",
+            "properties": {
+                "after": {},
+                "before": {}
+            }
+        },
+        "models.OtpThrottleTier": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "window": {
+                    "description": "Seconds",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Permission": {
+            "type": "string",
+            "enum": [
+                "users:read",
+                "users:write",
+                "settings:read",
+                "settings:write",
+                "admins:manage",
+                "audit:read",
+                "ip_lists:manage"
+            ],
+            "x-enum-varnames": [
+                "PermissionUsersRead",
+                "PermissionUsersWrite",
+                "PermissionSettingsRead",
+                "PermissionSettingsWrite",
+                "PermissionAdminsManage",
+                "PermissionAuditRead",
+                "PermissionIPListsManage"
+            ]
+        },
+        "models.UserStatus": {
+            "type": "integer",
+            "enum": [
+                1,
+                2
+            ],
+            "x-enum-varnames": [
+                "UserStatusActive",
+                "UserStatusDisabled"
+            ]
+        },
+        "router.AddIPListEntryRequest": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "description": "Seconds, permanent when omitted",
+                    "type": "integer"
+                },
+                "network": {
+                    "description": "Address or CIDR",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminLockoutResponse": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "Failed logins since the last success or lockout",
+                    "type": "integer"
+                },
+                "locked": {
+                    "type": "boolean"
+                },
+                "locked_until": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminLoginRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminLoginResponse": {
+            "type": "object",
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "enrollment_token": {
+                    "description": "Only accepted by /auth/admin/mfa/enroll",
+                    "type": "string"
+                },
+                "mfa_enrollment_required": {
+                    "type": "boolean"
+                },
+                "mfa_required": {
+                    "type": "boolean"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminMfaEnrollConfirmRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "enrollment_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminMfaEnrollRequest": {
+            "type": "object",
+            "properties": {
+                "enrollment_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminMfaRequest": {
+            "type": "object",
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AdminResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "role": {
+                    "$ref": "#/definitions/models.AdminRole"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.AuditEventResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/models.AuditAction"
+                },
+                "actor_id": {
+                    "type": "integer"
+                },
+                "actor_name": {
+                    "type": "string"
+                },
+                "actor_type": {
+                    "$ref": "#/definitions/models.AuditActorType"
+                },
+                "changes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.AuditChange"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.CreateAdminRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/models.AdminRole"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.CreateRoleRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                }
+            }
+        },
+        "router.DiagnosticsResponse": {
+            "type": "object",
+            "properties": {
+                "secret_key_id": {
+                    "type": "string"
+                },
+                "server_time": {
+                    "type": "string"
+                },
+                "settings_loaded_at": {
+                    "type": "string"
+                },
+                "settings_version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "router.GetCurrentAdminResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                },
+                "role": {
+                    "$ref": "#/definitions/models.AdminRole"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.IPListEntryResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "description": "Admin username, \"cli\" or \"auto-ban\"",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.MfaPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "router.MfaPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "router.RefreshTokenRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.RequestOTPRequest": {
+            "type": "object",
+            "properties": {
+                "phone_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.RequestOTPResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.RoleResponse": {
+            "type": "object",
+            "properties": {
+                "builtin": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                }
+            }
+        },
+        "router.SearchAuditEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/router.AuditEventResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "router.SearchUsersResponse": {
+            "type": "object",
+            "properties": {
+                "total": {
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/router.UserResponse"
+                    }
+                }
+            }
+        },
+        "router.SecretKeyRotateRequest": {
+            "type": "object",
+            "properties": {
+                "grace_period": {
+                    "description": "Minutes, defaults to the configured grace period",
+                    "type": "integer"
+                }
+            }
+        },
+        "router.SecretKeyRotateResponse": {
+            "type": "object",
+            "properties": {
+                "kid": {
+                    "type": "string"
+                },
+                "previous_valid_until": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.SessionResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "device_info": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "last_seen_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "router.SettingsResponse": {
+            "type": "object",
+            "properties": {
+                "access_token_expire": {
+                    "type": "integer"
+                },
+                "admin_lockout_attempts": {
+                    "type": "integer"
+                },
+                "admin_lockout_duration": {
+                    "type": "integer"
+                },
+                "admin_login_free_tries": {
+                    "type": "integer"
+                },
+                "admin_login_max_delay": {
+                    "type": "integer"
+                },
+                "admin_mfa_required": {
+                    "type": "boolean"
+                },
+                "otp_cooldown": {
+                    "type": "integer"
+                },
+                "otp_length": {
+                    "type": "integer"
+                },
+                "otp_max_attempts": {
+                    "type": "integer"
+                },
+                "otp_numeric_only": {
+                    "type": "boolean"
+                },
+                "otp_session_ttl": {
+                    "type": "integer"
+                },
+                "otp_throttle_tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OtpThrottleTier"
+                    }
+                },
+                "refresh_token_expire": {
+                    "type": "integer"
+                },
+                "secret_key_grace_period": {
+                    "type": "integer"
+                },
+                "secret_key_id": {
+                    "type": "string"
+                },
+                "sms_allowed_countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sms_cost": {
+                    "type": "integer"
+                },
+                "sms_country_costs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "sms_country_hourly_limit": {
+                    "type": "integer"
+                },
+                "sms_daily_spend_cap": {
+                    "type": "integer"
+                },
+                "sms_denied_countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sms_prefix_hourly_limit": {
+                    "type": "integer"
+                },
+                "sms_prefix_length": {
+                    "type": "integer"
+                },
+                "sms_sequential_limit": {
+                    "type": "integer"
+                },
+                "sms_sequential_window": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "router.SettingsUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "access_token_expire": {
+                    "type": "integer"
+                },
+                "admin_lockout_attempts": {
+                    "type": "integer"
+                },
+                "admin_lockout_duration": {
+                    "type": "integer"
+                },
+                "admin_login_free_tries": {
+                    "type": "integer"
+                },
+                "admin_login_max_delay": {
+                    "type": "integer"
+                },
+                "otp_cooldown": {
+                    "type": "integer"
+                },
+                "otp_length": {
+                    "type": "integer"
+                },
+                "otp_max_attempts": {
+                    "type": "integer"
+                },
+                "otp_numeric_only": {
+                    "type": "boolean"
+                },
+                "otp_session_ttl": {
+                    "type": "integer"
+                },
+                "otp_throttle_tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OtpThrottleTier"
                     }
-                ],
-                "description": "Updates the authenticated user's first name and last name",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "User"
-                ],
-                "summary": "Update user profile",
-                "parameters": [
-                    {
-                        "description": "User profile information to update",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/router.UpdateProfileRequest"
-                        }
+                },
+                "refresh_token_expire": {
+                    "type": "integer"
+                },
+                "secret_key_grace_period": {
+                    "type": "integer"
+                },
+                "sms_allowed_countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Updated user information",
-                        "schema": {
-                            "$ref": "#/definitions/router.UserResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid request format or validation error",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized - invalid or missing JWT token",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "type": "string"
-                        }
+                },
+                "sms_cost": {
+                    "type": "integer"
+                },
+                "sms_country_costs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "sms_country_hourly_limit": {
+                    "type": "integer"
+                },
+                "sms_daily_spend_cap": {
+                    "type": "integer"
+                },
+                "sms_denied_countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
+                },
+                "sms_prefix_hourly_limit": {
+                    "type": "integer"
+                },
+                "sms_prefix_length": {
+                    "type": "integer"
+                },
+                "sms_sequential_limit": {
+                    "type": "integer"
+                },
+                "sms_sequential_window": {
+                    "type": "integer"
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AdminRole": {
-            "type": "integer",
-            "enum": [
-                1,
-                2,
-                3
-            ],
-            "x-enum-varnames": [
-                "RoleSuperAdmin",
-                "RoleSudo",
-                "RoleVisitor"
-            ]
-        },
-        "models.UserStatus": {
-            "type": "integer",
-            "enum": [
-                1,
-                2
-            ],
-            "x-enum-varnames": [
-                "UserStatusActive",
-                "UserStatusDisabled"
-            ]
         },
-        "router.AdminLoginRequest": {
+        "router.SmsBlockedResponse": {
             "type": "object",
             "properties": {
-                "password": {
-                    "type": "string"
+                "code": {
+                    "$ref": "#/definitions/service.SmsBlockedCode"
                 },
-                "username": {
+                "error": {
                     "type": "string"
                 }
             }
         },
-        "router.AdminLoginResponse": {
+        "router.TokenResponse": {
             "type": "object",
             "properties": {
+                "refresh_token": {
+                    "type": "string"
+                },
                 "token": {
                     "type": "string"
                 }
             }
         },
-        "router.GetCurrentAdminResponse": {
+        "router.TotpCodeRequest": {
             "type": "object",
             "properties": {
-                "id": {
-                    "type": "integer"
-                },
-                "role": {
-                    "$ref": "#/definitions/models.AdminRole"
-                },
-                "username": {
+                "code": {
                     "type": "string"
                 }
             }
         },
-        "router.RequestOTPRequest": {
+        "router.TotpEnrollmentResponse": {
             "type": "object",
             "properties": {
-                "phone_number": {
+                "secret": {
+                    "type": "string"
+                },
+                "uri": {
                     "type": "string"
                 }
             }
         },
-        "router.RequestOTPResponse": {
+        "router.TotpLoginRequest": {
             "type": "object",
             "properties": {
-                "token": {
+                "code": {
+                    "type": "string"
+                },
+                "phone_number": {
                     "type": "string"
                 }
             }
         },
-        "router.SearchUsersResponse": {
+        "router.UpdateAdminRequest": {
             "type": "object",
             "properties": {
-                "total": {
-                    "type": "integer"
+                "password": {
+                    "type": "string"
                 },
-                "users": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/router.UserResponse"
-                    }
+                "role": {
+                    "$ref": "#/definitions/models.AdminRole"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
@@ -675,6 +3635,23 @@ const docTemplate = `{
                 }
             }
         },
+        "router.UpdateRoleRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                }
+            }
+        },
         "router.UserResponse": {
             "type": "object",
             "properties": {
@@ -703,21 +3680,102 @@ const docTemplate = `{
                 }
             }
         },
+        "router.VerifyAuditChainResponse": {
+            "type": "object",
+            "properties": {
+                "broken_at": {
+                    "description": "ID of the first altered event",
+                    "type": "integer"
+                },
+                "checked": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
         "router.VerifyOTPRequest": {
             "type": "object",
             "properties": {
                 "code": {
                     "type": "string"
+                },
+                "totp_code": {
+                    "type": "string"
                 }
             }
         },
         "router.VerifyOTPResponse": {
             "type": "object",
             "properties": {
+                "refresh_token": {
+                    "type": "string"
+                },
                 "token": {
                     "type": "string"
                 }
             }
+        },
+        "service.SmsBlockedCode": {
+            "type": "string",
+            "enum": [
+                "sms_country_not_allowed",
+                "sms_country_budget_exceeded",
+                "sms_prefix_budget_exceeded",
+                "sms_sequential_numbers",
+                "sms_daily_spend_cap_reached"
+            ],
+            "x-enum-varnames": [
+                "SmsCountryNotAllowed",
+                "SmsCountryBudgetExceeded",
+                "SmsPrefixBudgetExceeded",
+                "SmsSequentialNumbers",
+                "SmsDailySpendCapReached"
+            ]
+        },
+        "token.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "token.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/token.JWK"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
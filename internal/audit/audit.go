@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+)
+
+// Target types of audit events.
+const (
+	TargetAdmin      = "admin"
+	TargetRole       = "role"
+	TargetUser       = "user"
+	TargetSettings   = "settings"
+	TargetIPList     = "ip_list"
+	TargetSigningKey = "signing_key"
+)
+
+// Redacted replaces secret values, such as passwords, in recorded changes.
+const Redacted = "[redacted]"
+
+// Recorder appends events to the hash-chained audit log.
+type Recorder struct {
+	database *db.DB
+	repo     repository.AuditEvent
+}
+
+// NewRecorder creates a new Recorder instance.
+func NewRecorder(database *db.DB, repo repository.AuditEvent) *Recorder {
+	return &Recorder{database: database, repo: repo}
+}
+
+// Record appends event inside tx, so it is kept only if the audited change is committed.
+// The client information is taken from the request the transaction belongs to.
+func (r *Recorder) Record(tx *gorm.DB, event *models.AuditEvent) error {
+	withRequestInfo(tx.Statement.Context, event)
+	return r.repo.Append(tx, event)
+}
+
+// RecordDetached appends event in a transaction of its own, so failed attempts are kept even though
+// the request transaction rolls back. It must not be called after Record in the same request: the
+// request transaction would still hold the chain lock. Errors are logged, not returned.
+func (r *Recorder) RecordDetached(ctx context.Context, event *models.AuditEvent) {
+	withRequestInfo(ctx, event)
+
+	tx := r.database.GetTransaction(context.WithoutCancel(ctx))
+	if tx.Error != nil {
+		log.Printf("Failed to begin audit transaction: %v", tx.Error)
+		return
+	}
+	if err := r.repo.Append(tx, event); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Failed to commit audit event %s: %v", event.Action, err)
+	}
+}
+
+func withRequestInfo(ctx context.Context, event *models.AuditEvent) {
+	info := middleware.GetRequestInfoFromContext(ctx)
+	event.IPAddress = info.IPAddress
+	event.UserAgent = info.UserAgent
+	event.RequestID = info.RequestID
+}
+
+// ByAdmin starts an event performed by admin.
+func ByAdmin(admin *models.Admin, action models.AuditAction) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorType: models.AuditActorAdmin,
+		ActorID:   &admin.ID,
+		ActorName: admin.Username,
+		Action:    action,
+	}
+}
+
+// ByAnonymous starts an event performed by an unauthenticated client, named by what it claimed to be.
+func ByAnonymous(name string, action models.AuditAction) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorType: models.AuditActorAnonymous,
+		ActorName: name,
+		Action:    action,
+	}
+}
+
+// BySystem starts an event performed outside the API, such as through the admin CLI.
+func BySystem(name string, action models.AuditAction) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorType: models.AuditActorSystem,
+		ActorName: name,
+		Action:    action,
+	}
+}
+
+// On sets the target of event and returns it.
+func On(event *models.AuditEvent, targetType string, targetID uint) *models.AuditEvent {
+	event.TargetType = targetType
+	event.TargetID = strconv.FormatUint(uint64(targetID), 10)
+	return event
+}
+
+// OnNetwork sets an allow or deny list entry as the target of event and returns it.
+func OnNetwork(event *models.AuditEvent, list, network string) *models.AuditEvent {
+	event.TargetType = TargetIPList
+	event.TargetID = list + ":" + network
+	return event
+}
+
+// Diff returns the fields whose JSON value differs between before and after, which must be values of
+// the same struct type. Fields named in exclude are skipped.
+func Diff(before, after any, exclude ...string) map[string]models.AuditChange {
+	beforeFields, afterFields := toFields(before), toFields(after)
+	for _, name := range exclude {
+		delete(beforeFields, name)
+		delete(afterFields, name)
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for name, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[name], value) {
+			changes[name] = models.AuditChange{Before: beforeFields[name], After: value}
+		}
+	}
+	return changes
+}
+
+func toFields(v any) map[string]any {
+	fields := make(map[string]any)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -29,16 +30,67 @@ type RedisConfig struct {
 }
 
 type ServerConfig struct {
-	WebAppHost string
-	WebAppPort int
-	CertFile   string
-	KeyFile    string
+	WebAppHost         string
+	WebAppPort         int
+	CertFile           string
+	KeyFile            string
+	TrustedProxies     []string // CIDRs or addresses whose forwarding headers are honored
+	TrustedProxyHeader string   // The one forwarding header the trusted proxies set
+}
+
+type DeliveryConfig struct {
+	Provider       string
+	FilePath       string
+	GatewayURL     string
+	GatewayAPIKey  string
+	GatewaySender  string
+	GatewayTimeout int // Seconds
+	Workers        int
+	MaxAttempts    int
+	RetryBaseDelay int // Seconds
+	RetryMaxDelay  int // Seconds
+}
+
+type OutboxConfig struct {
+	Sinks        []string
+	RedisChannel string
+	WebhookURL   string
+}
+
+type SecurityConfig struct {
+	OtpPepper         string
+	DataEncryptionKey string
+	TotpEncryptionKey string
+	TotpIssuer        string
+}
+
+type PasswordConfig struct {
+	MinLength         int
+	MinEntropy        int // Estimated bits
+	HistorySize       int
+	BreachedListFile  string
+	HashAlgorithm     string
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+}
+
+type IPBanConfig struct {
+	Threshold int // Rate limit rejections that ban an address, 0 disables bans
+	Window    int // Seconds
+	Duration  int // Seconds
 }
 
 type Config struct {
 	Database DatabaseConfig
 	Redis    RedisConfig
 	Server   ServerConfig
+	Delivery DeliveryConfig
+	Outbox   OutboxConfig
+	Security SecurityConfig
+	Password PasswordConfig
+	IPBan    IPBanConfig
 }
 
 var AppConfig *Config
@@ -71,6 +123,11 @@ func LoadConfig() *Config {
 	cfg.Server.WebAppPort = GetEnvAsInt("WEBAPP_PORT", 8000)
 	cfg.Server.CertFile = GetEnv("CERT_FILE", "")
 	cfg.Server.KeyFile = GetEnv("KEY_FILE", "")
+	cfg.Server.TrustedProxies = GetEnvAsSlice("TRUSTED_PROXIES", nil)
+	cfg.Server.TrustedProxyHeader = GetEnv("TRUSTED_PROXY_HEADER", "x-forwarded-for")
+	cfg.IPBan.Threshold = GetEnvAsInt("IP_AUTOBAN_THRESHOLD", 5)
+	cfg.IPBan.Window = GetEnvAsInt("IP_AUTOBAN_WINDOW", 600)
+	cfg.IPBan.Duration = GetEnvAsInt("IP_AUTOBAN_DURATION", 3600)
 
 	// Redis
 	cfg.Redis.Host = GetEnv("REDIS_HOST", "")
@@ -83,6 +140,49 @@ func LoadConfig() *Config {
 	cfg.Redis.DB = GetEnvAsInt("REDIS_DB", 1)
 	cfg.Redis.Password = GetEnv("REDIS_PASSWORD", "")
 
+	// OTP Delivery
+	cfg.Delivery.Provider = GetEnv("OTP_DELIVERY_PROVIDER", "console")
+	cfg.Delivery.FilePath = GetEnv("OTP_DELIVERY_FILE", "")
+	cfg.Delivery.GatewayURL = GetEnv("SMS_GATEWAY_URL", "")
+	cfg.Delivery.GatewayAPIKey = GetEnv("SMS_GATEWAY_API_KEY", "")
+	cfg.Delivery.GatewaySender = GetEnv("SMS_GATEWAY_SENDER", "")
+	cfg.Delivery.GatewayTimeout = GetEnvAsInt("SMS_GATEWAY_TIMEOUT", 10)
+	cfg.Delivery.Workers = GetEnvAsInt("OTP_DELIVERY_WORKERS", 4)
+	cfg.Delivery.MaxAttempts = GetEnvAsInt("OTP_DELIVERY_MAX_ATTEMPTS", 5)
+	cfg.Delivery.RetryBaseDelay = GetEnvAsInt("OTP_DELIVERY_RETRY_DELAY", 2)
+	cfg.Delivery.RetryMaxDelay = GetEnvAsInt("OTP_DELIVERY_RETRY_MAX_DELAY", 300)
+
+	// Outbox
+	cfg.Outbox.Sinks = GetEnvAsSlice("OUTBOX_SINKS", []string{"log"})
+	cfg.Outbox.RedisChannel = GetEnv("OUTBOX_REDIS_CHANNEL", "otp-server:events")
+	cfg.Outbox.WebhookURL = GetEnv("OUTBOX_WEBHOOK_URL", "")
+
+	// Security
+	cfg.Security.OtpPepper = GetEnv("OTP_PEPPER", "")
+	if cfg.Security.OtpPepper == "" {
+		log.Fatalf("Error: OTP_PEPPER environment variable is required")
+	}
+	cfg.Security.DataEncryptionKey = GetEnv("DATA_ENCRYPTION_KEY", "")
+	if cfg.Security.DataEncryptionKey == "" {
+		log.Fatalf("Error: DATA_ENCRYPTION_KEY environment variable is required")
+	}
+	cfg.Security.TotpEncryptionKey = GetEnv("TOTP_ENCRYPTION_KEY", "")
+	if cfg.Security.TotpEncryptionKey == "" {
+		log.Fatalf("Error: TOTP_ENCRYPTION_KEY environment variable is required")
+	}
+	cfg.Security.TotpIssuer = GetEnv("TOTP_ISSUER", "OTP Server")
+
+	// Admin passwords
+	cfg.Password.MinLength = GetEnvAsInt("ADMIN_PASSWORD_MIN_LENGTH", 12)
+	cfg.Password.MinEntropy = GetEnvAsInt("ADMIN_PASSWORD_MIN_ENTROPY", 50)
+	cfg.Password.HistorySize = GetEnvAsInt("ADMIN_PASSWORD_HISTORY", 5)
+	cfg.Password.BreachedListFile = GetEnv("BREACHED_PASSWORDS_FILE", "")
+	cfg.Password.HashAlgorithm = GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	cfg.Password.Argon2Memory = GetEnvAsInt("ARGON2_MEMORY", 64*1024)
+	cfg.Password.Argon2Iterations = GetEnvAsInt("ARGON2_ITERATIONS", 3)
+	cfg.Password.Argon2Parallelism = GetEnvAsInt("ARGON2_PARALLELISM", 2)
+	cfg.Password.BcryptCost = GetEnvAsInt("BCRYPT_COST", 12)
+
 	AppConfig = cfg
 	return cfg
 }
@@ -118,3 +218,18 @@ func GetEnvAsInt64(name string, defaultVal int64) int64 {
 	}
 	return defaultVal
 }
+
+func GetEnvAsSlice(name string, defaultVal []string) []string {
+	valStr := GetEnv(name, "")
+	if valStr == "" {
+		return defaultVal
+	}
+
+	var vals []string
+	for _, v := range strings.Split(valStr, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
@@ -66,6 +66,11 @@ func Init(dbConfig config.DatabaseConfig) (*DB, error) {
 		settingRepo := repository.NewSetting()
 		db.autoMigrateAndSeedSettings(settingRepo)
 	}
+
+	// The built-in roles are only seeded here, whether the schema comes from the migrations or GORM.
+	if err = repository.NewRole().CreateBuiltin(db.DB); err != nil {
+		return nil, fmt.Errorf("error creating built-in roles: %w", err)
+	}
 	return db, nil
 }
 
@@ -82,7 +87,7 @@ func (db *DB) Stop() error {
 }
 
 func (db *DB) autoMigrateAndSeedSettings(settingRepo repository.Setting) {
-	err := db.AutoMigrate(&models.User{}, &models.UserOtp{}, &models.Setting{}, &models.Admin{})
+	err := db.AutoMigrate(&models.User{}, &models.UserOtp{}, &models.Setting{}, &models.Admin{}, &models.OtpDelivery{}, &models.OutboxEvent{}, &models.UserTotpSecret{}, &models.AdminTotpSecret{}, &models.RefreshToken{}, &models.Session{}, &models.SigningKey{}, &models.Role{}, &models.AuditEvent{}, &models.AdminPasswordHistory{})
 	if err != nil {
 		log.Fatalf("failed to auto migrate database: %v", err)
 	}
@@ -0,0 +1,19 @@
+package delivery
+
+import (
+	"context"
+	"log"
+)
+
+// ConsoleSender writes messages to the application log. Intended for local development only.
+type ConsoleSender struct{}
+
+// NewConsoleSender creates a new ConsoleSender.
+func NewConsoleSender() *ConsoleSender {
+	return &ConsoleSender{}
+}
+
+func (s *ConsoleSender) Send(_ context.Context, phoneNumber, message string, metadata map[string]string) error {
+	log.Printf("[console sender] To=%s, Message=%q, Metadata=%v", phoneNumber, message, metadata)
+	return nil
+}
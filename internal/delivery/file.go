@@ -0,0 +1,53 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileRecord struct {
+	Time        time.Time         `json:"time"`
+	PhoneNumber string            `json:"phone_number"`
+	Message     string            `json:"message"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// FileSender appends messages as JSON lines to a file. Intended for local development and testing.
+type FileSender struct {
+	mutex sync.Mutex
+	path  string
+}
+
+// NewFileSender creates a new FileSender writing to path.
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(_ context.Context, phoneNumber, message string, metadata map[string]string) error {
+	data, err := json.Marshal(fileRecord{
+		Time:        time.Now().UTC(),
+		PhoneNumber: phoneNumber,
+		Message:     message,
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return &Error{Provider: ProviderFile, Err: err}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return &Error{Provider: ProviderFile, Err: err}
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		return &Error{Provider: ProviderFile, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type httpSendRequest struct {
+	To       string            `json:"to"`
+	From     string            `json:"from,omitempty"`
+	Message  string            `json:"message"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// HTTPSender delivers messages through an SMS gateway that accepts JSON POST requests.
+type HTTPSender struct {
+	client *http.Client
+	url    string
+	apiKey string
+	from   string
+}
+
+// NewHTTPSender creates a new HTTPSender for the gateway at url.
+func NewHTTPSender(url, apiKey, from string, timeout time.Duration) *HTTPSender {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &HTTPSender{
+		client: &http.Client{Timeout: timeout},
+		url:    url,
+		apiKey: apiKey,
+		from:   from,
+	}
+}
+
+func (s *HTTPSender) Send(ctx context.Context, phoneNumber, message string, metadata map[string]string) error {
+	body, err := json.Marshal(httpSendRequest{
+		To:       phoneNumber,
+		From:     s.from,
+		Message:  message,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return &Error{Provider: ProviderHTTP, Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &Error{Provider: ProviderHTTP, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &Error{Provider: ProviderHTTP, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &Error{
+			Provider:   ProviderHTTP,
+			StatusCode: resp.StatusCode,
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/config"
+)
+
+const (
+	ProviderConsole = "console"
+	ProviderFile    = "file"
+	ProviderHTTP    = "http"
+)
+
+// Sender delivers a text message to a phone number.
+type Sender interface {
+	Send(ctx context.Context, phoneNumber, message string, metadata map[string]string) error
+}
+
+// Error is returned by senders when a message could not be delivered.
+type Error struct {
+	Provider   string
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s delivery failed with status %d: %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s delivery failed: %v", e.Provider, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether retrying the delivery may succeed.
+func (e *Error) Temporary() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// New creates the Sender selected by the delivery configuration.
+func New(cfg config.DeliveryConfig) (Sender, error) {
+	switch cfg.Provider {
+	case ProviderConsole, "":
+		return NewConsoleSender(), nil
+	case ProviderFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("OTP_DELIVERY_FILE is required for the %s provider", ProviderFile)
+		}
+		return NewFileSender(cfg.FilePath), nil
+	case ProviderHTTP:
+		if cfg.GatewayURL == "" {
+			return nil, fmt.Errorf("SMS_GATEWAY_URL is required for the %s provider", ProviderHTTP)
+		}
+		return NewHTTPSender(cfg.GatewayURL, cfg.GatewayAPIKey, cfg.GatewaySender, time.Duration(cfg.GatewayTimeout)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("unknown OTP delivery provider: %s", cfg.Provider)
+	}
+}
@@ -0,0 +1,221 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/config"
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/encryption"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+)
+
+const (
+	// sendTimeout bounds a single call to the sender.
+	sendTimeout = 30 * time.Second
+	// claimLease is how long a claimed delivery is hidden from other workers. It outlasts the send,
+	// so a delivery is only claimed again when the worker sending it stopped before recording the result.
+	claimLease = 2 * sendTimeout
+)
+
+// messageFormat is the text message carrying an OTP code.
+const messageFormat = "Your verification code is: %s"
+
+var (
+	// errOtpExpired marks deliveries of OTPs that were used or expired before they could be sent.
+	errOtpExpired = errors.New("otp expired before it could be delivered")
+	// errUnreadableCode marks deliveries whose code cannot be decrypted, which retrying does not fix.
+	errUnreadableCode = errors.New("otp code cannot be decrypted")
+)
+
+// Worker drains the OTP delivery queue with a pool of goroutines.
+// Failed deliveries are retried with exponential backoff and marked dead
+// after the configured number of attempts, on a permanent error, or once
+// their OTP can no longer be used.
+type Worker struct {
+	database     *db.DB
+	deliveryRepo repository.OtpDelivery
+	otpRepo      repository.Otp
+	appSettings  *setting.Config
+	cipher       *encryption.Cipher
+	sender       Sender
+	workers      int
+	maxAttempts  uint
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	pollInterval time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker creates a new Worker.
+func NewWorker(database *db.DB, deliveryRepo repository.OtpDelivery, otpRepo repository.Otp, appSettings *setting.Config,
+	cipher *encryption.Cipher, sender Sender, cfg config.DeliveryConfig) *Worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Worker{
+		database:     database,
+		deliveryRepo: deliveryRepo,
+		otpRepo:      otpRepo,
+		appSettings:  appSettings,
+		cipher:       cipher,
+		sender:       sender,
+		workers:      max(cfg.Workers, 1),
+		maxAttempts:  uint(max(cfg.MaxAttempts, 1)),
+		baseDelay:    time.Duration(max(cfg.RetryBaseDelay, 1)) * time.Second,
+		maxDelay:     time.Duration(max(cfg.RetryMaxDelay, 1)) * time.Second,
+		pollInterval: time.Second,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start launches the worker pool.
+func (w *Worker) Start() {
+	for i := 0; i < w.workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+	log.Printf("OTP delivery worker started with %d goroutines", w.workers)
+}
+
+// Stop signals the pool to exit and waits for in-flight deliveries to finish.
+func (w *Worker) Stop() {
+	w.cancel()
+	w.wg.Wait()
+	log.Println("OTP delivery worker stopped")
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+
+	for {
+		processed, err := w.processNext()
+		if err != nil {
+			log.Printf("OTP delivery worker error: %v", err)
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// processNext claims a single delivery, sends it and records the result. The claim is committed
+// before sending, so no transaction or row lock is held while the sender runs.
+func (w *Worker) processNext() (bool, error) {
+	d, otp, err := w.claim()
+	if err != nil || d == nil {
+		return false, err
+	}
+
+	sendErr := w.send(d)
+
+	// The result is recorded even while stopping, otherwise the delivery is sent again after its lease.
+	tx := w.database.GetTransaction(context.WithoutCancel(w.ctx))
+	if tx.Error != nil {
+		return true, tx.Error
+	}
+	defer tx.Rollback()
+
+	switch {
+	case sendErr == nil:
+		err = w.deliveryRepo.MarkSent(tx, d)
+		log.Printf("OTP delivered: OtpID=%d, Attempt=%d", d.OtpID, d.Attempts)
+	case !isTemporary(sendErr) || d.Attempts >= w.maxAttempts:
+		err = w.deliveryRepo.MarkDead(tx, d, sendErr)
+		log.Printf("OTP delivery moved to dead letter: OtpID=%d, Attempts=%d, Error=%v", d.OtpID, d.Attempts, sendErr)
+	default:
+		next := time.Now().UTC().Add(w.backoff(d.Attempts - 1))
+		if otp.Expired(w.appSettings.OtpPolicy().SessionTTL, next) {
+			err = w.deliveryRepo.MarkDead(tx, d, fmt.Errorf("%w: %v", errOtpExpired, sendErr))
+			log.Printf("OTP delivery failed and the OTP expires before a retry, moved to dead letter: OtpID=%d, Error=%v", d.OtpID, sendErr)
+			break
+		}
+		err = w.deliveryRepo.MarkFailed(tx, d, sendErr, next)
+		log.Printf("OTP delivery failed, retrying at %s: OtpID=%d, Attempt=%d, Error=%v", next.Format(time.RFC3339), d.OtpID, d.Attempts, sendErr)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	return true, tx.Commit().Error
+}
+
+// send decrypts the code of d and sends the OTP message carrying it.
+func (w *Worker) send(d *models.OtpDelivery) error {
+	code, err := w.cipher.Decrypt(d.CodeCiphertext)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnreadableCode, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return w.sender.Send(ctx, d.PhoneNumber, fmt.Sprintf(messageFormat, code), d.Metadata)
+}
+
+// claim takes the next due delivery in its own transaction. Deliveries whose OTP can no longer be
+// used are marked dead instead of being returned.
+func (w *Worker) claim() (*models.OtpDelivery, *models.UserOtp, error) {
+	tx := w.database.GetTransaction(w.ctx)
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+	defer tx.Rollback()
+
+	for {
+		d, err := w.deliveryRepo.ClaimNext(tx, claimLease)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d == nil {
+			// Commits the deliveries marked dead on the way
+			return nil, nil, tx.Commit().Error
+		}
+
+		otp, err := w.otpRepo.GetByID(tx, d.OtpID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !otp.Expired(w.appSettings.OtpPolicy().SessionTTL, time.Now().UTC()) {
+			return d, otp, tx.Commit().Error
+		}
+
+		if err = w.deliveryRepo.MarkDead(tx, d, errOtpExpired); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("OTP delivery skipped, the OTP was used or expired: OtpID=%d", d.OtpID)
+	}
+}
+
+// backoff returns the delay before the next attempt given the number of attempts already made.
+func (w *Worker) backoff(attempts uint) time.Duration {
+	delay := w.baseDelay << min(attempts, 16)
+	if delay <= 0 || delay > w.maxDelay {
+		return w.maxDelay
+	}
+	return delay
+}
+
+func isTemporary(err error) bool {
+	if errors.Is(err, errUnreadableCode) {
+		return false
+	}
+	var deliveryErr *Error
+	if errors.As(err, &deliveryErr) {
+		return deliveryErr.Temporary()
+	}
+	return true
+}
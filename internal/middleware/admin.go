@@ -11,15 +11,19 @@ import (
 
 type AdminKey struct{}
 
+type AdminRoleKey struct{}
+
 // AdminAuthenticator holds dependencies for admin authentication middleware.
 type AdminAuthenticator struct {
-	adminRepo  repository.Admin
-	jwtService *token.JWTService
+	adminRepo        repository.Admin
+	roleRepo         repository.Role
+	jwtService       *token.JWTService
+	sessionValidator *SessionValidator
 }
 
 // NewAdminAuthenticator creates a new AdminAuthenticator instance.
-func NewAdminAuthenticator(adminRepo repository.Admin, jwtService *token.JWTService) *AdminAuthenticator {
-	return &AdminAuthenticator{adminRepo: adminRepo, jwtService: jwtService}
+func NewAdminAuthenticator(adminRepo repository.Admin, roleRepo repository.Role, jwtService *token.JWTService, sessionValidator *SessionValidator) *AdminAuthenticator {
+	return &AdminAuthenticator{adminRepo: adminRepo, roleRepo: roleRepo, jwtService: jwtService, sessionValidator: sessionValidator}
 }
 
 func (a *AdminAuthenticator) Authenticate(next http.Handler) http.Handler {
@@ -30,14 +34,14 @@ func (a *AdminAuthenticator) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		if claims.Audience != token.AudianceAdmin {
+		if claims.Audience != token.AudianceAdmin || !a.sessionValidator.Validate(r, claims) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		tx := GetTxFromRequest(r)
 		admin, err := a.adminRepo.GetByID(tx, claims.ID)
-		if err != nil {
+		if err != nil || admin == nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -58,22 +62,32 @@ func (a *AdminAuthenticator) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		role, err := a.roleRepo.GetByID(tx, uint(admin.Role))
+		if err != nil || role == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), AdminKey{}, admin)
-		r = r.WithContext(ctx)
+		ctx = context.WithValue(ctx, AdminRoleKey{}, role)
+		r = withSessionID(r.WithContext(ctx), claims)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (a *AdminAuthenticator) AuthorizeSudo(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		admin := GetAdminFromRequest(r)
-		if admin == nil || (admin.Role > models.RoleSudoAdmin) {
-			http.Error(w, "Forbidden: Insufficient privileges", http.StatusForbidden)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// RequirePermission only lets through admins whose role grants every permission in perms.
+func (a *AdminAuthenticator) RequirePermission(perms ...models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := GetAdminRoleFromRequest(r)
+			if role == nil || !role.HasPermissions(perms...) {
+				http.Error(w, "Forbidden: Insufficient privileges", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func GetAdminFromContext(ctx context.Context) *models.Admin {
@@ -86,3 +100,11 @@ func GetAdminFromContext(ctx context.Context) *models.Admin {
 func GetAdminFromRequest(r *http.Request) *models.Admin {
 	return GetAdminFromContext(r.Context())
 }
+
+// GetAdminRoleFromRequest returns the role of the authenticated admin.
+func GetAdminRoleFromRequest(r *http.Request) *models.Role {
+	if role, ok := r.Context().Value(AdminRoleKey{}).(*models.Role); ok {
+		return role
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type ClientIPKey struct{}
+
+// Forwarding headers a ClientIPResolver can read the client address from.
+const (
+	ProxyHeaderXForwardedFor = "x-forwarded-for"
+	ProxyHeaderForwarded     = "forwarded"
+	ProxyHeaderXRealIP       = "x-real-ip"
+)
+
+// ClientIPResolver finds the address of the client behind trusted reverse proxies. The forwarding header
+// is honored only when the connection comes from a trusted proxy, and only up to the first hop that is
+// not one, so clients cannot choose their address by sending the header themselves. Only the header the
+// proxies are configured to set is read; a client could send any other one through them unchanged.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewClientIPResolver creates a resolver trusting trustedProxies, given as CIDRs or single addresses,
+// to set header, one of the ProxyHeader constants. With no trusted proxies the connection address is
+// always used.
+func NewClientIPResolver(trustedProxies []string, header string) (*ClientIPResolver, error) {
+	header = strings.ToLower(strings.TrimSpace(header))
+	switch header {
+	case ProxyHeaderXForwardedFor, ProxyHeaderForwarded, ProxyHeaderXRealIP:
+	default:
+		return nil, fmt.Errorf("unsupported trusted proxy header %q: use %s, %s or %s",
+			header, ProxyHeaderXForwardedFor, ProxyHeaderForwarded, ProxyHeaderXRealIP)
+	}
+
+	resolver := &ClientIPResolver{header: header}
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+// Middleware stores the client address in the request context and RemoteAddr, so rate limiting, the
+// audit log and request logging all see the same value. It must run before chi's Logger.
+func (c *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := c.Resolve(r)
+		r.RemoteAddr = ip
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClientIPKey{}, ip)))
+	})
+}
+
+// Resolve returns the client address of r. It walks the proxy chain of the configured forwarding header
+// from the nearest hop and returns the first address that is not a trusted proxy.
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	remote, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch c.header {
+	case ProxyHeaderForwarded:
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	case ProxyHeaderXForwardedFor:
+		hops = splitList(r.Header.Values("X-Forwarded-For"))
+	case ProxyHeaderXRealIP:
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			hops = []string{realIP}
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// Unknown or obfuscated hops cannot be checked, so the chain is not followed past them
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the client address of r resolved by ClientIPResolver.Middleware, or the
+// connection address when the middleware did not run.
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey{}).(string); ok {
+		return ip
+	}
+	if addr, ok := parseHop(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// parseHop parses an address that may carry a port, and brackets for IPv6, as found in RemoteAddr and
+// forwarding headers.
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// forwardedFor returns the for= parameters of RFC 7239 Forwarded headers, in order from the client.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		hop := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		// Elements without for= are kept as unknown hops
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// splitList splits comma separated header values into their elements.
+func splitList(values []string) []string {
+	var elements []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			elements = append(elements, strings.TrimSpace(element))
+		}
+	}
+	return elements
+}
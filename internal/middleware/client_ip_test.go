@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.0.2.1"}
+
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "untrusted connection ignores the header",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "203.0.113.7:4000",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "203.0.113.7",
+		},
+		{
+			name:   "trusted connection without the header",
+			header: ProxyHeaderXForwardedFor,
+			remote: "10.0.0.1:4000",
+			want:   "10.0.0.1",
+		},
+		{
+			name:    "nearest untrusted hop is the client",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "hops left of the first untrusted one are ignored",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 192.0.2.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "chain of trusted proxies ends at the leftmost hop",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "walk stops at an unparsable hop",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "other forwarding headers are ignored",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": "for=6.6.6.6", "X-Real-IP": "6.6.6.6", "X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "forwarded header with IPv6 and ports",
+			header:  ProxyHeaderForwarded,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`, "X-Forwarded-For": "6.6.6.6"},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "x-real-ip header",
+			header:  ProxyHeaderXRealIP,
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Real-IP": "198.51.100.1", "X-Forwarded-For": "6.6.6.6"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "IPv4-mapped connection address",
+			header:  ProxyHeaderXForwardedFor,
+			remote:  "[::ffff:10.0.0.1]:4000",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewClientIPResolver(proxies, tt.header)
+			if err != nil {
+				t.Fatalf("NewClientIPResolver: %v", err)
+			}
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolverRejectsUnknownHeader(t *testing.T) {
+	if _, err := NewClientIPResolver(nil, "x-client-ip"); err == nil {
+		t.Fatal("NewClientIPResolver accepted an unsupported header")
+	}
+	if _, err := NewClientIPResolver(nil, " X-Forwarded-For "); err != nil {
+		t.Fatalf("NewClientIPResolver: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/config"
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+type AllowListedKey struct{}
+
+// AutoBanCreator is the CreatedBy of deny list entries added by automatic bans.
+const AutoBanCreator = "auto-ban"
+
+type ipRule struct {
+	network   netip.Prefix
+	expiresAt *time.Time
+}
+
+// IPFilter rejects clients on the deny list and exempts clients on the allow list from rate limiting.
+// The lists live in Redis; each instance keeps a copy and reloads it when the lists change.
+type IPFilter struct {
+	redisCli *redis.Config
+	ban      config.IPBanConfig
+
+	mu      sync.RWMutex
+	version int64
+	allow   []ipRule
+	deny    []ipRule
+}
+
+// NewIPFilter creates a new IPFilter.
+func NewIPFilter(redisCli *redis.Config, ban config.IPBanConfig) *IPFilter {
+	return &IPFilter{redisCli: redisCli, ban: ban, version: -1}
+}
+
+// Filter checks the client address against the lists. It must run after ClientIPResolver.Middleware
+// and before any rate limiter. If the lists cannot be read, the last loaded copy is used.
+func (f *IPFilter) Filter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, err := netip.ParseAddr(GetClientIP(r))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err = f.refresh(r.Context()); err != nil {
+			log.Printf("Failed to reload IP lists: %v", err)
+		}
+
+		f.mu.RLock()
+		allowed, denied := matches(f.allow, addr), matches(f.deny, addr)
+		f.mu.RUnlock()
+
+		switch {
+		case allowed:
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), AllowListedKey{}, true)))
+		case denied:
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// IsAllowListed reports whether the client of r is on the allow list.
+func IsAllowListed(r *http.Request) bool {
+	allowed, _ := r.Context().Value(AllowListedKey{}).(bool)
+	return allowed
+}
+
+// RecordViolation counts a rejection of the client of r by the limiter named prefix, and puts the
+// address on the deny list for the configured duration once it reaches the threshold.
+func (f *IPFilter) RecordViolation(r *http.Request, prefix string) {
+	if f.ban.Threshold <= 0 {
+		return
+	}
+
+	ip := GetClientIP(r)
+	ctx := context.WithoutCancel(r.Context())
+	count, err := f.redisCli.RecordIPViolation(ctx, ip, time.Duration(f.ban.Window)*time.Second)
+	if err != nil {
+		log.Printf("Failed to record rate limit violation for %s: %v", ip, err)
+		return
+	}
+	if count < int64(f.ban.Threshold) {
+		return
+	}
+
+	_, network, err := redis.ParseNetwork(ip)
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(f.ban.Duration) * time.Second)
+	entry := redis.IPListEntry{
+		Network:   network,
+		Reason:    fmt.Sprintf("rejected by the %s rate limit %d times", prefix, count),
+		CreatedBy: AutoBanCreator,
+		CreatedAt: now,
+		ExpiresAt: &expiresAt,
+	}
+	if err = f.redisCli.AddIPListEntry(ctx, redis.IPDenyList, entry); err != nil {
+		log.Printf("Failed to ban %s: %v", ip, err)
+		return
+	}
+	if err = f.redisCli.ResetIPViolations(ctx, ip); err != nil {
+		log.Printf("Failed to reset rate limit violations for %s: %v", ip, err)
+	}
+	log.Printf("Banned %s until %s: %s", network, expiresAt.Format(time.RFC3339), entry.Reason)
+}
+
+// refresh reloads the lists when their version changed since the last load.
+func (f *IPFilter) refresh(ctx context.Context) error {
+	version, err := f.redisCli.GetIPListVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	current := f.version == version
+	f.mu.RUnlock()
+	if current {
+		return nil
+	}
+
+	allow, err := f.loadRules(ctx, redis.IPAllowList)
+	if err != nil {
+		return err
+	}
+	deny, err := f.loadRules(ctx, redis.IPDenyList)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.version, f.allow, f.deny = version, allow, deny
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *IPFilter) loadRules(ctx context.Context, list redis.IPList) ([]ipRule, error) {
+	entries, err := f.redisCli.GetIPListEntries(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]ipRule, 0, len(entries))
+	for _, entry := range entries {
+		network, _, err := redis.ParseNetwork(entry.Network)
+		if err != nil {
+			log.Printf("Skipping %s list entry: %v", list, err)
+			continue
+		}
+		rules = append(rules, ipRule{network: network, expiresAt: entry.ExpiresAt})
+	}
+	return rules, nil
+}
+
+// matches reports whether addr is in any rule that has not expired.
+func matches(rules []ipRule, addr netip.Addr) bool {
+	now := time.Now()
+	addr = addr.Unmap()
+	for _, rule := range rules {
+		if rule.expiresAt != nil && !rule.expiresAt.After(now) {
+			continue
+		}
+		if rule.network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
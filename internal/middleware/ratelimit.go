@@ -1,7 +1,8 @@
 package middleware
 
 import (
-	"net"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,46 +20,65 @@ func NewRateLimiter(redisCli *redis.Config) *RateLimiter {
 	}
 }
 
-func getClientIP(r *http.Request, allowForwarded bool) string {
-	if allowForwarded {
-		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-			if ip := net.ParseIP(xff); ip != nil {
-				return ip.String()
-			}
-		}
-
-		if xri := r.Header.Get("X-Real-IP"); xri != "" {
-			if ip := net.ParseIP(xri); ip != nil {
-				return ip.String()
-			}
-		}
-	}
+// RateLimit allows each client address maxRequests per windowSeconds, counted with algorithm.
+// Rejected requests get a Retry-After header telling when the next request will be allowed.
+func (rl *RateLimiter) RateLimit(prefix string, algorithm redis.RateLimitAlgorithm, maxRequests int, windowSeconds int) func(http.Handler) http.Handler {
+	return rl.limit(prefix, clientIPKey, true, algorithm, maxRequests, windowSeconds, nil)
+}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
+// RateLimitWithBan is RateLimit that also reports rejected addresses to filter, which bans an address
+// for a while once it is rejected too often.
+func (rl *RateLimiter) RateLimitWithBan(prefix string, algorithm redis.RateLimitAlgorithm, maxRequests int, windowSeconds int, filter *IPFilter) func(http.Handler) http.Handler {
+	return rl.limit(prefix, clientIPKey, true, algorithm, maxRequests, windowSeconds, func(r *http.Request) {
+		filter.RecordViolation(r, prefix)
+	})
+}
 
-	return ip
+// RateLimitBy allows maxRequests per windowSeconds for each value returned by keyFunc, such as a
+// phone number, regardless of the client address. It composes with RateLimit: a request must pass
+// every limiter on its route. Requests keyFunc finds no value for share one limit, so leaving the
+// value out does not get around it. Allow-listed clients are limited too, as the limit protects the
+// target rather than the server.
+func (rl *RateLimiter) RateLimitBy(prefix string, keyFunc KeyFunc, algorithm redis.RateLimitAlgorithm, maxRequests int, windowSeconds int) func(http.Handler) http.Handler {
+	return rl.limit(prefix, keyFunc, false, algorithm, maxRequests, windowSeconds, nil)
 }
 
-func (rl *RateLimiter) RateLimit(prefix string, maxRequests int, windowSeconds int, allowForwarded bool) func(http.Handler) http.Handler {
+// limit builds the rate limiting middleware. Allow-listed clients are not limited when exemptAllowListed
+// is set. onReject, when not nil, is called for every rejected request.
+func (rl *RateLimiter) limit(prefix string, keyFunc KeyFunc, exemptAllowListed bool, algorithm redis.RateLimitAlgorithm, maxRequests int, windowSeconds int, onReject func(r *http.Request)) func(http.Handler) http.Handler {
+	if !algorithm.IsValid() {
+		log.Fatalf("Unknown rate limit algorithm for %s: %s", prefix, algorithm)
+	}
+	window := time.Duration(windowSeconds) * time.Second
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := getClientIP(r, allowForwarded)
-			key := rl.redisCli.GetRateLimitKey(prefix, clientIP)
+			if exemptAllowListed && IsAllowListed(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identifier, ok := keyFunc(r)
+			if !ok {
+				identifier = unkeyedIdentifier
+			}
+			key := rl.redisCli.GetRateLimitKey(algorithm, prefix, identifier)
 
-			allowed, remaining, err := rl.redisCli.CheckRateLimit(r.Context(), key, maxRequests, windowSeconds)
+			result, err := rl.redisCli.CheckRateLimit(r.Context(), algorithm, key, maxRequests, window)
 			if err != nil {
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
 			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(maxRequests))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Duration(windowSeconds)*time.Second).Unix(), 10))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(result.ResetAt.UnixMilli())/1000)), 10))
 
-			if !allowed {
+			if !result.Allowed {
+				if onReject != nil {
+					onReject(r)
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxKeyBodySize is the most of a request body read to find a rate limit key. Larger bodies have no key.
+const maxKeyBodySize = 64 << 10
+
+// KeyFunc returns the value a rate limiter counts a request by, or false when the request has none.
+type KeyFunc func(r *http.Request) (string, bool)
+
+// unkeyedIdentifier is what requests without a key are counted by, so they share one limit.
+const unkeyedIdentifier = "unkeyed"
+
+func clientIPKey(r *http.Request) (string, bool) {
+	return GetClientIP(r), true
+}
+
+// BodyField returns a KeyFunc decoding a JSON request body into T, the request type of the handler,
+// and reading the key with field, passed through normalize when it is not nil. Decoding the body the
+// way the handler does means both see the same value, whatever the case of its name. The body is
+// restored, so handlers can still decode it. Values are hashed, so phone numbers and tokens are not
+// stored in Redis keys.
+func BodyField[T any](field func(req *T) string, normalize func(string) string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		body, err := peekBody(r)
+		if err != nil {
+			return "", false
+		}
+
+		var req T
+		if err = json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+			return "", false
+		}
+		value := field(&req)
+
+		if normalize != nil {
+			value = normalize(value)
+		}
+		if value == "" {
+			return "", false
+		}
+		return hashKey(value), true
+	}
+}
+
+// BearerToken is a KeyFunc returning the bearer token of the Authorization header, such as the login
+// session token sent to /auth/verify-otp.
+func BearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return hashKey(token), true
+}
+
+// NormalizePhoneNumber strips the formatting characters people type in phone numbers and turns a
+// leading international 00 into +, so variants of one number share a limit.
+func NormalizePhoneNumber(phoneNumber string) string {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(phoneNumber) {
+		switch {
+		case c >= '0' && c <= '9', c == '+' && b.Len() == 0:
+			b.WriteRune(c)
+		case c == ' ', c == '-', c == '.', c == '(', c == ')':
+		default:
+			// Not a phone number; keep it whole so it is still counted
+			return phoneNumber
+		}
+	}
+
+	normalized := b.String()
+	if rest, ok := strings.CutPrefix(normalized, "00"); ok {
+		normalized = "+" + rest
+	}
+	return normalized
+}
+
+// NormalizeUsername folds case and surrounding spaces of a username.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// peekBody returns up to maxKeyBodySize bytes of the request body and puts everything back in front of
+// the unread remainder.
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, io.EOF
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxKeyBodySize+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxKeyBodySize {
+		return nil, io.ErrShortBuffer
+	}
+	return body, nil
+}
+
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:16])
+}
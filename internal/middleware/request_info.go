@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	cMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type RequestInfoKey struct{}
+
+// maxUserAgentLength caps the user agent kept for audit events.
+const maxUserAgentLength = 255
+
+// RequestInfo describes the client of a request for the audit log.
+type RequestInfo struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+// WithRequestInfo stores the client address, user agent and request ID in the request context.
+// It must run after chi's RequestID middleware and ClientIPResolver.Middleware, and before Transaction, so the transaction
+// context carries the information too.
+func WithRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent := r.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = userAgent[:maxUserAgentLength]
+		}
+
+		info := RequestInfo{
+			IPAddress: GetClientIP(r),
+			UserAgent: userAgent,
+			RequestID: cMiddleware.GetReqID(r.Context()),
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), RequestInfoKey{}, info)))
+	})
+}
+
+// GetRequestInfoFromContext returns the request information stored by WithRequestInfo, or the zero
+// value outside of a request.
+func GetRequestInfoFromContext(ctx context.Context) RequestInfo {
+	if ctx == nil {
+		return RequestInfo{}
+	}
+	if info, ok := ctx.Value(RequestInfoKey{}).(RequestInfo); ok {
+		return info
+	}
+	return RequestInfo{}
+}
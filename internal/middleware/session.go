@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/token"
+)
+
+type SessionKey struct{}
+
+// sessionCacheTTL bounds how long an active session is trusted from Redis without checking the database.
+const sessionCacheTTL = time.Minute
+
+// SessionValidator checks that the session an access token belongs to has not been revoked.
+type SessionValidator struct {
+	sessionRepo repository.Session
+	redisCli    *redis.Config
+}
+
+// NewSessionValidator creates a new SessionValidator instance.
+func NewSessionValidator(sessionRepo repository.Session, redisCli *redis.Config) *SessionValidator {
+	return &SessionValidator{sessionRepo: sessionRepo, redisCli: redisCli}
+}
+
+// Validate reports whether the session of claims is still active. Redis answers first;
+// on a cache miss or a Redis failure the database is consulted.
+func (v *SessionValidator) Validate(r *http.Request, claims *token.Claims) bool {
+	jti := claims.SessionID()
+	if jti == "" {
+		return false
+	}
+
+	state, err := v.redisCli.GetSessionState(r.Context(), jti)
+	if err != nil {
+		log.Printf("Failed to read cached session state: %v", err)
+	}
+	switch state {
+	case redis.SessionStateActive:
+		return true
+	case redis.SessionStateRevoked:
+		return false
+	}
+
+	session, err := v.sessionRepo.GetByJti(GetTxFromRequest(r), jti)
+	if err != nil || session == nil {
+		return false
+	}
+	if session.SubjectID != claims.ID || session.Audience != claims.Audience.Int() || !session.IsActive() {
+		return false
+	}
+
+	if err = v.redisCli.CacheActiveSession(r.Context(), jti, sessionCacheTTL); err != nil {
+		log.Printf("Failed to cache session state: %v", err)
+	}
+	return true
+}
+
+func withSessionID(r *http.Request, claims *token.Claims) *http.Request {
+	ctx := context.WithValue(r.Context(), SessionKey{}, claims.SessionID())
+	return r.WithContext(ctx)
+}
+
+// GetSessionIDFromRequest returns the jti of the access token that authenticated r.
+func GetSessionIDFromRequest(r *http.Request) string {
+	if jti, ok := r.Context().Value(SessionKey{}).(string); ok {
+		return jti
+	}
+	return ""
+}
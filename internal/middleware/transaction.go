@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"sync"
 
 	"gorm.io/gorm"
 
@@ -12,6 +13,31 @@ import (
 
 type TransactionKey struct{}
 
+type afterCommitKey struct{}
+
+// afterCommitHooks collects the functions to run once a request transaction has committed.
+type afterCommitHooks struct {
+	mutex sync.Mutex
+	hooks []func()
+}
+
+func (h *afterCommitHooks) add(fn func()) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+func (h *afterCommitHooks) run() {
+	h.mutex.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mutex.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -35,8 +61,9 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 func Transaction(database *db.DB) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Start a new database transaction using the request context
-			tx := database.GetTransaction(r.Context())
+			// Start a new database transaction using the request context, which carries the after-commit hooks
+			hooks := &afterCommitHooks{}
+			tx := database.GetTransaction(context.WithValue(r.Context(), afterCommitKey{}, hooks))
 			if tx.Error != nil {
 				log.Printf("Failed to begin transaction: %v", tx.Error)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -75,6 +102,7 @@ func Transaction(database *db.DB) func(next http.Handler) http.Handler {
 				}
 				log.Printf("Transaction committed successfully for %s %s (status: %d)",
 					r.Method, r.URL.Path, rw.statusCode)
+				hooks.run()
 			} else {
 				// Error: rollback the transaction
 				if err := tx.Rollback().Error; err != nil {
@@ -102,3 +130,13 @@ func GetTxFromContext(ctx context.Context) *gorm.DB {
 func GetTxFromRequest(r *http.Request) *gorm.DB {
 	return GetTxFromContext(r.Context())
 }
+
+// AfterCommit runs fn once the request transaction tx belongs to has committed, and never if it rolls back.
+// Outside a request transaction fn runs right away.
+func AfterCommit(tx *gorm.DB, fn func()) {
+	if hooks, ok := tx.Statement.Context.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		hooks.add(fn)
+		return
+	}
+	fn()
+}
@@ -13,13 +13,14 @@ type UserKey struct{}
 
 // Authenticator holds dependencies for user authentication middleware.
 type Authenticator struct {
-	userRepo   repository.User
-	jwtService *token.JWTService
+	userRepo         repository.User
+	jwtService       *token.JWTService
+	sessionValidator *SessionValidator
 }
 
 // NewAuthenticator creates a new Authenticator instance.
-func NewAuthenticator(userRepo repository.User, jwtService *token.JWTService) *Authenticator {
-	return &Authenticator{userRepo: userRepo, jwtService: jwtService}
+func NewAuthenticator(userRepo repository.User, jwtService *token.JWTService, sessionValidator *SessionValidator) *Authenticator {
+	return &Authenticator{userRepo: userRepo, jwtService: jwtService, sessionValidator: sessionValidator}
 }
 
 func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
@@ -30,7 +31,7 @@ func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		if claims.Audience != token.AudianceUser {
+		if claims.Audience != token.AudianceUser || !a.sessionValidator.Validate(r, claims) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -48,7 +49,7 @@ func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), UserKey{}, user)
-		r = r.WithContext(ctx)
+		r = withSessionID(r.WithContext(ctx), claims)
 
 		next.ServeHTTP(w, r)
 	})
@@ -1,11 +1,18 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/phone"
 )
 
 type UserStatus int
@@ -40,10 +47,16 @@ type User struct {
 
 type UserOtp struct {
 	gorm.Model
-	Code   string       `gorm:"not null"`
-	UserID uint         `gorm:"not null"`
-	User   *User        `gorm:"foreignkey:UserID;not null"`
-	UsedAt sql.NullTime `gorm:""`
+	CodeHash string       `gorm:"not null"`
+	UserID   uint         `gorm:"not null"`
+	User     *User        `gorm:"foreignkey:UserID;not null"`
+	UsedAt   sql.NullTime `gorm:""`
+}
+
+// Expired reports whether the OTP can no longer be verified at now, because it was used or its login
+// session, which starts when the OTP is issued, has ended.
+func (o UserOtp) Expired(sessionTTL time.Duration, now time.Time) bool {
+	return o.UsedAt.Valid || !now.Before(o.CreatedAt.Add(sessionTTL))
 }
 
 func (o UserOtp) Waste(tx *gorm.DB) error {
@@ -52,10 +65,472 @@ func (o UserOtp) Waste(tx *gorm.DB) error {
 	return tx.Save(o).Error
 }
 
+// UserTotpSecret holds a user's encrypted authenticator app secret.
+type UserTotpSecret struct {
+	gorm.Model
+	UserID          uint         `gorm:"not null;uniqueIndex"`
+	User            *User        `gorm:"foreignkey:UserID"`
+	EncryptedSecret string       `gorm:"not null"`
+	ConfirmedAt     sql.NullTime `gorm:""`
+	LastUsedStep    int64        `gorm:"not null;default:0"`
+}
+
+type OtpDeliveryStatus int
+
+const (
+	OtpDeliveryPending OtpDeliveryStatus = iota + 1
+	OtpDeliverySent
+	OtpDeliveryDead
+)
+
+func (s OtpDeliveryStatus) String() string {
+	switch s {
+	case OtpDeliveryPending:
+		return "Pending"
+	case OtpDeliverySent:
+		return "Sent"
+	case OtpDeliveryDead:
+		return "Dead"
+	default:
+		return "Unknown"
+	}
+}
+
+// OtpDelivery is a queued OTP message, written in the same transaction as its UserOtp.
+type OtpDelivery struct {
+	gorm.Model
+	OtpID          uint              `gorm:"not null;uniqueIndex"`
+	Otp            *UserOtp          `gorm:"foreignkey:OtpID"`
+	PhoneNumber    string            `gorm:"not null"`
+	CodeCiphertext string            `gorm:"not null;default:''"` // Encrypted OTP code, cleared once the delivery is finished
+	Metadata       map[string]string `gorm:"serializer:json"`
+	Status         OtpDeliveryStatus `gorm:"not null;default:1;index:idx_otp_deliveries_due,priority:1"`
+	Attempts       uint              `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time         `gorm:"not null;index:idx_otp_deliveries_due,priority:2"`
+	LastError      string
+	SentAt         sql.NullTime
+}
+
+const (
+	EventOtpRequested = "otp.requested"
+	EventOtpVerified  = "otp.verified"
+	EventUserDisabled = "user.disabled"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change it describes.
+// The relay publishes it to every registered sink once the transaction has committed.
+type OutboxEvent struct {
+	ID            uint      `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"not null"`
+	Type          string    `gorm:"not null;index"`
+	Payload       string    `gorm:"not null"`
+	DeliveredTo   []string  `gorm:"serializer:json"`
+	Attempts      uint      `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"`
+	LastError     string
+	PublishedAt   sql.NullTime `gorm:"index"`
+}
+
 type Setting struct {
-	ID                uint   `gorm:"primaryKey"`
-	SecretKey         string `gorm:"not null"`
-	AccessTokenExpire uint   `gorm:"not null"` // Minutes
+	ID                    uint                `gorm:"primaryKey"`
+	Version               uint64              `gorm:"not null;default:1"` // Incremented on every change
+	SecretKey             string              `gorm:"not null"`
+	SecretKeyID           string              `gorm:"not null;default:''"` // kid header of HS256 tokens
+	PreviousSecretKeys    []PreviousSecretKey `gorm:"serializer:json"`
+	SecretKeyGracePeriod  uint                `gorm:"not null;default:1440"`  // Minutes
+	AccessTokenExpire     uint                `gorm:"not null"`               // Minutes
+	RefreshTokenExpire    uint                `gorm:"not null;default:43200"` // Minutes
+	OtpLength             uint                `gorm:"not null;default:6"`
+	OtpNumericOnly        bool                `gorm:"not null;default:false"`
+	OtpMaxAttempts        uint                `gorm:"not null;default:3"`
+	OtpSessionTTL         uint                `gorm:"not null;default:180"` // Seconds
+	OtpCooldown           uint                `gorm:"not null;default:120"` // Seconds
+	OtpThrottleTiers      []OtpThrottleTier   `gorm:"serializer:json"`
+	AdminMfaRequired      bool                `gorm:"not null;default:false"`
+	AdminLockoutAttempts  uint                `gorm:"not null;default:10"`    // Failed logins before the username is locked
+	AdminLockoutDuration  uint                `gorm:"not null;default:900"`   // Seconds
+	AdminLoginFreeTries   uint                `gorm:"not null;default:3"`     // Failed logins before delays start
+	AdminLoginMaxDelay    uint                `gorm:"not null;default:30"`    // Seconds
+	SmsAllowedCountries   []string            `gorm:"serializer:json"`        // Calling codes or longer prefixes OTPs may be sent to, all when empty
+	SmsDeniedCountries    []string            `gorm:"serializer:json"`        // Calling codes or longer prefixes OTPs are never sent to
+	SmsCountryHourlyLimit uint                `gorm:"not null;default:200"`   // OTP SMS per calling code per hour, 0 disables
+	SmsPrefixHourlyLimit  uint                `gorm:"not null;default:20"`    // OTP SMS per number prefix per hour, 0 disables
+	SmsPrefixLength       uint                `gorm:"not null;default:7"`     // Leading digits, calling code included, forming a prefix
+	SmsSequentialLimit    uint                `gorm:"not null;default:5"`     // Distinct numbers in a block of 100 per window, 0 disables
+	SmsSequentialWindow   uint                `gorm:"not null;default:600"`   // Seconds
+	SmsCost               uint                `gorm:"not null;default:1"`     // Cost units of an SMS to a country without its own cost
+	SmsCountryCosts       map[string]uint     `gorm:"serializer:json"`        // Cost units by calling code or longer prefix
+	SmsDailySpendCap      uint                `gorm:"not null;default:10000"` // Cost units per UTC day, 0 disables
+}
+
+// PreviousSecretKey is a rotated-out secret that still verifies tokens with its kid until ExpiresAt.
+type PreviousSecretKey struct {
+	Kid       string    `json:"kid"`
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RotateSecretKey makes key the primary secret. The replaced secret keeps verifying tokens for grace;
+// previous secrets whose grace period has ended are dropped.
+func (s *Setting) RotateSecretKey(kid, key string, grace time.Duration) {
+	now := time.Now().UTC()
+
+	previous := make([]PreviousSecretKey, 0, len(s.PreviousSecretKeys)+1)
+	if grace > 0 {
+		previous = append(previous, PreviousSecretKey{Kid: s.SecretKeyID, Key: s.SecretKey, ExpiresAt: now.Add(grace)})
+	}
+	for _, p := range s.PreviousSecretKeys {
+		if p.ExpiresAt.After(now) {
+			previous = append(previous, p)
+		}
+	}
+
+	s.SecretKey = key
+	s.SecretKeyID = kid
+	s.PreviousSecretKeys = previous
+}
+
+// OtpThrottleTier allows at most Limit OTPs per user within a sliding Window.
+type OtpThrottleTier struct {
+	Window uint `json:"window"` // Seconds
+	Limit  uint `json:"limit"`
+}
+
+// OtpThrottlePolicy limits how often a user can be issued an OTP.
+type OtpThrottlePolicy struct {
+	Cooldown time.Duration // Minimum time between unused OTPs
+	Tiers    []OtpThrottleTier
+}
+
+// DefaultOtpThrottleTiers are used when the settings do not define any tiers.
+var DefaultOtpThrottleTiers = []OtpThrottleTier{
+	{Window: 600, Limit: 3},
+	{Window: 86400, Limit: 10},
+}
+
+// OtpThrottlePolicy builds the OTP throttling policy from the settings, ignoring invalid tiers.
+func (s *Setting) OtpThrottlePolicy() OtpThrottlePolicy {
+	tiers := make([]OtpThrottleTier, 0, len(s.OtpThrottleTiers))
+	for _, tier := range s.OtpThrottleTiers {
+		if tier.Window > 0 && tier.Limit > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	if len(tiers) == 0 {
+		tiers = DefaultOtpThrottleTiers
+	}
+	return OtpThrottlePolicy{
+		Cooldown: time.Duration(s.OtpCooldown) * time.Second,
+		Tiers:    tiers,
+	}
+}
+
+const (
+	MinOtpLength = 4
+	MaxOtpLength = 10
+)
+
+// Bounds enforced on settings changed at runtime.
+const (
+	MinAccessTokenExpire    = 5      // Minutes
+	MaxAccessTokenExpire    = 10080  // Minutes, one week
+	MaxRefreshTokenExpire   = 525600 // Minutes, one year
+	MaxSecretKeyGracePeriod = 10080  // Minutes
+	MaxOtpAttempts          = 10
+	MinOtpSessionTTL        = 30   // Seconds
+	MaxOtpSessionTTL        = 3600 // Seconds
+	MaxOtpCooldown          = 3600 // Seconds
+	MaxOtpThrottleTiers     = 10
+	MaxOtpThrottleWindow    = 604800 // Seconds, one week
+	MaxAdminLockoutAttempts = 100
+	MinAdminLockoutDuration = 60    // Seconds
+	MaxAdminLockoutDuration = 86400 // Seconds
+	MaxAdminLoginMaxDelay   = 300   // Seconds
+	MaxSmsCountries         = 300
+	MinSmsPrefixLength      = 4
+	MaxSmsPrefixLength      = 12
+	MaxSmsSequentialWindow  = 86400 // Seconds
+)
+
+// SettingUpdate struct for partial updates of the settings. The secret key is rotated separately
+// and MFA enforcement goes through its own policy check, so neither is part of it.
+type SettingUpdate struct {
+	AccessTokenExpire     *uint
+	RefreshTokenExpire    *uint
+	SecretKeyGracePeriod  *uint
+	OtpLength             *uint
+	OtpNumericOnly        *bool
+	OtpMaxAttempts        *uint
+	OtpSessionTTL         *uint
+	OtpCooldown           *uint
+	OtpThrottleTiers      []OtpThrottleTier
+	AdminLockoutAttempts  *uint
+	AdminLockoutDuration  *uint
+	AdminLoginFreeTries   *uint
+	AdminLoginMaxDelay    *uint
+	SmsAllowedCountries   []string
+	SmsDeniedCountries    []string
+	SmsCountryHourlyLimit *uint
+	SmsPrefixHourlyLimit  *uint
+	SmsPrefixLength       *uint
+	SmsSequentialLimit    *uint
+	SmsSequentialWindow   *uint
+	SmsCost               *uint
+	SmsCountryCosts       map[string]uint
+	SmsDailySpendCap      *uint
+}
+
+// ApplyTo copies the set fields of u onto s.
+func (u SettingUpdate) ApplyTo(s *Setting) {
+	if u.AccessTokenExpire != nil {
+		s.AccessTokenExpire = *u.AccessTokenExpire
+	}
+	if u.RefreshTokenExpire != nil {
+		s.RefreshTokenExpire = *u.RefreshTokenExpire
+	}
+	if u.SecretKeyGracePeriod != nil {
+		s.SecretKeyGracePeriod = *u.SecretKeyGracePeriod
+	}
+	if u.OtpLength != nil {
+		s.OtpLength = *u.OtpLength
+	}
+	if u.OtpNumericOnly != nil {
+		s.OtpNumericOnly = *u.OtpNumericOnly
+	}
+	if u.OtpMaxAttempts != nil {
+		s.OtpMaxAttempts = *u.OtpMaxAttempts
+	}
+	if u.OtpSessionTTL != nil {
+		s.OtpSessionTTL = *u.OtpSessionTTL
+	}
+	if u.OtpCooldown != nil {
+		s.OtpCooldown = *u.OtpCooldown
+	}
+	if u.OtpThrottleTiers != nil {
+		s.OtpThrottleTiers = u.OtpThrottleTiers
+	}
+	if u.AdminLockoutAttempts != nil {
+		s.AdminLockoutAttempts = *u.AdminLockoutAttempts
+	}
+	if u.AdminLockoutDuration != nil {
+		s.AdminLockoutDuration = *u.AdminLockoutDuration
+	}
+	if u.AdminLoginFreeTries != nil {
+		s.AdminLoginFreeTries = *u.AdminLoginFreeTries
+	}
+	if u.AdminLoginMaxDelay != nil {
+		s.AdminLoginMaxDelay = *u.AdminLoginMaxDelay
+	}
+	if u.SmsAllowedCountries != nil {
+		s.SmsAllowedCountries = u.SmsAllowedCountries
+	}
+	if u.SmsDeniedCountries != nil {
+		s.SmsDeniedCountries = u.SmsDeniedCountries
+	}
+	if u.SmsCountryHourlyLimit != nil {
+		s.SmsCountryHourlyLimit = *u.SmsCountryHourlyLimit
+	}
+	if u.SmsPrefixHourlyLimit != nil {
+		s.SmsPrefixHourlyLimit = *u.SmsPrefixHourlyLimit
+	}
+	if u.SmsPrefixLength != nil {
+		s.SmsPrefixLength = *u.SmsPrefixLength
+	}
+	if u.SmsSequentialLimit != nil {
+		s.SmsSequentialLimit = *u.SmsSequentialLimit
+	}
+	if u.SmsSequentialWindow != nil {
+		s.SmsSequentialWindow = *u.SmsSequentialWindow
+	}
+	if u.SmsCost != nil {
+		s.SmsCost = *u.SmsCost
+	}
+	if u.SmsCountryCosts != nil {
+		s.SmsCountryCosts = u.SmsCountryCosts
+	}
+	if u.SmsDailySpendCap != nil {
+		s.SmsDailySpendCap = *u.SmsDailySpendCap
+	}
+}
+
+// Validate checks that the runtime-editable settings are within bounds.
+func (s *Setting) Validate() error {
+	switch {
+	case s.AccessTokenExpire < MinAccessTokenExpire || s.AccessTokenExpire > MaxAccessTokenExpire:
+		return fmt.Errorf("access_token_expire must be between %d and %d minutes", MinAccessTokenExpire, MaxAccessTokenExpire)
+	case s.RefreshTokenExpire < s.AccessTokenExpire || s.RefreshTokenExpire > MaxRefreshTokenExpire:
+		return fmt.Errorf("refresh_token_expire must be between access_token_expire and %d minutes", MaxRefreshTokenExpire)
+	case s.SecretKeyGracePeriod > MaxSecretKeyGracePeriod:
+		return fmt.Errorf("secret_key_grace_period cannot exceed %d minutes", MaxSecretKeyGracePeriod)
+	case s.OtpLength < MinOtpLength || s.OtpLength > MaxOtpLength:
+		return fmt.Errorf("otp_length must be between %d and %d", MinOtpLength, MaxOtpLength)
+	case s.OtpMaxAttempts < 1 || s.OtpMaxAttempts > MaxOtpAttempts:
+		return fmt.Errorf("otp_max_attempts must be between 1 and %d", MaxOtpAttempts)
+	case s.OtpSessionTTL < MinOtpSessionTTL || s.OtpSessionTTL > MaxOtpSessionTTL:
+		return fmt.Errorf("otp_session_ttl must be between %d and %d seconds", MinOtpSessionTTL, MaxOtpSessionTTL)
+	case s.OtpCooldown > MaxOtpCooldown:
+		return fmt.Errorf("otp_cooldown cannot exceed %d seconds", MaxOtpCooldown)
+	case len(s.OtpThrottleTiers) > MaxOtpThrottleTiers:
+		return fmt.Errorf("at most %d otp_throttle_tiers are allowed", MaxOtpThrottleTiers)
+	case s.AdminLockoutAttempts < 1 || s.AdminLockoutAttempts > MaxAdminLockoutAttempts:
+		return fmt.Errorf("admin_lockout_attempts must be between 1 and %d", MaxAdminLockoutAttempts)
+	case s.AdminLockoutDuration < MinAdminLockoutDuration || s.AdminLockoutDuration > MaxAdminLockoutDuration:
+		return fmt.Errorf("admin_lockout_duration must be between %d and %d seconds", MinAdminLockoutDuration, MaxAdminLockoutDuration)
+	case s.AdminLoginFreeTries >= s.AdminLockoutAttempts:
+		return errors.New("admin_login_free_tries must be lower than admin_lockout_attempts")
+	case s.AdminLoginMaxDelay > MaxAdminLoginMaxDelay:
+		return fmt.Errorf("admin_login_max_delay cannot exceed %d seconds", MaxAdminLoginMaxDelay)
+	case len(s.SmsAllowedCountries) > MaxSmsCountries || len(s.SmsDeniedCountries) > MaxSmsCountries || len(s.SmsCountryCosts) > MaxSmsCountries:
+		return fmt.Errorf("sms_allowed_countries, sms_denied_countries and sms_country_costs allow at most %d entries each", MaxSmsCountries)
+	case s.SmsPrefixLength < MinSmsPrefixLength || s.SmsPrefixLength > MaxSmsPrefixLength:
+		return fmt.Errorf("sms_prefix_length must be between %d and %d digits", MinSmsPrefixLength, MaxSmsPrefixLength)
+	case s.SmsSequentialWindow < 1 || s.SmsSequentialWindow > MaxSmsSequentialWindow:
+		return fmt.Errorf("sms_sequential_window must be between 1 and %d seconds", MaxSmsSequentialWindow)
+	}
+	for _, tier := range s.OtpThrottleTiers {
+		if tier.Window < 1 || tier.Window > MaxOtpThrottleWindow || tier.Limit < 1 {
+			return fmt.Errorf("otp_throttle_tiers need a window between 1 and %d seconds and a positive limit", MaxOtpThrottleWindow)
+		}
+	}
+	for _, code := range slices.Concat(s.SmsAllowedCountries, s.SmsDeniedCountries) {
+		if !phone.IsPrefix(code) {
+			return fmt.Errorf("%q is not a country calling code or a longer prefix, use digits without the plus sign (e.g. 44 or 1876)", code)
+		}
+	}
+	for code := range s.SmsCountryCosts {
+		if !phone.IsPrefix(code) {
+			return fmt.Errorf("sms_country_costs key %q is not a country calling code or a longer prefix", code)
+		}
+	}
+	return nil
+}
+
+// OtpPolicy describes how OTP codes are generated and verified.
+type OtpPolicy struct {
+	Length      int
+	NumericOnly bool
+	MaxAttempts uint
+	SessionTTL  time.Duration
+}
+
+// OtpPolicy builds the OTP policy from the settings, clamping out of range values.
+func (s *Setting) OtpPolicy() OtpPolicy {
+	policy := OtpPolicy{
+		Length:      min(max(int(s.OtpLength), MinOtpLength), MaxOtpLength),
+		NumericOnly: s.OtpNumericOnly,
+		MaxAttempts: max(s.OtpMaxAttempts, 1),
+		SessionTTL:  time.Duration(s.OtpSessionTTL) * time.Second,
+	}
+	if policy.SessionTTL < 30*time.Second {
+		policy.SessionTTL = 30 * time.Second
+	}
+	return policy
+}
+
+// AdminLockoutPolicy slows down and then locks out repeated failed admin logins for a username.
+type AdminLockoutPolicy struct {
+	Attempts  uint          // Failed logins that lock the username
+	Duration  time.Duration // How long the lock lasts and how long failures are remembered
+	FreeTries uint          // Failed logins allowed without a delay
+	MaxDelay  time.Duration
+}
+
+// AdminLockoutPolicy builds the admin lockout policy from the settings, clamping out of range values.
+func (s *Setting) AdminLockoutPolicy() AdminLockoutPolicy {
+	attempts := min(max(s.AdminLockoutAttempts, 1), MaxAdminLockoutAttempts)
+	return AdminLockoutPolicy{
+		Attempts:  attempts,
+		Duration:  time.Duration(min(max(s.AdminLockoutDuration, MinAdminLockoutDuration), MaxAdminLockoutDuration)) * time.Second,
+		FreeTries: min(s.AdminLoginFreeTries, attempts-1),
+		MaxDelay:  time.Duration(min(s.AdminLoginMaxDelay, MaxAdminLoginMaxDelay)) * time.Second,
+	}
+}
+
+// Delay returns how long a username must wait after its failures-th consecutive failed login.
+// The delay doubles with every failure past the free tries, starting at one second.
+func (p AdminLockoutPolicy) Delay(failures uint) time.Duration {
+	if failures <= p.FreeTries {
+		return 0
+	}
+	exponent := min(failures-p.FreeTries-1, 30)
+	return min(time.Second<<exponent, p.MaxDelay)
+}
+
+// SmsFraudPolicy limits where and how many OTP text messages are sent, against SMS pumping and toll fraud.
+type SmsFraudPolicy struct {
+	AllowedCountries   []string // Calling codes or longer prefixes, all are allowed when empty
+	DeniedCountries    []string // Calling codes or longer prefixes, see NumberAllowed
+	CountryHourlyLimit uint     // Zero disables
+	PrefixHourlyLimit  uint     // Zero disables
+	PrefixLength       int      // Leading digits, calling code included
+	SequentialLimit    uint     // Distinct numbers in a block of 100 within SequentialWindow, zero disables
+	SequentialWindow   time.Duration
+	Cost               uint            // Cost units of an SMS to a country without its own cost
+	CountryCosts       map[string]uint // By calling code or longer prefix, see CostOf
+	DailySpendCap      uint            // Cost units per UTC day, zero disables
+}
+
+// SmsFraudPolicy builds the SMS fraud policy from the settings, clamping out of range values.
+func (s *Setting) SmsFraudPolicy() SmsFraudPolicy {
+	return SmsFraudPolicy{
+		AllowedCountries:   s.SmsAllowedCountries,
+		DeniedCountries:    s.SmsDeniedCountries,
+		CountryHourlyLimit: s.SmsCountryHourlyLimit,
+		PrefixHourlyLimit:  s.SmsPrefixHourlyLimit,
+		PrefixLength:       int(min(max(s.SmsPrefixLength, MinSmsPrefixLength), MaxSmsPrefixLength)),
+		SequentialLimit:    s.SmsSequentialLimit,
+		SequentialWindow:   time.Duration(min(max(s.SmsSequentialWindow, 1), MaxSmsSequentialWindow)) * time.Second,
+		Cost:               s.SmsCost,
+		CountryCosts:       s.SmsCountryCosts,
+		DailySpendCap:      s.SmsDailySpendCap,
+	}
+}
+
+// NumberAllowed reports whether OTPs may be sent to number. The longest matching entry of the allow
+// and deny lists decides, so "1" can be allowed with "1876" denied, or the other way round; an entry
+// on both lists denies.
+func (p SmsFraudPolicy) NumberAllowed(number string) bool {
+	allowed, inAllowed := phone.LongestPrefix(number, p.AllowedCountries)
+	denied, inDenied := phone.LongestPrefix(number, p.DeniedCountries)
+	switch {
+	case inDenied && (!inAllowed || len(denied) >= len(allowed)):
+		return false
+	case len(p.AllowedCountries) > 0:
+		return inAllowed
+	default:
+		return true
+	}
+}
+
+// CostOf returns the cost units of an SMS to number, from its longest matching country cost.
+func (p SmsFraudPolicy) CostOf(number string) uint {
+	prefixes := make([]string, 0, len(p.CountryCosts))
+	for prefix := range p.CountryCosts {
+		prefixes = append(prefixes, prefix)
+	}
+	if prefix, ok := phone.LongestPrefix(number, prefixes); ok {
+		return p.CountryCosts[prefix]
+	}
+	return p.Cost
+}
+
+// ValidateCode checks that code has the shape produced by the policy.
+func (p OtpPolicy) ValidateCode(code string) error {
+	if len(code) != p.Length {
+		return fmt.Errorf("code must be exactly %d characters", p.Length)
+	}
+	for _, c := range code {
+		switch {
+		case c >= '0' && c <= '9':
+		case !p.NumericOnly && c >= 'A' && c <= 'Z':
+		default:
+			if p.NumericOnly {
+				return errors.New("code must contain only digits")
+			}
+			return errors.New("code must contain only uppercase letters and digits")
+		}
+	}
+	return nil
 }
 
 type AdminRole int
@@ -83,26 +558,94 @@ func (role AdminRole) String() string {
 	}
 }
 
-func (role AdminRole) IsValid() bool {
-	switch role {
-	case RoleSuperAdmin, RoleSudoAdmin, RoleVisitorAdmin:
-		return true
-	default:
-		return false
+// Permission grants access to a group of admin endpoints.
+type Permission string
+
+const (
+	PermissionUsersRead     Permission = "users:read"
+	PermissionUsersWrite    Permission = "users:write"
+	PermissionSettingsRead  Permission = "settings:read"
+	PermissionSettingsWrite Permission = "settings:write"
+	PermissionAdminsManage  Permission = "admins:manage"
+	PermissionAuditRead     Permission = "audit:read"
+	PermissionIPListsManage Permission = "ip_lists:manage"
+)
+
+// AllPermissions lists every known permission.
+var AllPermissions = []Permission{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionSettingsRead,
+	PermissionSettingsWrite,
+	PermissionAdminsManage,
+	PermissionAuditRead,
+	PermissionIPListsManage,
+}
+
+func (p Permission) IsValid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
 	}
+	return false
 }
 
-func ParseAdminRole(roleStr string) (AdminRole, error) {
-	switch roleStr {
-	case "Super":
-		return RoleSuperAdmin, nil
-	case "Sudo":
-		return RoleSudoAdmin, nil
-	case "Visitor":
-		return RoleVisitorAdmin, nil
-	default:
-		return 0, errors.New("invalid admin role")
+// Role is a named set of permissions assigned to admins through Admin.Role, which holds the role ID.
+// The built-in roles keep the IDs of the former fixed roles; Super always holds every permission.
+type Role struct {
+	ID          uint         `gorm:"primaryKey"`
+	CreatedAt   time.Time    `gorm:""`
+	UpdatedAt   time.Time    `gorm:""`
+	Name        string       `gorm:"not null;uniqueIndex"`
+	Description string       `gorm:""`
+	Builtin     bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"serializer:json"`
+}
+
+// BuiltinRoles are created on first start with the access the former fixed roles had. Only Super
+// is immutable; the others can be edited.
+var BuiltinRoles = []Role{
+	{ID: uint(RoleSuperAdmin), Name: "Super", Description: "Full access", Builtin: true, Permissions: AllPermissions},
+	{ID: uint(RoleSudoAdmin), Name: "Sudo", Description: "Manages users", Builtin: true, Permissions: []Permission{
+		PermissionUsersRead, PermissionUsersWrite,
+	}},
+	{ID: uint(RoleVisitorAdmin), Name: "Visitor", Description: "Reads users", Builtin: true, Permissions: []Permission{
+		PermissionUsersRead,
+	}},
+}
+
+// RoleUpdate struct for partial updates of a role
+type RoleUpdate struct {
+	Name        *string
+	Description *string
+	Permissions []Permission
+}
+
+// EffectivePermissions returns the permissions the role grants.
+func (r *Role) EffectivePermissions() []Permission {
+	if r.ID == uint(RoleSuperAdmin) {
+		return AllPermissions
+	}
+	return r.Permissions
+}
+
+// HasPermissions reports whether the role grants every permission in perms.
+func (r *Role) HasPermissions(perms ...Permission) bool {
+	granted := r.EffectivePermissions()
+	for _, p := range perms {
+		found := false
+		for _, g := range granted {
+			if p == g {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
 	}
+	return true
 }
 
 type Admin struct {
@@ -113,6 +656,219 @@ type Admin struct {
 	PasswordResetAt sql.NullTime
 }
 
+// AdminPasswordHistory keeps the hashes of an admin's previous passwords so they are not reused.
+type AdminPasswordHistory struct {
+	ID             uint      `gorm:"primarykey"`
+	CreatedAt      time.Time `gorm:"not null"`
+	AdminID        uint      `gorm:"not null;index"`
+	HashedPassword string    `gorm:"not null"`
+}
+
+// AdminTotpSecret holds an admin's encrypted authenticator app secret.
+type AdminTotpSecret struct {
+	gorm.Model
+	AdminID         uint         `gorm:"not null;uniqueIndex"`
+	Admin           *Admin       `gorm:"foreignkey:AdminID"`
+	EncryptedSecret string       `gorm:"not null"`
+	ConfirmedAt     sql.NullTime `gorm:""`
+	LastUsedStep    int64        `gorm:"not null;default:0"`
+}
+
+// RefreshToken is an opaque, single-use token exchanged for a new access token.
+// Tokens issued from the same login share a FamilyID, which is also the Jti of the login's Session;
+// reusing a rotated token revokes the whole family.
+type RefreshToken struct {
+	gorm.Model
+	TokenHash       string       `gorm:"not null;uniqueIndex"`
+	FamilyID        string       `gorm:"not null;index"`
+	ParentID        *uint        `gorm:"index"`
+	SubjectID       uint         `gorm:"not null"`
+	Audience        int          `gorm:"not null"`
+	AuthenticatedAt time.Time    `gorm:"not null"` // Time of the login that started the family
+	DeviceInfo      string       `gorm:""`
+	IPAddress       string       `gorm:""`
+	ExpiresAt       time.Time    `gorm:"not null"`
+	RotatedAt       sql.NullTime `gorm:""`
+	RevokedAt       sql.NullTime `gorm:""`
+}
+
+// Session is a signed-in device. Every access token issued for the login carries Jti as its jti claim,
+// so revoking the session invalidates those tokens before they expire.
+type Session struct {
+	gorm.Model
+	Jti        string       `gorm:"not null;uniqueIndex"`
+	SubjectID  uint         `gorm:"not null;index:idx_sessions_subject"`
+	Audience   int          `gorm:"not null;index:idx_sessions_subject"`
+	DeviceInfo string       `gorm:""`
+	IPAddress  string       `gorm:""`
+	LastSeenAt time.Time    `gorm:"not null"` // Time of the login or the last refresh
+	ExpiresAt  time.Time    `gorm:"not null"` // Expiry of the latest refresh token
+	RevokedAt  sql.NullTime `gorm:""`
+}
+
+// IsActive reports whether tokens of the session are still accepted.
+func (s *Session) IsActive() bool {
+	return !s.RevokedAt.Valid && time.Now().UTC().Before(s.ExpiresAt)
+}
+
+type SigningKeyStatus int
+
+const (
+	SigningKeyActive SigningKeyStatus = iota + 1
+	SigningKeyRetiring
+	SigningKeyRetired
+)
+
+func (s SigningKeyStatus) String() string {
+	switch s {
+	case SigningKeyActive:
+		return "Active"
+	case SigningKeyRetiring:
+		return "Retiring"
+	case SigningKeyRetired:
+		return "Retired"
+	default:
+		return "Unknown"
+	}
+}
+
+// SigningKey is an asymmetric JWT signing key. The active key signs new tokens; retiring keys
+// only verify tokens signed before the last rotation and stay published in the JWKS until retired.
+type SigningKey struct {
+	gorm.Model
+	Kid        string           `gorm:"not null;uniqueIndex"`
+	Algorithm  string           `gorm:"not null"`
+	PrivateKey string           `gorm:"not null"` // PKCS #8 PEM encrypted with DATA_ENCRYPTION_KEY
+	PublicKey  string           `gorm:"not null"` // PKIX PEM
+	Status     SigningKeyStatus `gorm:"not null;default:1;index"`
+	RetiredAt  sql.NullTime     `gorm:""`
+}
+
+// AuditActorType identifies who performed an audited action.
+type AuditActorType string
+
+const (
+	AuditActorAdmin     AuditActorType = "admin"
+	AuditActorAnonymous AuditActorType = "anonymous"
+	AuditActorSystem    AuditActorType = "system" // Operators using the admin CLI
+)
+
+// AuditAction names an audited action.
+type AuditAction string
+
+const (
+	AuditAdminLogin           AuditAction = "admin.login"             // Password and any second factor checked
+	AuditAdminLoginPendingMfa AuditAction = "admin.login_pending_mfa" // Password checked, second factor or enrollment pending
+	AuditAdminLoginFailed     AuditAction = "admin.login_failed"
+	AuditAdminMfaVerified     AuditAction = "admin.mfa_verified"
+	AuditAdminMfaFailed       AuditAction = "admin.mfa_failed"
+	AuditAdminLockedOut       AuditAction = "admin.locked_out"
+	AuditAdminUnlocked        AuditAction = "admin.unlocked"
+	AuditAdminTotpEnrolled    AuditAction = "admin.totp_enrolled"
+	AuditAdminTotpDisabled    AuditAction = "admin.totp_disabled"
+	AuditAdminCreated         AuditAction = "admin.created"
+	AuditAdminUpdated         AuditAction = "admin.updated"
+	AuditAdminDeleted         AuditAction = "admin.deleted"
+	AuditRoleCreated          AuditAction = "role.created"
+	AuditRoleUpdated          AuditAction = "role.updated"
+	AuditRoleDeleted          AuditAction = "role.deleted"
+	AuditUserStatusUpdated    AuditAction = "user.status_updated"
+	AuditSettingsUpdated      AuditAction = "settings.updated"
+	AuditMfaPolicyUpdated     AuditAction = "settings.mfa_policy_updated"
+	AuditSecretKeyRotated     AuditAction = "settings.secret_key_rotated"
+	AuditIPListAdded          AuditAction = "ip_list.added"
+	AuditIPListRemoved        AuditAction = "ip_list.removed"
+	AuditSigningKeyRotated    AuditAction = "signing_key.rotated"
+	AuditSigningKeyRetired    AuditAction = "signing_key.retired"
+)
+
+// AuditChange holds the value of a field before and after an audited action.
+type AuditChange struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// AuditEvent is an append-only record of an admin or security action. Each event stores the hash of
+// the previous one, so editing or deleting a row breaks the chain from that row on.
+type AuditEvent struct {
+	ID         uint                   `gorm:"primarykey"`
+	CreatedAt  time.Time              `gorm:"not null;index"`
+	ActorType  AuditActorType         `gorm:"not null;index:idx_audit_events_actor"`
+	ActorID    *uint                  `gorm:"index:idx_audit_events_actor"`
+	ActorName  string                 `gorm:""`
+	Action     AuditAction            `gorm:"not null;index"`
+	TargetType string                 `gorm:"index:idx_audit_events_target"`
+	TargetID   string                 `gorm:"index:idx_audit_events_target"`
+	Changes    map[string]AuditChange `gorm:"serializer:json"`
+	IPAddress  string                 `gorm:""`
+	UserAgent  string                 `gorm:""`
+	RequestID  string                 `gorm:"index"`
+	PrevHash   string                 `gorm:"not null"`
+	Hash       string                 `gorm:"not null;uniqueIndex"`
+}
+
+// ComputeHash returns the SHA-256 of PrevHash and every recorded field except ID and Hash.
+func (e *AuditEvent) ComputeHash() (string, error) {
+	payload, err := json.Marshal(struct {
+		PrevHash   string                 `json:"prev_hash"`
+		CreatedAt  string                 `json:"created_at"`
+		ActorType  AuditActorType         `json:"actor_type"`
+		ActorID    *uint                  `json:"actor_id"`
+		ActorName  string                 `json:"actor_name"`
+		Action     AuditAction            `json:"action"`
+		TargetType string                 `json:"target_type"`
+		TargetID   string                 `json:"target_id"`
+		Changes    map[string]AuditChange `json:"changes"`
+		IPAddress  string                 `json:"ip_address"`
+		UserAgent  string                 `json:"user_agent"`
+		RequestID  string                 `json:"request_id"`
+	}{
+		PrevHash:   e.PrevHash,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ActorType:  e.ActorType,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Changes:    e.Changes,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type AuditSearchParams struct {
+	ActorType  *AuditActorType `schema:"actor_type"`
+	ActorID    *uint           `schema:"actor_id"`
+	Action     *AuditAction    `schema:"action"`
+	TargetType *string         `schema:"target_type"`
+	TargetID   *string         `schema:"target_id"`
+	RequestID  *string         `schema:"request_id"`
+	From       *time.Time      `schema:"from"`
+	To         *time.Time      `schema:"to"`
+	Limit      int             `schema:"limit"`
+	Offset     int             `schema:"offset"`
+	SortOrder  string          `schema:"sort_order"`
+}
+
+func (p *AuditSearchParams) SetDefaults() {
+	if p.Limit <= 0 || p.Limit > 100 {
+		p.Limit = 20 // Default limit
+	}
+	if p.Offset < 0 {
+		p.Offset = 0 // Default offset
+	}
+	if p.SortOrder != "asc" && p.SortOrder != "desc" {
+		p.SortOrder = "desc" // Newest events first
+	}
+}
+
 type UserSearchParams struct {
 	ID          *uint       `schema:"id"`
 	PhoneNumber *string     `schema:"phone_number"`
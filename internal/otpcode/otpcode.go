@@ -0,0 +1,47 @@
+package otpcode
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+const (
+	digits       = "0123456789"
+	alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Generate returns a new random OTP code following policy.
+func Generate(policy models.OtpPolicy) string {
+	chars := alphanumeric
+	if policy.NumericOnly {
+		chars = digits
+	}
+	code := make([]byte, policy.Length)
+	for i := range code {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		code[i] = chars[n.Int64()]
+	}
+	return string(code)
+}
+
+// Hasher derives keyed hashes of OTP codes so they are never stored in clear text.
+type Hasher struct {
+	pepper []byte
+}
+
+// NewHasher creates a new Hasher keyed by pepper.
+func NewHasher(pepper string) *Hasher {
+	return &Hasher{pepper: []byte(pepper)}
+}
+
+// Hash returns the hex encoded HMAC-SHA256 of code.
+func (h *Hasher) Hash(code string) string {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+)
+
+const (
+	pollInterval   = time.Second
+	publishTimeout = 10 * time.Second // Per sink
+	maxBackoff     = 5 * time.Minute
+)
+
+// Relay publishes committed outbox events to the registered sinks, one event at a time.
+// An event is claimed in a short transaction that hides it from relays on other instances
+// for a lease outlasting the sink calls, published without holding any lock, and its outcome
+// recorded in a second transaction. The sinks that already received an event are recorded
+// so a retry only targets the ones that failed.
+type Relay struct {
+	database   *db.DB
+	outboxRepo repository.Outbox
+	sinks      []Sink
+	lease      time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay creates a new Relay.
+func NewRelay(database *db.DB, outboxRepo repository.Outbox, sinks ...Sink) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		database:   database,
+		outboxRepo: outboxRepo,
+		sinks:      sinks,
+		lease:      time.Duration(len(sinks)+1) * publishTimeout,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start launches the relay goroutine.
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go r.run()
+	log.Printf("Outbox relay started with %d sinks", len(r.sinks))
+}
+
+// Stop signals the relay to exit and waits for the current event to finish.
+func (r *Relay) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Println("Outbox relay stopped")
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+
+	for {
+		processed, err := r.processNext()
+		if err != nil {
+			log.Printf("Outbox relay error: %v", err)
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// processNext claims a single event, publishes it and records the outcome.
+func (r *Relay) processNext() (bool, error) {
+	event, err := r.claim()
+	if err != nil || event == nil {
+		return false, err
+	}
+
+	r.publish(event)
+
+	// The outcome is recorded even while stopping, otherwise the sinks that received the event get it again after its lease.
+	tx := r.database.GetTransaction(context.WithoutCancel(r.ctx))
+	if tx.Error != nil {
+		return true, tx.Error
+	}
+	defer tx.Rollback()
+
+	if err = r.outboxRepo.Save(tx, event); err != nil {
+		return true, err
+	}
+	return true, tx.Commit().Error
+}
+
+// claim takes the next due event in its own transaction.
+func (r *Relay) claim() (*models.OutboxEvent, error) {
+	tx := r.database.GetTransaction(r.ctx)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.Rollback()
+
+	event, err := r.outboxRepo.ClaimNext(tx, r.lease)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	return event, tx.Commit().Error
+}
+
+// publish sends event to every sink it has not been delivered to yet and records the outcome on event.
+func (r *Relay) publish(event *models.OutboxEvent) {
+	var errs []error
+	for _, sink := range r.sinks {
+		if slices.Contains(event.DeliveredTo, sink.Name()) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(r.ctx, publishTimeout)
+		err := sink.Publish(ctx, event)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		event.DeliveredTo = append(event.DeliveredTo, sink.Name())
+	}
+
+	now := time.Now().UTC()
+	if len(errs) == 0 {
+		event.PublishedAt = sql.NullTime{Time: now, Valid: true}
+		event.LastError = ""
+		return
+	}
+
+	event.Attempts++
+	event.LastError = errors.Join(errs...).Error()
+	backoff := time.Second << min(event.Attempts, 16)
+	event.NextAttemptAt = now.Add(min(backoff, maxBackoff))
+	log.Printf("Outbox event publish failed, retrying at %s: ID=%d, Type=%s, Error=%s",
+		event.NextAttemptAt.Format(time.RFC3339), event.ID, event.Type, event.LastError)
+}
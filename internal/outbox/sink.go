@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/config"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+// Sink receives committed outbox events.
+// Sinks should treat the event ID as an idempotency key: an event is published
+// to a sink at most once per successful relay run, but a crash between publishing
+// and recording the delivery can cause a redelivery.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+type message struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+func newMessage(event *models.OutboxEvent) message {
+	return message{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Payload:   json.RawMessage(event.Payload),
+	}
+}
+
+// LogSink writes events to the application log.
+type LogSink struct{}
+
+// NewLogSink creates a new LogSink.
+func NewLogSink() *LogSink {
+	return &LogSink{}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Publish(_ context.Context, event *models.OutboxEvent) error {
+	log.Printf("[outbox] Event: ID=%d, Type=%s, Payload=%s", event.ID, event.Type, event.Payload)
+	return nil
+}
+
+// RedisSink publishes events to a Redis pub/sub channel.
+type RedisSink struct {
+	redisCli *redis.Config
+	channel  string
+}
+
+// NewRedisSink creates a new RedisSink publishing to channel.
+func NewRedisSink(redisCli *redis.Config, channel string) *RedisSink {
+	return &RedisSink{redisCli: redisCli, channel: channel}
+}
+
+func (s *RedisSink) Name() string {
+	return "redis"
+}
+
+func (s *RedisSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := json.Marshal(newMessage(event))
+	if err != nil {
+		return err
+	}
+	return s.redisCli.Publish(ctx, s.channel, data)
+}
+
+// WebhookSink POSTs events as JSON to a URL.
+type WebhookSink struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookSink creates a new WebhookSink for url.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		client: &http.Client{Timeout: 10 * time.Second},
+		url:    url,
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := json.Marshal(newMessage(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatUint(uint64(event.ID), 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewSinks creates the sinks enabled in the outbox configuration.
+func NewSinks(cfg config.OutboxConfig, redisCli *redis.Config) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, NewLogSink())
+		case "redis":
+			sinks = append(sinks, NewRedisSink(redisCli, cfg.RedisChannel))
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				return nil, errors.New("OUTBOX_WEBHOOK_URL is required for the webhook sink")
+			}
+			sinks = append(sinks, NewWebhookSink(cfg.WebhookURL))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink: %s", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,76 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// minPrefixLength is the shortest hash prefix accepted in a breached password list.
+const minPrefixLength = 5
+
+// BreachedList holds SHA-1 hash prefixes of passwords known from breaches, so they can be checked
+// offline without sending anything to a third party.
+type BreachedList struct {
+	prefixes map[string]struct{}
+	lengths  []int // Distinct prefix lengths present in prefixes
+}
+
+// LoadBreachedList reads a list of uppercase or lowercase hex SHA-1 prefixes, one per line. Lines may
+// carry a ":count" suffix as in downloaded breach corpora, and full 40 character hashes are accepted.
+// Blank lines and lines starting with # are skipped.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachedList{prefixes: make(map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		entry, _, _ = strings.Cut(entry, ":")
+		entry = strings.ToUpper(entry)
+
+		if len(entry) < minPrefixLength || len(entry) > sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list line %d: prefix must be %d to %d characters", line, minPrefixLength, sha1.Size*2)
+		}
+		if _, err = hex.DecodeString(entry + strings.Repeat("0", len(entry)%2)); err != nil {
+			return nil, fmt.Errorf("breached password list line %d: prefix is not hexadecimal", line)
+		}
+
+		list.prefixes[entry] = struct{}{}
+		if !slices.Contains(list.lengths, len(entry)) {
+			list.lengths = append(list.lengths, len(entry))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return list, nil
+}
+
+// Len returns the number of prefixes in the list.
+func (l *BreachedList) Len() int {
+	return len(l.prefixes)
+}
+
+// Contains reports whether the SHA-1 hash of password starts with any prefix in the list.
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	for _, length := range l.lengths {
+		if _, ok := l.prefixes[hash[:length]]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/MoSed3/otp-server/internal/config"
+)
+
+// Supported hashing algorithms.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params are the tunable argon2id parameters. They are encoded in every hash, so changing
+// them does not affect verifying existing hashes.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes made by any supported
+// algorithm, telling when a stored hash should be replaced.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+	dummyHash  string // Hash of a random password with the configured parameters, see SimulateVerify
+}
+
+// NewHasher creates a Hasher from cfg.
+func NewHasher(cfg config.PasswordConfig) (*Hasher, error) {
+	h := &Hasher{
+		algorithm: cfg.HashAlgorithm,
+		argon2: Argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		bcryptCost: cfg.BcryptCost,
+	}
+
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return nil, errors.New("invalid argon2id parameters: iterations and parallelism must be positive and memory at least 8 KiB per thread")
+		}
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", h.algorithm)
+	}
+
+	dummy := make([]byte, 32)
+	if _, err := rand.Read(dummy); err != nil {
+		return nil, err
+	}
+	var err error
+	if h.dummyHash, err = h.Hash(base64.RawStdEncoding.EncodeToString(dummy)); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Hash returns the encoded hash of password. The algorithm and its parameters are part of the result.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, argon2KeyLength)
+	return encodeArgon2(h.argon2, salt, key), nil
+}
+
+// Verify reports whether password matches hash. It returns an error only for malformed hashes.
+func (h *Hasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnknownHash
+}
+
+// SimulateVerify takes as long as verifying password against a hash made with the configured
+// parameters, without matching anything. Logins of unknown accounts run it so their response time does
+// not reveal that the account does not exist.
+func (h *Hasher) SimulateVerify(password string) {
+	_, _ = h.Verify(h.dummyHash, password)
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or weaker parameters than the
+// configured ones, so it should be replaced the next time the password is known.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		params, _, key, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+		return params.Memory < h.argon2.Memory || params.Iterations < h.argon2.Iterations ||
+			params.Parallelism < h.argon2.Parallelism || len(key) < argon2KeyLength
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < h.bcryptCost
+	}
+	return false
+}
+
+// encodeArgon2 formats a hash in the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2 key")
+	}
+	return params, salt, key, nil
+}
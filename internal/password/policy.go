@@ -0,0 +1,133 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/MoSed3/otp-server/internal/config"
+)
+
+// minUsernameLength is the shortest username that passwords are checked not to contain.
+const minUsernameLength = 3
+
+// ErrRejected is wrapped by every error returned for a password that does not meet the policy.
+var ErrRejected = errors.New("password rejected")
+
+// Policy describes the passwords admins may choose.
+type Policy struct {
+	MinLength   int     // Characters
+	MinEntropy  float64 // Estimated bits
+	HistorySize int     // Previous passwords that cannot be reused
+	breached    *BreachedList
+}
+
+// NewPolicy creates a policy from cfg, loading the breached password list when one is configured.
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	policy := &Policy{
+		MinLength:   cfg.MinLength,
+		MinEntropy:  float64(cfg.MinEntropy),
+		HistorySize: max(cfg.HistorySize, 0),
+	}
+
+	if cfg.BreachedListFile != "" {
+		breached, err := LoadBreachedList(cfg.BreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+	return policy, nil
+}
+
+// Check rejects password when it is too short, too predictable, contains username or
+// appears in the breached password list. Reuse is checked separately against stored hashes.
+func (p *Policy) Check(username, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrRejected, p.MinLength)
+	}
+
+	lowerPassword := strings.ToLower(password)
+	lowerUsername := strings.ToLower(strings.TrimSpace(username))
+	if utf8.RuneCountInString(lowerUsername) >= minUsernameLength &&
+		(strings.Contains(lowerPassword, lowerUsername) || strings.Contains(lowerPassword, reverse(lowerUsername))) {
+		return fmt.Errorf("%w: must not contain the username", ErrRejected)
+	}
+
+	if entropy := Entropy(password); entropy < p.MinEntropy {
+		return fmt.Errorf("%w: too predictable, use a longer password or more kinds of characters", ErrRejected)
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		return fmt.Errorf("%w: appears in a list of breached passwords", ErrRejected)
+	}
+	return nil
+}
+
+// Entropy estimates the strength of password in bits: the size of the character pool it draws from,
+// applied to every character that does not repeat or continue a run of the previous ones.
+func Entropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	var counted float64
+	var prev rune = -1
+	var prevStep rune
+
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+
+		step := c - prev
+		switch {
+		case prev < 0:
+			counted++
+		case step == 0 || ((step == 1 || step == -1) && step == prevStep):
+			// Repeats such as "aaa" and runs such as "abc" or "321" add little
+			counted += 0.25
+		default:
+			counted++
+		}
+		prev, prevStep = c, step
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return counted * math.Log2(float64(pool))
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
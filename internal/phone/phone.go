@@ -0,0 +1,67 @@
+package phone
+
+import "strings"
+
+// maxDigits is the longest an E.164 number can be, without the plus sign.
+const maxDigits = 15
+
+// twoDigitCallingCodes are the ITU country calling codes of two digits. Calling codes are prefix
+// free: a number starting with 1 or 7 has a one digit code, one starting with any of these has a
+// two digit code, and every other number has a three digit code.
+var twoDigitCallingCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true,
+	"39": true, "40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true,
+	"48": true, "49": true, "51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true, "64": true, "65": true,
+	"66": true, "81": true, "82": true, "84": true, "86": true, "90": true, "91": true, "92": true,
+	"93": true, "94": true, "95": true, "98": true,
+}
+
+// Digits returns an E.164 number without its leading plus sign.
+func Digits(number string) string {
+	return strings.TrimPrefix(number, "+")
+}
+
+// CallingCode returns the country calling code of an E.164 number, without the plus sign.
+func CallingCode(number string) string {
+	digits := Digits(number)
+	switch {
+	case digits == "":
+		return ""
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case len(digits) >= 2 && twoDigitCallingCodes[digits[:2]]:
+		return digits[:2]
+	case len(digits) >= 3:
+		return digits[:3]
+	default:
+		return digits
+	}
+}
+
+// IsPrefix reports whether prefix has the shape of the leading digits of numbers starting with a
+// whole country calling code, such as "44", or a longer prefix within one, such as "1876" for Jamaica.
+func IsPrefix(prefix string) bool {
+	if prefix == "" || prefix[0] == '0' || len(prefix) > maxDigits {
+		return false
+	}
+	for _, c := range prefix {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(CallingCode(prefix+"0")) <= len(prefix)
+}
+
+// LongestPrefix returns the longest of prefixes that number starts with, or false when none does.
+// number is an E.164 number, with or without its plus sign.
+func LongestPrefix(number string, prefixes []string) (string, bool) {
+	digits := Digits(number)
+	longest, found := "", false
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(digits, prefix) && (!found || len(prefix) > len(longest)) {
+			longest, found = prefix, true
+		}
+	}
+	return longest, found
+}
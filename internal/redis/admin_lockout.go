@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// AdminLoginState tracks the failed logins of an admin username.
+type AdminLoginState struct {
+	Failures      uint
+	LockedUntil   time.Time // Zero when the username is not locked
+	NextAttemptAt time.Time // Zero when no delay applies
+}
+
+// RetryAfter returns how long the username must wait before its next login attempt, or zero.
+func (s AdminLoginState) RetryAfter(now time.Time) time.Duration {
+	wait := max(s.LockedUntil.Sub(now), s.NextAttemptAt.Sub(now))
+	return max(wait, 0)
+}
+
+// Locked reports whether the username is locked out at now.
+func (s AdminLoginState) Locked(now time.Time) bool {
+	return s.LockedUntil.After(now)
+}
+
+// adminLoginPendingTTL bounds how long a reserved attempt whose outcome is never recorded keeps counting.
+const adminLoginPendingTTL = 30 * time.Second
+
+func adminLoginKey(username string) string {
+	return "admin_login:" + username
+}
+
+func adminLoginPendingKey(username string) string {
+	return "admin_login_pending:" + username
+}
+
+// luaReserveAdminLoginAttempt refuses an attempt while the username is locked or inside its delay, and
+// refuses parallel attempts past the free tries, which are never more than the attempt limit. Otherwise
+// the attempt is counted as pending until its outcome is recorded.
+const luaReserveAdminLoginAttempt = `
+local now = tonumber(ARGV[1])
+local freeTries = tonumber(ARGV[2])
+local pendingTTL = tonumber(ARGV[3])
+
+local lockedUntil = tonumber(redis.call('HGET', KEYS[1], 'locked_until') or '0')
+if lockedUntil > now then
+    return {1, lockedUntil - now}
+end
+local nextAttemptAt = tonumber(redis.call('HGET', KEYS[1], 'next_attempt_at') or '0')
+if nextAttemptAt > now then
+    return {2, nextAttemptAt - now}
+end
+
+local failures = tonumber(redis.call('HGET', KEYS[1], 'failures') or '0')
+local pending = tonumber(redis.call('GET', KEYS[2]) or '0')
+if pending > 0 and failures + pending >= freeTries then
+    return {2, math.max(redis.call('PTTL', KEYS[2]), 1)}
+end
+
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], pendingTTL)
+return {0, 0}
+`
+
+// luaReleaseAdminLoginAttempt ends a pending attempt.
+const luaReleaseAdminLoginAttempt = `
+if tonumber(redis.call('GET', KEYS[1]) or '0') > 0 then
+    redis.call('DECR', KEYS[1])
+end
+return 0
+`
+
+// luaRecordAdminLoginFailure ends a pending attempt and counts it as failed. Reaching the attempt limit
+// locks the username and starts a fresh count; otherwise failures past the free tries set a doubling delay.
+const luaRecordAdminLoginFailure = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local attempts = tonumber(ARGV[2])
+local duration = tonumber(ARGV[3])
+local lockedUntil = 0
+local nextAttemptAt = 0
+
+if tonumber(redis.call('GET', KEYS[2]) or '0') > 0 then
+    redis.call('DECR', KEYS[2])
+end
+
+local failures = redis.call('HINCRBY', key, 'failures', 1)
+if failures >= attempts then
+    lockedUntil = now + duration
+    redis.call('HSET', key, 'failures', 0, 'locked_until', lockedUntil, 'next_attempt_at', 0)
+else
+    local delay = tonumber(ARGV[3 + failures]) or 0
+    if delay > 0 then
+        nextAttemptAt = now + delay
+    end
+    redis.call('HSET', key, 'next_attempt_at', nextAttemptAt)
+end
+redis.call('PEXPIRE', key, duration)
+return {failures, lockedUntil, nextAttemptAt}
+`
+
+// ReserveAdminLoginAttempt atomically checks that username may attempt a login and counts the attempt as
+// pending, so parallel attempts cannot get past the lockout. When the attempt is refused it returns how
+// long to wait, and whether the username is locked out. A reserved attempt ends with
+// RecordAdminLoginFailure, ReleaseAdminLoginAttempt or ResetAdminLoginFailures.
+func (c *Config) ReserveAdminLoginAttempt(ctx context.Context, username string, policy models.AdminLockoutPolicy) (time.Duration, bool, error) {
+	keys := []string{adminLoginKey(username), adminLoginPendingKey(username)}
+	values, err := c.client.Eval(ctx, luaReserveAdminLoginAttempt, keys,
+		time.Now().UnixMilli(),
+		policy.FreeTries,
+		adminLoginPendingTTL.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(values) != 2 {
+		return 0, false, errors.New("unexpected result from Redis Lua script")
+	}
+	return time.Duration(values[1]) * time.Millisecond, values[0] == 1, nil
+}
+
+// ReleaseAdminLoginAttempt ends a reserved attempt that neither failed nor completed the login.
+func (c *Config) ReleaseAdminLoginAttempt(ctx context.Context, username string) error {
+	return c.client.Eval(ctx, luaReleaseAdminLoginAttempt, []string{adminLoginPendingKey(username)}).Err()
+}
+
+// RecordAdminLoginFailure counts a failed login for username and returns the resulting state.
+// LockedUntil is set only when this failure locked the username.
+func (c *Config) RecordAdminLoginFailure(ctx context.Context, username string, policy models.AdminLockoutPolicy) (AdminLoginState, error) {
+	now := time.Now()
+
+	args := make([]any, 0, 3+policy.Attempts)
+	args = append(args, now.UnixMilli(), policy.Attempts, policy.Duration.Milliseconds())
+	for failures := uint(1); failures < policy.Attempts; failures++ {
+		args = append(args, policy.Delay(failures).Milliseconds())
+	}
+
+	keys := []string{adminLoginKey(username), adminLoginPendingKey(username)}
+	result, err := c.client.Eval(ctx, luaRecordAdminLoginFailure, keys, args...).Slice()
+	if err != nil {
+		return AdminLoginState{}, err
+	}
+	if len(result) != 3 {
+		return AdminLoginState{}, errors.New("unexpected result from Redis Lua script")
+	}
+
+	values := make([]int64, len(result))
+	for i, v := range result {
+		n, ok := v.(int64)
+		if !ok {
+			return AdminLoginState{}, errors.New("unexpected result type from Redis Lua script")
+		}
+		values[i] = n
+	}
+	return AdminLoginState{
+		Failures:      uint(values[0]),
+		LockedUntil:   millisToTime(values[1]),
+		NextAttemptAt: millisToTime(values[2]),
+	}, nil
+}
+
+// GetAdminLoginState returns the failed login state of username.
+func (c *Config) GetAdminLoginState(ctx context.Context, username string) (AdminLoginState, error) {
+	fields, err := c.client.HGetAll(ctx, adminLoginKey(username)).Result()
+	if err != nil {
+		return AdminLoginState{}, err
+	}
+
+	failures, _ := strconv.ParseUint(fields["failures"], 10, 32)
+	lockedUntil, _ := strconv.ParseInt(fields["locked_until"], 10, 64)
+	nextAttemptAt, _ := strconv.ParseInt(fields["next_attempt_at"], 10, 64)
+	return AdminLoginState{
+		Failures:      uint(failures),
+		LockedUntil:   millisToTime(lockedUntil),
+		NextAttemptAt: millisToTime(nextAttemptAt),
+	}, nil
+}
+
+// ResetAdminLoginFailures forgets the failed and pending logins of username and lifts any lock.
+func (c *Config) ResetAdminLoginFailures(ctx context.Context, username string) error {
+	return c.client.Del(ctx, adminLoginKey(username), adminLoginPendingKey(username)).Err()
+}
+
+func millisToTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+// UseMfaChallenge marks the MFA challenge token with jti id as used until ttl passes, and reports
+// whether this was its first use.
+func (c *Config) UseMfaChallenge(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, "mfa_challenge_used:"+id, 1, max(ttl, time.Second)).Result()
+}
@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IPList names a list of networks checked before rate limiting.
+type IPList string
+
+const (
+	// IPAllowList networks are never denied or rate limited.
+	IPAllowList IPList = "allow"
+	// IPDenyList networks are rejected outright.
+	IPDenyList IPList = "deny"
+)
+
+const ipListVersionKey = "ip_list:version"
+
+// IsValid reports whether l is a known list.
+func (l IPList) IsValid() bool {
+	return l == IPAllowList || l == IPDenyList
+}
+
+// IPListEntry is a network on an allow or deny list.
+type IPListEntry struct {
+	Network   string     `json:"network"` // Single address or CIDR, normalized by ParseNetwork
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Nil for permanent entries
+}
+
+// Expired reports whether the entry no longer applies at now.
+func (e IPListEntry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !e.ExpiresAt.After(now)
+}
+
+// ParseNetwork parses an address or CIDR. It returns the network and its normalized form: the
+// address for single addresses, the masked CIDR otherwise.
+func ParseNetwork(network string) (netip.Prefix, string, error) {
+	if addr, err := netip.ParseAddr(network); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), addr.String(), nil
+	}
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return netip.Prefix{}, "", fmt.Errorf("invalid address or CIDR: %s", network)
+	}
+	prefix = prefix.Masked()
+	return prefix, prefix.String(), nil
+}
+
+func ipListKey(list IPList) string {
+	return "ip_list:" + string(list)
+}
+
+// AddIPListEntry adds entry to list, replacing an entry for the same network.
+func (c *Config) AddIPListEntry(ctx context.Context, list IPList, entry IPListEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, ipListKey(list), entry.Network, data)
+	pipe.Incr(ctx, ipListVersionKey)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RemoveIPListEntry removes network from list and reports whether it was there.
+func (c *Config) RemoveIPListEntry(ctx context.Context, list IPList, network string) (bool, error) {
+	pipe := c.client.TxPipeline()
+	removed := pipe.HDel(ctx, ipListKey(list), network)
+	pipe.Incr(ctx, ipListVersionKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// HasIPListEntry reports whether list has an entry for network, expired or not.
+func (c *Config) HasIPListEntry(ctx context.Context, list IPList, network string) (bool, error) {
+	return c.client.HExists(ctx, ipListKey(list), network).Result()
+}
+
+// GetIPListEntries returns the entries of list that have not expired, dropping expired ones.
+func (c *Config) GetIPListEntries(ctx context.Context, list IPList) ([]IPListEntry, error) {
+	fields, err := c.client.HGetAll(ctx, ipListKey(list)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]IPListEntry, 0, len(fields))
+	var expired []string
+	for network, data := range fields {
+		var entry IPListEntry
+		if err = json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("invalid %s list entry %s: %w", list, network, err)
+		}
+		if entry.Expired(now) {
+			expired = append(expired, network)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(expired) > 0 {
+		// Expiry is also checked on every read, so a failed cleanup is retried next time
+		_ = c.client.HDel(ctx, ipListKey(list), expired...).Err()
+	}
+	return entries, nil
+}
+
+// GetIPListVersion returns a counter increased on every change to the lists, so cached copies can
+// tell when to reload.
+func (c *Config) GetIPListVersion(ctx context.Context) (int64, error) {
+	version, err := c.client.Get(ctx, ipListVersionKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// luaRecordIPViolation counts a rate limit rejection of an address in a window that starts with the first one.
+const luaRecordIPViolation = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`
+
+// RecordIPViolation counts a rate limit rejection of ip and returns the rejections within window.
+func (c *Config) RecordIPViolation(ctx context.Context, ip string, window time.Duration) (int64, error) {
+	return c.client.Eval(ctx, luaRecordIPViolation, []string{"ip_violations:" + ip}, window.Milliseconds()).Int64()
+}
+
+// ResetIPViolations forgets the rate limit rejections of ip.
+func (c *Config) ResetIPViolations(ctx context.Context, ip string) error {
+	return c.client.Del(ctx, "ip_violations:"+ip).Err()
+}
@@ -2,11 +2,19 @@ package redis
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidCode     = errors.New("invalid code")
 )
 
 type LoginState int
@@ -20,36 +28,37 @@ const (
 type UserLoginSession struct {
 	Tries       uint       `json:"tries"`
 	OtpID       uint       `json:"otp_id"`
-	Code        string     `json:"code"`
+	CodeHash    string     `json:"code_hash"`
 	PhoneNumber string     `json:"phone_number"`
 	State       LoginState `json:"state"`
 }
 
-func (c *Config) SetUserLoginSession(ctx context.Context, key string, session *UserLoginSession) error {
+func (c *Config) SetUserLoginSession(ctx context.Context, key string, session *UserLoginSession, ttl time.Duration) error {
 	data, err := json.Marshal(session)
 	if err != nil {
 		return err
 	}
 
-	// Set with 3-minute expiration
-	return c.client.Set(ctx, key, data, 3*time.Minute).Err()
+	return c.client.Set(ctx, key, data, ttl).Err()
 }
 
-func (c *Config) CreateUserLoginSession(ctx context.Context, otpID uint, code string) (string, error) {
+func (c *Config) CreateUserLoginSession(ctx context.Context, otpID uint, codeHash string, policy models.OtpPolicy) (string, error) {
 	key := uuid.New().String()
 
 	session := &UserLoginSession{
-		Tries: 0,
-		OtpID: otpID,
-		Code:  code,
-		State: LoginStateWaiting,
+		Tries:    0,
+		OtpID:    otpID,
+		CodeHash: codeHash,
+		State:    LoginStateWaiting,
 	}
 
-	return key, c.SetUserLoginSession(ctx, key, session)
+	return key, c.SetUserLoginSession(ctx, key, session, policy.SessionTTL)
 }
 
 const luaIncrementTries = `
 local key = KEYS[1]
+local maxTries = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
 local session = redis.call('GET', key)
 if not session then
     return nil
@@ -58,23 +67,24 @@ end
 local data = cjson.decode(session)
 data.tries = data.tries + 1
 
-if data.tries > 3 then
+if data.tries > maxTries then
     data.state = 2
 end
 
 local updated = cjson.encode(data)
-redis.call('SET', key, updated, 'EX', 180)
+redis.call('SET', key, updated, 'EX', ttl)
 return updated
 `
 
-func (c *Config) IncreaseUserLoginTries(ctx context.Context, key string) (*UserLoginSession, error) {
-	result, err := c.client.Eval(ctx, luaIncrementTries, []string{key}).Result()
+func (c *Config) IncreaseUserLoginTries(ctx context.Context, key string, policy models.OtpPolicy) (*UserLoginSession, error) {
+	ttl := int64(policy.SessionTTL / time.Second)
+	result, err := c.client.Eval(ctx, luaIncrementTries, []string{key}, policy.MaxAttempts, ttl).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	if result == nil {
-		return nil, errors.New("session not found")
+		return nil, ErrSessionNotFound
 	}
 
 	var session UserLoginSession
@@ -88,23 +98,23 @@ func (c *Config) IncreaseUserLoginTries(ctx context.Context, key string) (*UserL
 
 	switch session.State {
 	case LoginStateSuccess, LoginStateCorrupted:
-		return &session, errors.New("invalid code")
+		return &session, ErrInvalidCode
 	default:
 	}
 
 	return &session, nil
 }
 
-func (c *Config) CheckUserLoginCode(ctx context.Context, token, code string) (uint, error) {
-	session, err := c.IncreaseUserLoginTries(ctx, token)
+func (c *Config) CheckUserLoginCode(ctx context.Context, token, codeHash string, policy models.OtpPolicy) (uint, error) {
+	session, err := c.IncreaseUserLoginTries(ctx, token, policy)
 	if err != nil {
 		return 0, err
 	}
 
-	if session.Code != code {
-		return 0, errors.New("invalid code")
+	if subtle.ConstantTimeCompare([]byte(session.CodeHash), []byte(codeHash)) != 1 {
+		return 0, ErrInvalidCode
 	}
 
 	session.State = LoginStateSuccess
-	return session.OtpID, c.SetUserLoginSession(ctx, token, session)
+	return session.OtpID, c.SetUserLoginSession(ctx, token, session, policy.SessionTTL)
 }
@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"context"
+)
+
+// Publish sends message to every subscriber of channel.
+func (c *Config) Publish(ctx context.Context, channel string, message any) error {
+	return c.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe delivers the payload of every message published on channel until ctx is done.
+// The subscription reconnects on its own if the connection to Redis drops.
+func (c *Config) Subscribe(ctx context.Context, channel string) <-chan string {
+	pubsub := c.client.Subscribe(ctx, channel)
+	payloads := make(chan string)
+
+	go func() {
+		defer close(payloads)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case payloads <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return payloads
+}
@@ -2,30 +2,162 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 )
 
-func (c *Config) CheckRateLimit(ctx context.Context, key string, maxRequests int, windowSeconds int) (bool, int, error) {
-	pipe := c.client.Pipeline()
+// RateLimitAlgorithm selects how requests are counted against a limit.
+type RateLimitAlgorithm string
 
-	incr := pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, time.Duration(windowSeconds)*time.Second)
+const (
+	// RateLimitFixedWindow counts requests in consecutive windows starting at the first request.
+	// Cheap, but allows up to twice the limit around a window boundary.
+	RateLimitFixedWindow RateLimitAlgorithm = "fixed_window"
+	// RateLimitSlidingWindow keeps a log of the requests made in the last window, enforcing the
+	// limit exactly at any point in time.
+	RateLimitSlidingWindow RateLimitAlgorithm = "sliding_window"
+	// RateLimitTokenBucket refills the limit steadily over the window, allowing bursts of up to the
+	// limit while capping the long term rate.
+	RateLimitTokenBucket RateLimitAlgorithm = "token_bucket"
+)
 
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		return false, 0, err
+// RateLimitResult is the outcome of counting a request.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	ResetAt    time.Time     // When the full limit is available again
+	RetryAfter time.Duration // How long a rejected client must wait, zero when allowed
+}
+
+// luaFixedWindow counts a request in the current window, which expires a window after its first request.
+const luaFixedWindow = `
+local key = KEYS[1]
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+
+local count = redis.call('INCR', key)
+if count == 1 then
+    redis.call('PEXPIRE', key, window)
+end
+local ttl = redis.call('PTTL', key)
+if ttl < 0 then
+    redis.call('PEXPIRE', key, window)
+    ttl = window
+end
+
+local allowed = 0
+local retryAfter = ttl
+if count <= limit then
+    allowed = 1
+    retryAfter = 0
+end
+return {allowed, math.max(limit - count, 0), ttl, retryAfter}
+`
+
+// luaSlidingWindow logs the request in a sorted set scored by time after dropping entries older than
+// a window. Rejected requests are not logged, so a client that keeps retrying is not punished further.
+const luaSlidingWindow = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+    redis.call('ZADD', key, now, ARGV[4])
+    redis.call('PEXPIRE', key, window)
+    count = count + 1
+    allowed = 1
+end
+
+local retryAfter = 0
+if allowed == 0 then
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    retryAfter = tonumber(oldest[2]) + window - now
+end
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+local reset = tonumber(newest[2]) + window - now
+return {allowed, limit - count, reset, retryAfter}
+`
+
+// luaTokenBucket refills the bucket for the time elapsed since the last request, at limit tokens per
+// window, then takes a token if one is available.
+const luaTokenBucket = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local rate = limit / window
+
+local state = redis.call('HMGET', key, 'tokens', 'updated_at')
+local tokens = tonumber(state[1]) or limit
+local updatedAt = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(now - updatedAt, 0) * rate)
+
+local allowed = 0
+local retryAfter = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+else
+    retryAfter = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'updated_at', now)
+redis.call('PEXPIRE', key, window)
+return {allowed, math.floor(tokens), math.ceil((limit - tokens) / rate), retryAfter}
+`
+
+var rateLimitScripts = map[RateLimitAlgorithm]string{
+	RateLimitFixedWindow:   luaFixedWindow,
+	RateLimitSlidingWindow: luaSlidingWindow,
+	RateLimitTokenBucket:   luaTokenBucket,
+}
+
+// IsValid reports whether a is a known algorithm.
+func (a RateLimitAlgorithm) IsValid() bool {
+	_, ok := rateLimitScripts[a]
+	return ok
+}
+
+// CheckRateLimit atomically counts a request against key, allowing maxRequests per window with algorithm.
+func (c *Config) CheckRateLimit(ctx context.Context, algorithm RateLimitAlgorithm, key string, maxRequests int, window time.Duration) (RateLimitResult, error) {
+	script, ok := rateLimitScripts[algorithm]
+	if !ok {
+		return RateLimitResult{}, fmt.Errorf("unknown rate limit algorithm: %s", algorithm)
+	}
+
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return RateLimitResult{}, err
 	}
 
-	count := incr.Val()
-	remaining := maxRequests - int(count)
-	if remaining < 0 {
-		remaining = 0
+	now := time.Now()
+	values, err := c.client.Eval(ctx, script, []string{key},
+		now.UnixMilli(), maxRequests, window.Milliseconds(), hex.EncodeToString(member)).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return RateLimitResult{}, errors.New("unexpected result from Redis Lua script")
 	}
 
-	return count <= int64(maxRequests), remaining, nil
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(max(values[1], 0)),
+		ResetAt:    now.Add(time.Duration(max(values[2], 0)) * time.Millisecond),
+		RetryAfter: time.Duration(max(values[3], 0)) * time.Millisecond,
+	}, nil
 }
 
-func (c *Config) GetRateLimitKey(prefix, identifier string) string {
-	return fmt.Sprintf("rate_limit:%s:%s", prefix, identifier)
+// GetRateLimitKey returns the key counting requests of identifier. Each algorithm stores a different
+// Redis type, so it is part of the key.
+func (c *Config) GetRateLimitKey(algorithm RateLimitAlgorithm, prefix, identifier string) string {
+	return fmt.Sprintf("rate_limit:%s:%s:%s", algorithm, prefix, identifier)
 }
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type SessionState string
+
+const (
+	SessionStateUnknown SessionState = ""
+	SessionStateActive  SessionState = "active"
+	SessionStateRevoked SessionState = "revoked"
+)
+
+func sessionKey(jti string) string {
+	return "session:" + jti
+}
+
+// GetSessionState returns the cached state of a session, or SessionStateUnknown on a cache miss.
+func (c *Config) GetSessionState(ctx context.Context, jti string) (SessionState, error) {
+	state, err := c.client.Get(ctx, sessionKey(jti)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return SessionStateUnknown, nil
+		}
+		return SessionStateUnknown, err
+	}
+	return SessionState(state), nil
+}
+
+// CacheActiveSession caches an active session unless a state is already cached,
+// so a lookup racing with a revocation can never overwrite the revoked marker.
+func (c *Config) CacheActiveSession(ctx context.Context, jti string, ttl time.Duration) error {
+	return c.client.SetNX(ctx, sessionKey(jti), string(SessionStateActive), ttl).Err()
+}
+
+// MarkSessionRevoked caches the session as revoked. ttl should cover the lifetime of any access token of the session.
+func (c *Config) MarkSessionRevoked(ctx context.Context, jti string, ttl time.Duration) error {
+	return c.client.Set(ctx, sessionKey(jti), string(SessionStateRevoked), ttl).Err()
+}
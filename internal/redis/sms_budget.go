@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// SmsBudgetRejection names the budget that refused an OTP text message.
+type SmsBudgetRejection int
+
+const (
+	SmsBudgetAvailable SmsBudgetRejection = iota
+	SmsCountryBudgetExhausted
+	SmsPrefixBudgetExhausted
+	SmsDailySpendCapReached
+	SmsSequentialBurst
+)
+
+// SmsBudget is the outcome of reserving an OTP text message against the SMS budgets.
+type SmsBudget struct {
+	Rejection  SmsBudgetRejection
+	RetryAfter time.Duration // How long until the refusing budget frees up, zero when reserved
+	keys       []string
+	cost       uint
+}
+
+// Reserved reports whether the message was counted against every budget.
+func (b SmsBudget) Reserved() bool {
+	return b.Rejection == SmsBudgetAvailable
+}
+
+// luaReserveSmsBudget checks every budget before counting the message against any of them, so a
+// rejected message uses up none. The hourly counters and the daily spend expire at the end of their
+// UTC hour and day; the sequential burst log keeps the distinct numbers of a block seen in the window.
+const luaReserveSmsBudget = `
+local now = tonumber(ARGV[1])
+local countryLimit = tonumber(ARGV[2])
+local prefixLimit = tonumber(ARGV[3])
+local hourLeft = tonumber(ARGV[4])
+local cost = tonumber(ARGV[5])
+local spendCap = tonumber(ARGV[6])
+local dayLeft = tonumber(ARGV[7])
+local sequentialLimit = tonumber(ARGV[8])
+local sequentialWindow = tonumber(ARGV[9])
+local number = ARGV[10]
+
+if countryLimit > 0 and tonumber(redis.call('GET', KEYS[1]) or '0') >= countryLimit then
+    return {1, hourLeft}
+end
+if prefixLimit > 0 and tonumber(redis.call('GET', KEYS[2]) or '0') >= prefixLimit then
+    return {2, hourLeft}
+end
+if spendCap > 0 and tonumber(redis.call('GET', KEYS[3]) or '0') + cost > spendCap then
+    return {3, dayLeft}
+end
+if sequentialLimit > 0 then
+    redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', now - sequentialWindow)
+    if not redis.call('ZSCORE', KEYS[4], number) and redis.call('ZCARD', KEYS[4]) >= sequentialLimit then
+        local oldest = redis.call('ZRANGE', KEYS[4], 0, 0, 'WITHSCORES')
+        return {4, tonumber(oldest[2]) + sequentialWindow - now}
+    end
+end
+
+if countryLimit > 0 then
+    redis.call('INCR', KEYS[1])
+    redis.call('PEXPIRE', KEYS[1], hourLeft)
+end
+if prefixLimit > 0 then
+    redis.call('INCR', KEYS[2])
+    redis.call('PEXPIRE', KEYS[2], hourLeft)
+end
+if spendCap > 0 then
+    redis.call('INCRBY', KEYS[3], cost)
+    redis.call('PEXPIRE', KEYS[3], dayLeft)
+end
+if sequentialLimit > 0 then
+    redis.call('ZADD', KEYS[4], now, number)
+    redis.call('PEXPIRE', KEYS[4], sequentialWindow)
+end
+return {0, 0}
+`
+
+// luaReleaseSmsBudget gives back a reservation, leaving counters that have since expired alone.
+const luaReleaseSmsBudget = `
+local amounts = {1, 1, tonumber(ARGV[1])}
+for i, key in ipairs(KEYS) do
+    if redis.call('EXISTS', key) == 1 and tonumber(redis.call('GET', key)) >= amounts[i] then
+        redis.call('DECRBY', key, amounts[i])
+    end
+end
+return 0
+`
+
+// ReserveSmsBudget atomically counts an OTP text message to number, whose calling code is country,
+// against the hourly country and prefix budgets, the daily spend cap and the sequential burst limit
+// of policy. Nothing is counted when any of them refuses the message.
+func (c *Config) ReserveSmsBudget(ctx context.Context, number, country string, policy models.SmsFraudPolicy) (SmsBudget, error) {
+	now := time.Now().UTC()
+	hour := now.Truncate(time.Hour)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	prefix := number[:min(policy.PrefixLength, len(number))]
+	block := number[:max(len(number)-2, 0)]
+
+	keys := []string{
+		fmt.Sprintf("sms_budget:country:%s:%d", country, hour.Unix()),
+		fmt.Sprintf("sms_budget:prefix:%s:%d", prefix, hour.Unix()),
+		"sms_budget:spend:" + day.Format(time.DateOnly),
+		"sms_budget:sequence:" + block,
+	}
+	cost := policy.CostOf(number)
+
+	values, err := c.client.Eval(ctx, luaReserveSmsBudget, keys,
+		now.UnixMilli(),
+		policy.CountryHourlyLimit,
+		policy.PrefixHourlyLimit,
+		hour.Add(time.Hour).Sub(now).Milliseconds(),
+		cost,
+		policy.DailySpendCap,
+		day.AddDate(0, 0, 1).Sub(now).Milliseconds(),
+		policy.SequentialLimit,
+		policy.SequentialWindow.Milliseconds(),
+		number,
+	).Int64Slice()
+	if err != nil {
+		return SmsBudget{}, err
+	}
+	if len(values) != 2 {
+		return SmsBudget{}, errors.New("unexpected result from Redis Lua script")
+	}
+
+	return SmsBudget{
+		Rejection:  SmsBudgetRejection(values[0]),
+		RetryAfter: time.Duration(max(values[1], 0)) * time.Millisecond,
+		keys:       keys[:3],
+		cost:       cost,
+	}, nil
+}
+
+// ReleaseSmsBudget gives back the hourly budgets and spend of a reserved message that was not sent.
+// The number stays in the sequential burst log, as the request for it was still made.
+func (c *Config) ReleaseSmsBudget(ctx context.Context, budget SmsBudget) error {
+	if !budget.Reserved() || len(budget.keys) == 0 {
+		return nil
+	}
+	return c.client.Eval(ctx, luaReleaseSmsBudget, budget.keys, budget.cost).Err()
+}
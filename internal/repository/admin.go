@@ -2,11 +2,13 @@ package repository
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/MoSed3/otp-server/internal/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/MoSed3/otp-server/internal/password"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AdminUpdate struct for partial updates
@@ -20,22 +22,34 @@ type AdminUpdate struct {
 type Admin interface {
 	Create(tx *gorm.DB, username, password string, role models.AdminRole) (*models.Admin, error)
 	Update(tx *gorm.DB, adminID uint, updates AdminUpdate) error
+	UpdatePasswordHash(tx *gorm.DB, adminID uint, hashedPassword string) error
 	Delete(tx *gorm.DB, adminID uint) error
 	GetByUsername(tx *gorm.DB, username string) (*models.Admin, error)
+	UsernameExists(tx *gorm.DB, username string) (bool, error)
 	GetByID(tx *gorm.DB, adminID uint) (*models.Admin, error)
 	ListAll(tx *gorm.DB) ([]models.Admin, error)
+	ListByRoleForUpdate(tx *gorm.DB, role models.AdminRole) ([]models.Admin, error)
 }
 
 // gormAdmin implements Admin.
-type gormAdmin struct{}
+type gormAdmin struct {
+	passwordPolicy *password.Policy
+	passwordHasher *password.Hasher
+}
 
-// NewAdmin creates a new instance of gormAdmin.
-func NewAdmin() Admin {
-	return &gormAdmin{}
+// NewAdmin creates a new instance of gormAdmin. Passwords given to Create and Update must satisfy
+// passwordPolicy, otherwise an error wrapping password.ErrRejected is returned, and are stored hashed
+// by passwordHasher.
+func NewAdmin(passwordPolicy *password.Policy, passwordHasher *password.Hasher) Admin {
+	return &gormAdmin{passwordPolicy: passwordPolicy, passwordHasher: passwordHasher}
 }
 
-func (r *gormAdmin) Create(tx *gorm.DB, username, password string, role models.AdminRole) (*models.Admin, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (r *gormAdmin) Create(tx *gorm.DB, username, newPassword string, role models.AdminRole) (*models.Admin, error) {
+	if err := r.passwordPolicy.Check(username, newPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := r.passwordHasher.Hash(newPassword)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +57,7 @@ func (r *gormAdmin) Create(tx *gorm.DB, username, password string, role models.A
 	admin := &models.Admin{
 		Username:       username,
 		Role:           role,
-		HashedPassword: string(hashedPassword),
+		HashedPassword: hashedPassword,
 	}
 
 	if err = tx.Create(admin).Error; err != nil {
@@ -68,11 +82,21 @@ func (r *gormAdmin) Update(tx *gorm.DB, adminID uint, updates AdminUpdate) error
 		admin.Role = *updates.Role
 	}
 	if updates.NewPassword != nil {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*updates.NewPassword), bcrypt.DefaultCost)
+		if err := r.passwordPolicy.Check(admin.Username, *updates.NewPassword); err != nil {
+			return err
+		}
+		if err := r.checkPasswordReuse(tx, admin, *updates.NewPassword); err != nil {
+			return err
+		}
+		if err := r.addPasswordHistory(tx, admin); err != nil {
+			return err
+		}
+
+		hashedPassword, err := r.passwordHasher.Hash(*updates.NewPassword)
 		if err != nil {
 			return err
 		}
-		admin.HashedPassword = string(hashedPassword)
+		admin.HashedPassword = hashedPassword
 		admin.PasswordResetAt.Time = time.Now().UTC()
 		admin.PasswordResetAt.Valid = true
 	}
@@ -83,6 +107,66 @@ func (r *gormAdmin) Update(tx *gorm.DB, adminID uint, updates AdminUpdate) error
 	return nil
 }
 
+// UpdatePasswordHash replaces the stored hash of the same password, such as after upgrading its
+// algorithm. Unlike setting a new password through Update, it does not log the admin out.
+func (r *gormAdmin) UpdatePasswordHash(tx *gorm.DB, adminID uint, hashedPassword string) error {
+	result := tx.Model(&models.Admin{}).Where("id = ?", adminID).Update("hashed_password", hashedPassword)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("admin user not found")
+	}
+	return nil
+}
+
+// checkPasswordReuse rejects newPassword when it matches one of the last HistorySize passwords of
+// admin, counting the current one.
+func (r *gormAdmin) checkPasswordReuse(tx *gorm.DB, admin *models.Admin, newPassword string) error {
+	if r.passwordPolicy.HistorySize == 0 {
+		return nil
+	}
+
+	hashes := []string{admin.HashedPassword}
+	if r.passwordPolicy.HistorySize > 1 {
+		var history []models.AdminPasswordHistory
+		if err := tx.Where("admin_id = ?", admin.ID).
+			Order("id DESC").
+			Limit(r.passwordPolicy.HistorySize - 1).
+			Find(&history).Error; err != nil {
+			return err
+		}
+		for _, h := range history {
+			hashes = append(hashes, h.HashedPassword)
+		}
+	}
+
+	for _, hash := range hashes {
+		if match, _ := r.passwordHasher.Verify(hash, newPassword); match {
+			return fmt.Errorf("%w: must not match any of the last %d passwords", password.ErrRejected, r.passwordPolicy.HistorySize)
+		}
+	}
+	return nil
+}
+
+// addPasswordHistory keeps the current password hash of admin, which is about to be replaced, and
+// drops the entries that fall out of the reuse window.
+func (r *gormAdmin) addPasswordHistory(tx *gorm.DB, admin *models.Admin) error {
+	keep := r.passwordPolicy.HistorySize - 1
+	if keep > 0 {
+		if err := tx.Create(&models.AdminPasswordHistory{AdminID: admin.ID, HashedPassword: admin.HashedPassword}).Error; err != nil {
+			return err
+		}
+	}
+
+	stale := tx.Model(&models.AdminPasswordHistory{}).
+		Select("id").
+		Where("admin_id = ?", admin.ID).
+		Order("id DESC").
+		Offset(max(keep, 0))
+	return tx.Where("id IN (?)", stale).Delete(&models.AdminPasswordHistory{}).Error
+}
+
 func (r *gormAdmin) Delete(tx *gorm.DB, adminID uint) error {
 	result := tx.Where("id = ?", adminID).Delete(&models.Admin{})
 	if result.Error != nil {
@@ -105,6 +189,16 @@ func (r *gormAdmin) GetByUsername(tx *gorm.DB, username string) (*models.Admin,
 	return &admin, nil
 }
 
+// UsernameExists reports whether any admin, deleted ones included, has username.
+// Deleted admins keep their row, so their username stays taken.
+func (r *gormAdmin) UsernameExists(tx *gorm.DB, username string) (bool, error) {
+	var count int64
+	if err := tx.Unscoped().Model(&models.Admin{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (r *gormAdmin) GetByID(tx *gorm.DB, adminID uint) (*models.Admin, error) {
 	var admin models.Admin
 	if err := tx.Where("id = ?", adminID).First(&admin).Error; err != nil {
@@ -123,3 +217,12 @@ func (r *gormAdmin) ListAll(tx *gorm.DB) ([]models.Admin, error) {
 	}
 	return admins, nil
 }
+
+// ListByRoleForUpdate locks and returns the admins with role, serializing concurrent role changes.
+func (r *gormAdmin) ListByRoleForUpdate(tx *gorm.DB, role models.AdminRole) ([]models.Admin, error) {
+	var admins []models.Admin
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("role = ?", role).Find(&admins).Error; err != nil {
+		return nil, err
+	}
+	return admins, nil
+}
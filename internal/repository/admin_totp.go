@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// AdminTotp defines the interface for admin TOTP secret data access operations.
+type AdminTotp interface {
+	GetByAdminID(tx *gorm.DB, adminID uint) (*models.AdminTotpSecret, error)
+	Create(tx *gorm.DB, adminID uint, encryptedSecret string) (*models.AdminTotpSecret, error)
+	Confirm(tx *gorm.DB, secret *models.AdminTotpSecret, step int64) error
+	UseStep(tx *gorm.DB, secret *models.AdminTotpSecret, step int64) (bool, error)
+	DeleteByAdminID(tx *gorm.DB, adminID uint) error
+}
+
+// gormAdminTotp implements AdminTotp using GORM.
+type gormAdminTotp struct{}
+
+// NewAdminTotp creates a new instance of gormAdminTotp.
+func NewAdminTotp() AdminTotp {
+	return &gormAdminTotp{}
+}
+
+func (r *gormAdminTotp) GetByAdminID(tx *gorm.DB, adminID uint) (*models.AdminTotpSecret, error) {
+	var secret models.AdminTotpSecret
+	if err := tx.Where("admin_id = ?", adminID).First(&secret).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not enrolled
+		}
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Create stores a new unconfirmed secret, replacing any previous one for the admin.
+func (r *gormAdminTotp) Create(tx *gorm.DB, adminID uint, encryptedSecret string) (*models.AdminTotpSecret, error) {
+	if err := r.DeleteByAdminID(tx, adminID); err != nil {
+		return nil, err
+	}
+
+	secret := &models.AdminTotpSecret{
+		AdminID:         adminID,
+		EncryptedSecret: encryptedSecret,
+	}
+	return secret, tx.Create(secret).Error
+}
+
+func (r *gormAdminTotp) Confirm(tx *gorm.DB, secret *models.AdminTotpSecret, step int64) error {
+	secret.ConfirmedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	secret.LastUsedStep = step
+	return tx.Save(secret).Error
+}
+
+// UseStep atomically records step as used. It returns false if the step, or a later one, was already used.
+func (r *gormAdminTotp) UseStep(tx *gorm.DB, secret *models.AdminTotpSecret, step int64) (bool, error) {
+	result := tx.Model(&models.AdminTotpSecret{}).
+		Where("id = ? AND last_used_step < ?", secret.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	secret.LastUsedStep = step
+	return true, nil
+}
+
+func (r *gormAdminTotp) DeleteByAdminID(tx *gorm.DB, adminID uint) error {
+	return tx.Unscoped().Where("admin_id = ?", adminID).Delete(&models.AdminTotpSecret{}).Error
+}
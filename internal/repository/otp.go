@@ -1,9 +1,8 @@
 package repository
 
 import (
-	"crypto/rand"
 	"errors"
-	"math/big"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,10 +10,20 @@ import (
 	"github.com/MoSed3/otp-server/internal/models"
 )
 
+// ErrOtpThrottled is returned when a user requests OTPs faster than the throttling policy allows.
+type ErrOtpThrottled struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ErrOtpThrottled) Error() string {
+	return fmt.Sprintf("%s, retry after %d seconds", e.Reason, int(e.RetryAfter.Seconds()))
+}
+
 // Otp defines the interface for OTP data access operations.
 type Otp interface {
 	GetByID(tx *gorm.DB, id uint) (*models.UserOtp, error)
-	Create(tx *gorm.DB, user *models.User) (*models.UserOtp, error)
+	Create(tx *gorm.DB, user *models.User, codeHash string, throttle models.OtpThrottlePolicy) (*models.UserOtp, error)
 	GetUserByOtpID(tx *gorm.DB, otpID uint) (*models.User, error)
 }
 
@@ -31,47 +40,78 @@ func (r *gormOtp) GetByID(tx *gorm.DB, id uint) (*models.UserOtp, error) {
 	return otp, tx.Where(otp).Find(otp).Error
 }
 
-func generateOTPCode() string {
-	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	code := make([]byte, 6)
-	for i := range code {
-		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
-		code[i] = chars[n.Int64()]
-	}
-	return string(code)
-}
-
-func (r *gormOtp) Create(tx *gorm.DB, user *models.User) (*models.UserOtp, error) {
+// checkThrottle returns ErrOtpThrottled carrying the longest wait imposed by the cooldown or any exceeded tier.
+func (r *gormOtp) checkThrottle(tx *gorm.DB, userID uint, throttle models.OtpThrottlePolicy) error {
 	now := time.Now().UTC()
-	twoMinutesAgo := now.Add(-2 * time.Minute)
-	tenMinutesAgo := now.Add(-10 * time.Minute)
-
-	var recentOtp models.UserOtp
-	err := tx.Where("user_id = ? AND created_at > ? AND used_at IS NULL", user.ID, twoMinutesAgo).First(&recentOtp).Error
+	var throttled *ErrOtpThrottled
+
+	if throttle.Cooldown > 0 {
+		var recentOtp models.UserOtp
+		err := tx.Where("user_id = ? AND created_at > ? AND used_at IS NULL", userID, now.Add(-throttle.Cooldown)).
+			Order("created_at DESC").
+			First(&recentOtp).Error
+
+		switch {
+		case err == nil:
+			throttled = &ErrOtpThrottled{
+				Reason:     fmt.Sprintf("user has an unused OTP issued within the last %d seconds", int(throttle.Cooldown.Seconds())),
+				RetryAfter: recentOtp.CreatedAt.Add(throttle.Cooldown).Sub(now),
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+	}
 
-	switch {
-	case err == nil:
-		return nil, errors.New("user has generated OTP within the last 2 minutes")
-	case !errors.Is(err, gorm.ErrRecordNotFound):
-		return nil, err
+	for _, tier := range throttle.Tiers {
+		window := time.Duration(tier.Window) * time.Second
+		windowStart := now.Add(-window)
+
+		var otpCount int64
+		err := tx.Model(&models.UserOtp{}).Where("user_id = ? AND created_at > ?", userID, windowStart).Count(&otpCount).Error
+		switch {
+		case err != nil:
+			return err
+		case otpCount < int64(tier.Limit):
+			continue
+		}
+
+		// The tier frees up once enough OTPs fall out of the window to get back under the limit.
+		var oldest models.UserOtp
+		err = tx.Where("user_id = ? AND created_at > ?", userID, windowStart).
+			Order("created_at ASC").
+			Offset(int(otpCount) - int(tier.Limit)).
+			First(&oldest).Error
+		if err != nil {
+			return err
+		}
+
+		retryAfter := oldest.CreatedAt.Add(window).Sub(now)
+		if throttled == nil || retryAfter > throttled.RetryAfter {
+			throttled = &ErrOtpThrottled{
+				Reason:     fmt.Sprintf("user has exceeded the maximum of %d OTPs within %d seconds", tier.Limit, tier.Window),
+				RetryAfter: retryAfter,
+			}
+		}
 	}
 
-	var otpCount int64
-	err = tx.Model(&models.UserOtp{}).Where("user_id = ? AND created_at > ?", user.ID, tenMinutesAgo).Count(&otpCount).Error
+	if throttled != nil {
+		throttled.RetryAfter = max(throttled.RetryAfter, time.Second)
+		return throttled
+	}
+	return nil
+}
 
-	switch {
-	case err != nil:
+func (r *gormOtp) Create(tx *gorm.DB, user *models.User, codeHash string, throttle models.OtpThrottlePolicy) (*models.UserOtp, error) {
+	if err := r.checkThrottle(tx, user.ID, throttle); err != nil {
 		return nil, err
-	case otpCount >= 3:
-		return nil, errors.New("user has exceeded maximum OTP limit (5) within the last hour")
 	}
 
 	otp := &models.UserOtp{
-		Code: generateOTPCode(),
-		User: user,
+		CodeHash: codeHash,
+		User:     user,
 	}
 
-	err = tx.Create(otp).Error
+	err := tx.Create(otp).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// OtpDelivery defines the interface for the OTP delivery queue.
+type OtpDelivery interface {
+	Enqueue(tx *gorm.DB, otp *models.UserOtp, phoneNumber, codeCiphertext string, metadata map[string]string) (*models.OtpDelivery, error)
+	ClaimNext(tx *gorm.DB, lease time.Duration) (*models.OtpDelivery, error)
+	MarkSent(tx *gorm.DB, d *models.OtpDelivery) error
+	MarkFailed(tx *gorm.DB, d *models.OtpDelivery, deliveryErr error, nextAttemptAt time.Time) error
+	MarkDead(tx *gorm.DB, d *models.OtpDelivery, deliveryErr error) error
+	GetByOtpID(tx *gorm.DB, otpID uint) (*models.OtpDelivery, error)
+}
+
+// gormOtpDelivery implements OtpDelivery using GORM.
+type gormOtpDelivery struct{}
+
+// NewOtpDelivery creates a new instance of gormOtpDelivery.
+func NewOtpDelivery() OtpDelivery {
+	return &gormOtpDelivery{}
+}
+
+// Enqueue queues the delivery of otp. The code is stored only as codeCiphertext, which the worker
+// decrypts when sending.
+func (r *gormOtpDelivery) Enqueue(tx *gorm.DB, otp *models.UserOtp, phoneNumber, codeCiphertext string, metadata map[string]string) (*models.OtpDelivery, error) {
+	d := &models.OtpDelivery{
+		OtpID:          otp.ID,
+		PhoneNumber:    phoneNumber,
+		CodeCiphertext: codeCiphertext,
+		Metadata:       metadata,
+		Status:         models.OtpDeliveryPending,
+		NextAttemptAt:  time.Now().UTC(),
+	}
+	return d, tx.Create(d).Error
+}
+
+// ClaimNext takes the oldest due delivery, skipping rows locked by other workers, and counts the attempt.
+// The delivery is not due again until lease has passed, so once tx commits no other worker picks it up
+// while it is being sent, and it is retried if the result is never recorded. Returns nil when nothing is due.
+func (r *gormOtpDelivery) ClaimNext(tx *gorm.DB, lease time.Duration) (*models.OtpDelivery, error) {
+	var deliveries []models.OtpDelivery
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", models.OtpDeliveryPending, time.Now().UTC()).
+		Order("next_attempt_at").
+		Limit(1).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	d := &deliveries[0]
+	d.Attempts++
+	d.NextAttemptAt = time.Now().UTC().Add(lease)
+	return d, tx.Save(d).Error
+}
+
+func (r *gormOtpDelivery) MarkSent(tx *gorm.DB, d *models.OtpDelivery) error {
+	d.Status = models.OtpDeliverySent
+	d.CodeCiphertext = "" // Drop the code once it is no longer needed
+	d.LastError = ""
+	d.SentAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return tx.Save(d).Error
+}
+
+func (r *gormOtpDelivery) MarkFailed(tx *gorm.DB, d *models.OtpDelivery, deliveryErr error, nextAttemptAt time.Time) error {
+	d.LastError = deliveryErr.Error()
+	d.NextAttemptAt = nextAttemptAt
+	return tx.Save(d).Error
+}
+
+func (r *gormOtpDelivery) MarkDead(tx *gorm.DB, d *models.OtpDelivery, deliveryErr error) error {
+	d.Status = models.OtpDeliveryDead
+	d.CodeCiphertext = ""
+	d.LastError = deliveryErr.Error()
+	return tx.Save(d).Error
+}
+
+func (r *gormOtpDelivery) GetByOtpID(tx *gorm.DB, otpID uint) (*models.OtpDelivery, error) {
+	d := &models.OtpDelivery{}
+	return d, tx.Where("otp_id = ?", otpID).First(d).Error
+}
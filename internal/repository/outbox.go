@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// Outbox defines the interface for transactional outbox operations.
+type Outbox interface {
+	Add(tx *gorm.DB, eventType string, payload any) (*models.OutboxEvent, error)
+	ClaimNext(tx *gorm.DB, lease time.Duration) (*models.OutboxEvent, error)
+	Save(tx *gorm.DB, event *models.OutboxEvent) error
+}
+
+// gormOutbox implements Outbox using GORM.
+type gormOutbox struct{}
+
+// NewOutbox creates a new instance of gormOutbox.
+func NewOutbox() Outbox {
+	return &gormOutbox{}
+}
+
+func (r *gormOutbox) Add(tx *gorm.DB, eventType string, payload any) (*models.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+
+	now := time.Now().UTC()
+	event := &models.OutboxEvent{
+		CreatedAt:     now,
+		Type:          eventType,
+		Payload:       string(data),
+		NextAttemptAt: now,
+	}
+	return event, tx.Create(event).Error
+}
+
+// ClaimNext takes the oldest unpublished event that is due, skipping rows locked by other relays. The
+// event is not due again until lease has passed, so once tx commits no other relay picks it up while it
+// is being published, and it is retried if the outcome is never recorded. Returns nil when nothing is due.
+func (r *gormOutbox) ClaimNext(tx *gorm.DB, lease time.Duration) (*models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("published_at IS NULL AND next_attempt_at <= ?", time.Now().UTC()).
+		Order("id").
+		Limit(1).
+		Find(&events).Error
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+
+	event := &events[0]
+	event.NextAttemptAt = time.Now().UTC().Add(lease)
+	return event, tx.Save(event).Error
+}
+
+func (r *gormOutbox) Save(tx *gorm.DB, event *models.OutboxEvent) error {
+	return tx.Save(event).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// RefreshToken defines the interface for refresh token data access operations.
+type RefreshToken interface {
+	Create(tx *gorm.DB, token *models.RefreshToken) error
+	GetByHashForUpdate(tx *gorm.DB, tokenHash string) (*models.RefreshToken, error)
+	MarkRotated(tx *gorm.DB, token *models.RefreshToken) error
+	RevokeFamily(tx *gorm.DB, familyID string) error
+}
+
+// gormRefreshToken implements RefreshToken using GORM.
+type gormRefreshToken struct{}
+
+// NewRefreshToken creates a new instance of gormRefreshToken.
+func NewRefreshToken() RefreshToken {
+	return &gormRefreshToken{}
+}
+
+func (r *gormRefreshToken) Create(tx *gorm.DB, token *models.RefreshToken) error {
+	return tx.Create(token).Error
+}
+
+// GetByHashForUpdate loads the token and locks it so concurrent rotations are serialized.
+func (r *gormRefreshToken) GetByHashForUpdate(tx *gorm.DB, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Unknown token
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *gormRefreshToken) MarkRotated(tx *gorm.DB, token *models.RefreshToken) error {
+	token.RotatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return tx.Save(token).Error
+}
+
+func (r *gormRefreshToken) RevokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// Role defines the interface for admin role data access operations.
+type Role interface {
+	CreateBuiltin(tx *gorm.DB) error
+	Create(tx *gorm.DB, role *models.Role) error
+	Update(tx *gorm.DB, role *models.Role) error
+	Delete(tx *gorm.DB, role *models.Role) error
+	GetByID(tx *gorm.DB, roleID uint) (*models.Role, error)
+	GetByName(tx *gorm.DB, name string) (*models.Role, error)
+	ListAll(tx *gorm.DB) ([]models.Role, error)
+	CountAdmins(tx *gorm.DB, roleID uint) (int64, error)
+}
+
+// gormRole implements Role using GORM.
+type gormRole struct{}
+
+// NewRole creates a new instance of gormRole.
+func NewRole() Role {
+	return &gormRole{}
+}
+
+// CreateBuiltin inserts the built-in roles that do not exist yet, leaving edited ones untouched.
+func (r *gormRole) CreateBuiltin(tx *gorm.DB) error {
+	roles := make([]models.Role, len(models.BuiltinRoles))
+	copy(roles, models.BuiltinRoles)
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&roles).Error; err != nil {
+		return err
+	}
+	// The built-in roles are inserted with explicit IDs, move the sequence past them.
+	return tx.Exec("SELECT setval(pg_get_serial_sequence('roles', 'id'), GREATEST((SELECT MAX(id) FROM roles), 1))").Error
+}
+
+func (r *gormRole) Create(tx *gorm.DB, role *models.Role) error {
+	return tx.Create(role).Error
+}
+
+func (r *gormRole) Update(tx *gorm.DB, role *models.Role) error {
+	return tx.Save(role).Error
+}
+
+func (r *gormRole) Delete(tx *gorm.DB, role *models.Role) error {
+	return tx.Delete(role).Error
+}
+
+func (r *gormRole) GetByID(tx *gorm.DB, roleID uint) (*models.Role, error) {
+	var role models.Role
+	if err := tx.Where("id = ?", roleID).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Role not found
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *gormRole) GetByName(tx *gorm.DB, name string) (*models.Role, error) {
+	var role models.Role
+	if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Role not found
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *gormRole) ListAll(tx *gorm.DB) ([]models.Role, error) {
+	var roles []models.Role
+	if err := tx.Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *gormRole) CountAdmins(tx *gorm.DB, roleID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.Admin{}).Where("role = ?", roleID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// Session defines the interface for session data access operations.
+type Session interface {
+	Create(tx *gorm.DB, session *models.Session) error
+	GetByJti(tx *gorm.DB, jti string) (*models.Session, error)
+	GetByIDForSubject(tx *gorm.DB, id, subjectID uint, audience int) (*models.Session, error)
+	ListActive(tx *gorm.DB, subjectID uint, audience int) ([]models.Session, error)
+	Touch(tx *gorm.DB, session *models.Session, ipAddress, deviceInfo string, expiresAt time.Time) error
+	Revoke(tx *gorm.DB, session *models.Session) error
+}
+
+// gormSession implements Session using GORM.
+type gormSession struct{}
+
+// NewSession creates a new instance of gormSession.
+func NewSession() Session {
+	return &gormSession{}
+}
+
+func (r *gormSession) Create(tx *gorm.DB, session *models.Session) error {
+	return tx.Create(session).Error
+}
+
+func (r *gormSession) GetByJti(tx *gorm.DB, jti string) (*models.Session, error) {
+	var session models.Session
+	err := tx.Where("jti = ?", jti).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Unknown session
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *gormSession) GetByIDForSubject(tx *gorm.DB, id, subjectID uint, audience int) (*models.Session, error) {
+	var session models.Session
+	err := tx.Where("id = ? AND subject_id = ? AND audience = ?", id, subjectID, audience).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found or owned by someone else
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *gormSession) ListActive(tx *gorm.DB, subjectID uint, audience int) ([]models.Session, error) {
+	var sessions []models.Session
+	err := tx.Where("subject_id = ? AND audience = ? AND revoked_at IS NULL AND expires_at > ?", subjectID, audience, time.Now().UTC()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch records a refresh of the session.
+func (r *gormSession) Touch(tx *gorm.DB, session *models.Session, ipAddress, deviceInfo string, expiresAt time.Time) error {
+	session.IPAddress = ipAddress
+	session.DeviceInfo = deviceInfo
+	session.LastSeenAt = time.Now().UTC()
+	session.ExpiresAt = expiresAt
+	return tx.Save(session).Error
+}
+
+func (r *gormSession) Revoke(tx *gorm.DB, session *models.Session) error {
+	now := time.Now().UTC()
+	session.RevokedAt.Time = now
+	session.RevokedAt.Valid = true
+	return tx.Model(session).Update("revoked_at", now).Error
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/MoSed3/otp-server/internal/models"
 )
@@ -15,6 +17,8 @@ type Setting interface {
 	Create(tx *gorm.DB) error
 	Update(tx *gorm.DB, setting *models.Setting) error
 	Get(tx *gorm.DB) (*models.Setting, error)
+	GetForUpdate(tx *gorm.DB) (*models.Setting, error)
+	RotateSecretKey(tx *gorm.DB, grace time.Duration) (*models.Setting, error)
 }
 
 // gormSetting implements Setting using GORM.
@@ -27,26 +31,37 @@ func NewSetting() Setting {
 
 // Create creates initial settings in the database
 func (r *gormSetting) Create(tx *gorm.DB) error {
-	randomBytes := make([]byte, 256)
-
-	_, err := rand.Read(randomBytes)
+	randomToken, err := generateSecretKey()
 	if err != nil {
-		return fmt.Errorf("failed to generate random bytes: %w", err)
+		return err
 	}
 
-	randomToken := base64.StdEncoding.EncodeToString(randomBytes)
-
 	newSetting := models.Setting{
-		SecretKey:         randomToken,
-		AccessTokenExpire: 1440,
+		Version:              1,
+		SecretKey:            randomToken,
+		SecretKeyGracePeriod: 1440,
+		AccessTokenExpire:    1440,
+		RefreshTokenExpire:   43200,
+		OtpLength:            6,
+		OtpNumericOnly:       false,
+		OtpMaxAttempts:       3,
+		OtpSessionTTL:        180,
+		OtpCooldown:          120,
+		OtpThrottleTiers:     models.DefaultOtpThrottleTiers,
 	}
 
 	return tx.Create(&newSetting).Error
 }
 
-// Update updates existing settings
+// Update updates existing settings and bumps their version in the database, so concurrent updates
+// never share a version. Read s with GetForUpdate so no other change is overwritten.
 func (r *gormSetting) Update(tx *gorm.DB, s *models.Setting) error {
-	return tx.Save(s).Error
+	if err := tx.Omit("Version").Save(s).Error; err != nil {
+		return err
+	}
+	return tx.Model(s).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}}).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error
 }
 
 // Get retrieves the settings (assuming single row)
@@ -57,3 +72,47 @@ func (r *gormSetting) Get(tx *gorm.DB) (*models.Setting, error) {
 	}
 	return &setting, nil
 }
+
+// GetForUpdate retrieves the settings and locks them until the transaction ends.
+func (r *gormSetting) GetForUpdate(tx *gorm.DB) (*models.Setting, error) {
+	var setting models.Setting
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&setting).Error; err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// RotateSecretKey generates a new primary secret key with a fresh kid, keeping the current one valid for grace.
+func (r *gormSetting) RotateSecretKey(tx *gorm.DB, grace time.Duration) (*models.Setting, error) {
+	setting, err := r.GetForUpdate(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := generateSecretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	kid := make([]byte, 12)
+	if _, err = rand.Read(kid); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	setting.RotateSecretKey(base64.RawURLEncoding.EncodeToString(kid), key, grace)
+	if err = r.Update(tx, setting); err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+func generateSecretKey() (string, error) {
+	randomBytes := make([]byte, 256)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(randomBytes), nil
+}
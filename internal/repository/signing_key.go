@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// SigningKey defines the interface for JWT signing key data access operations.
+type SigningKey interface {
+	Create(tx *gorm.DB, key *models.SigningKey) error
+	GetByKid(tx *gorm.DB, kid string) (*models.SigningKey, error)
+	ListAll(tx *gorm.DB) ([]models.SigningKey, error)
+	DemoteActive(tx *gorm.DB) error
+	Retire(tx *gorm.DB, key *models.SigningKey) error
+	ReplacePrivateKey(tx *gorm.DB, key *models.SigningKey, privateKey string) error
+}
+
+// gormSigningKey implements SigningKey using GORM.
+type gormSigningKey struct{}
+
+// NewSigningKey creates a new instance of gormSigningKey.
+func NewSigningKey() SigningKey {
+	return &gormSigningKey{}
+}
+
+func (r *gormSigningKey) Create(tx *gorm.DB, key *models.SigningKey) error {
+	return tx.Create(key).Error
+}
+
+func (r *gormSigningKey) GetByKid(tx *gorm.DB, kid string) (*models.SigningKey, error) {
+	var key models.SigningKey
+	if err := tx.Where("kid = ?", kid).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *gormSigningKey) ListAll(tx *gorm.DB) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := tx.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// DemoteActive moves the active key to retiring so a new key can take over.
+func (r *gormSigningKey) DemoteActive(tx *gorm.DB) error {
+	return tx.Model(&models.SigningKey{}).
+		Where("status = ?", models.SigningKeyActive).
+		Update("status", models.SigningKeyRetiring).Error
+}
+
+func (r *gormSigningKey) Retire(tx *gorm.DB, key *models.SigningKey) error {
+	key.Status = models.SigningKeyRetired
+	key.RetiredAt.Time = time.Now().UTC()
+	key.RetiredAt.Valid = true
+	return tx.Save(key).Error
+}
+
+// ReplacePrivateKey stores privateKey as the private key of key, unless the stored one has changed since key was read.
+func (r *gormSigningKey) ReplacePrivateKey(tx *gorm.DB, key *models.SigningKey, privateKey string) error {
+	err := tx.Model(&models.SigningKey{}).
+		Where("id = ? AND private_key = ?", key.ID, key.PrivateKey).
+		Update("private_key", privateKey).Error
+	if err != nil {
+		return err
+	}
+	key.PrivateKey = privateKey
+	return nil
+}
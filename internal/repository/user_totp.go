@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+// UserTotp defines the interface for user TOTP secret data access operations.
+type UserTotp interface {
+	GetByUserID(tx *gorm.DB, userID uint) (*models.UserTotpSecret, error)
+	Create(tx *gorm.DB, userID uint, encryptedSecret string) (*models.UserTotpSecret, error)
+	Confirm(tx *gorm.DB, secret *models.UserTotpSecret, step int64) error
+	UseStep(tx *gorm.DB, secret *models.UserTotpSecret, step int64) (bool, error)
+	DeleteByUserID(tx *gorm.DB, userID uint) error
+}
+
+// gormUserTotp implements UserTotp using GORM.
+type gormUserTotp struct{}
+
+// NewUserTotp creates a new instance of gormUserTotp.
+func NewUserTotp() UserTotp {
+	return &gormUserTotp{}
+}
+
+func (r *gormUserTotp) GetByUserID(tx *gorm.DB, userID uint) (*models.UserTotpSecret, error) {
+	var secret models.UserTotpSecret
+	if err := tx.Where("user_id = ?", userID).First(&secret).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not enrolled
+		}
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Create stores a new unconfirmed secret, replacing any previous one for the user.
+func (r *gormUserTotp) Create(tx *gorm.DB, userID uint, encryptedSecret string) (*models.UserTotpSecret, error) {
+	if err := r.DeleteByUserID(tx, userID); err != nil {
+		return nil, err
+	}
+
+	secret := &models.UserTotpSecret{
+		UserID:          userID,
+		EncryptedSecret: encryptedSecret,
+	}
+	return secret, tx.Create(secret).Error
+}
+
+func (r *gormUserTotp) Confirm(tx *gorm.DB, secret *models.UserTotpSecret, step int64) error {
+	secret.ConfirmedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	secret.LastUsedStep = step
+	return tx.Save(secret).Error
+}
+
+// UseStep atomically records step as used. It returns false if the step, or a later one, was already used.
+func (r *gormUserTotp) UseStep(tx *gorm.DB, secret *models.UserTotpSecret, step int64) (bool, error) {
+	result := tx.Model(&models.UserTotpSecret{}).
+		Where("id = ? AND last_used_step < ?", secret.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	secret.LastUsedStep = step
+	return true, nil
+}
+
+func (r *gormUserTotp) DeleteByUserID(tx *gorm.DB, userID uint) error {
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserTotpSecret{}).Error
+}
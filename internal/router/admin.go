@@ -3,8 +3,10 @@ package router
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/schema"
@@ -13,6 +15,7 @@ import (
 	"github.com/MoSed3/otp-server/internal/models"
 	"github.com/MoSed3/otp-server/internal/service"
 	"github.com/MoSed3/otp-server/internal/token"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 type AdminLoginRequest struct {
@@ -57,28 +60,208 @@ func (r *AdminLoginRequest) Validate() error {
 }
 
 type AdminLoginResponse struct {
-	Token string `json:"token"`
+	Token                 string `json:"token,omitempty"`
+	RefreshToken          string `json:"refresh_token,omitempty"`
+	MfaRequired           bool   `json:"mfa_required,omitempty"`
+	ChallengeToken        string `json:"challenge_token,omitempty"`
+	MfaEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	EnrollmentToken       string `json:"enrollment_token,omitempty"` // Only accepted by /auth/admin/mfa/enroll
+}
+
+type AdminMfaRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+func (r *AdminMfaRequest) Validate() error {
+	if r.ChallengeToken == "" {
+		return errors.New("challenge_token is required")
+	}
+	return validateTotpCode(r.Code)
+}
+
+type AdminMfaEnrollRequest struct {
+	EnrollmentToken string `json:"enrollment_token"`
+}
+
+func (r *AdminMfaEnrollRequest) Validate() error {
+	if r.EnrollmentToken == "" {
+		return errors.New("enrollment_token is required")
+	}
+	return nil
+}
+
+type AdminMfaEnrollConfirmRequest struct {
+	EnrollmentToken string `json:"enrollment_token"`
+	Code            string `json:"code"`
+}
+
+func (r *AdminMfaEnrollConfirmRequest) Validate() error {
+	if r.EnrollmentToken == "" {
+		return errors.New("enrollment_token is required")
+	}
+	return validateTotpCode(r.Code)
+}
+
+type MfaPolicyRequest struct {
+	Required bool `json:"required"`
+}
+
+type MfaPolicyResponse struct {
+	Required bool `json:"required"`
+}
+
+type SecretKeyRotateRequest struct {
+	GracePeriod *uint `json:"grace_period,omitempty"` // Minutes, defaults to the configured grace period
+}
+
+type SecretKeyRotateResponse struct {
+	Kid                string     `json:"kid"`
+	PreviousValidUntil *time.Time `json:"previous_valid_until,omitempty"`
+}
+
+// SettingsResponse never includes the secret key itself, only its kid.
+type SettingsResponse struct {
+	Version               uint64                   `json:"version"`
+	SecretKeyID           string                   `json:"secret_key_id"`
+	SecretKeyGracePeriod  uint                     `json:"secret_key_grace_period"`
+	AccessTokenExpire     uint                     `json:"access_token_expire"`
+	RefreshTokenExpire    uint                     `json:"refresh_token_expire"`
+	OtpLength             uint                     `json:"otp_length"`
+	OtpNumericOnly        bool                     `json:"otp_numeric_only"`
+	OtpMaxAttempts        uint                     `json:"otp_max_attempts"`
+	OtpSessionTTL         uint                     `json:"otp_session_ttl"`
+	OtpCooldown           uint                     `json:"otp_cooldown"`
+	OtpThrottleTiers      []models.OtpThrottleTier `json:"otp_throttle_tiers"`
+	AdminMfaRequired      bool                     `json:"admin_mfa_required"`
+	AdminLockoutAttempts  uint                     `json:"admin_lockout_attempts"`
+	AdminLockoutDuration  uint                     `json:"admin_lockout_duration"`
+	AdminLoginFreeTries   uint                     `json:"admin_login_free_tries"`
+	AdminLoginMaxDelay    uint                     `json:"admin_login_max_delay"`
+	SmsAllowedCountries   []string                 `json:"sms_allowed_countries"`
+	SmsDeniedCountries    []string                 `json:"sms_denied_countries"`
+	SmsCountryHourlyLimit uint                     `json:"sms_country_hourly_limit"`
+	SmsPrefixHourlyLimit  uint                     `json:"sms_prefix_hourly_limit"`
+	SmsPrefixLength       uint                     `json:"sms_prefix_length"`
+	SmsSequentialLimit    uint                     `json:"sms_sequential_limit"`
+	SmsSequentialWindow   uint                     `json:"sms_sequential_window"`
+	SmsCost               uint                     `json:"sms_cost"`
+	SmsCountryCosts       map[string]uint          `json:"sms_country_costs"`
+	SmsDailySpendCap      uint                     `json:"sms_daily_spend_cap"`
+}
+
+func SettingsToResponse(s *models.Setting) SettingsResponse {
+	return SettingsResponse{
+		Version:               s.Version,
+		SecretKeyID:           s.SecretKeyID,
+		SecretKeyGracePeriod:  s.SecretKeyGracePeriod,
+		AccessTokenExpire:     s.AccessTokenExpire,
+		RefreshTokenExpire:    s.RefreshTokenExpire,
+		OtpLength:             s.OtpLength,
+		OtpNumericOnly:        s.OtpNumericOnly,
+		OtpMaxAttempts:        s.OtpMaxAttempts,
+		OtpSessionTTL:         s.OtpSessionTTL,
+		OtpCooldown:           s.OtpCooldown,
+		OtpThrottleTiers:      s.OtpThrottleTiers,
+		AdminMfaRequired:      s.AdminMfaRequired,
+		AdminLockoutAttempts:  s.AdminLockoutAttempts,
+		AdminLockoutDuration:  s.AdminLockoutDuration,
+		AdminLoginFreeTries:   s.AdminLoginFreeTries,
+		AdminLoginMaxDelay:    s.AdminLoginMaxDelay,
+		SmsAllowedCountries:   s.SmsAllowedCountries,
+		SmsDeniedCountries:    s.SmsDeniedCountries,
+		SmsCountryHourlyLimit: s.SmsCountryHourlyLimit,
+		SmsPrefixHourlyLimit:  s.SmsPrefixHourlyLimit,
+		SmsPrefixLength:       s.SmsPrefixLength,
+		SmsSequentialLimit:    s.SmsSequentialLimit,
+		SmsSequentialWindow:   s.SmsSequentialWindow,
+		SmsCost:               s.SmsCost,
+		SmsCountryCosts:       s.SmsCountryCosts,
+		SmsDailySpendCap:      s.SmsDailySpendCap,
+	}
+}
+
+// SettingsUpdateRequest changes only the fields present in the body. Times are in minutes for
+// token expiry and grace period, and in seconds for the OTP, admin lockout and SMS settings. Countries
+// are calling codes, or longer prefixes such as 1876, without the plus sign, and SMS costs are in
+// whatever unit the spend cap uses.
+type SettingsUpdateRequest struct {
+	AccessTokenExpire     *uint                    `json:"access_token_expire,omitempty"`
+	RefreshTokenExpire    *uint                    `json:"refresh_token_expire,omitempty"`
+	SecretKeyGracePeriod  *uint                    `json:"secret_key_grace_period,omitempty"`
+	OtpLength             *uint                    `json:"otp_length,omitempty"`
+	OtpNumericOnly        *bool                    `json:"otp_numeric_only,omitempty"`
+	OtpMaxAttempts        *uint                    `json:"otp_max_attempts,omitempty"`
+	OtpSessionTTL         *uint                    `json:"otp_session_ttl,omitempty"`
+	OtpCooldown           *uint                    `json:"otp_cooldown,omitempty"`
+	OtpThrottleTiers      []models.OtpThrottleTier `json:"otp_throttle_tiers,omitempty"`
+	AdminLockoutAttempts  *uint                    `json:"admin_lockout_attempts,omitempty"`
+	AdminLockoutDuration  *uint                    `json:"admin_lockout_duration,omitempty"`
+	AdminLoginFreeTries   *uint                    `json:"admin_login_free_tries,omitempty"`
+	AdminLoginMaxDelay    *uint                    `json:"admin_login_max_delay,omitempty"`
+	SmsAllowedCountries   []string                 `json:"sms_allowed_countries,omitempty"`
+	SmsDeniedCountries    []string                 `json:"sms_denied_countries,omitempty"`
+	SmsCountryHourlyLimit *uint                    `json:"sms_country_hourly_limit,omitempty"`
+	SmsPrefixHourlyLimit  *uint                    `json:"sms_prefix_hourly_limit,omitempty"`
+	SmsPrefixLength       *uint                    `json:"sms_prefix_length,omitempty"`
+	SmsSequentialLimit    *uint                    `json:"sms_sequential_limit,omitempty"`
+	SmsSequentialWindow   *uint                    `json:"sms_sequential_window,omitempty"`
+	SmsCost               *uint                    `json:"sms_cost,omitempty"`
+	SmsCountryCosts       map[string]uint          `json:"sms_country_costs,omitempty"`
+	SmsDailySpendCap      *uint                    `json:"sms_daily_spend_cap,omitempty"`
+}
+
+func (r SettingsUpdateRequest) toUpdate() models.SettingUpdate {
+	return models.SettingUpdate{
+		AccessTokenExpire:     r.AccessTokenExpire,
+		RefreshTokenExpire:    r.RefreshTokenExpire,
+		SecretKeyGracePeriod:  r.SecretKeyGracePeriod,
+		OtpLength:             r.OtpLength,
+		OtpNumericOnly:        r.OtpNumericOnly,
+		OtpMaxAttempts:        r.OtpMaxAttempts,
+		OtpSessionTTL:         r.OtpSessionTTL,
+		OtpCooldown:           r.OtpCooldown,
+		OtpThrottleTiers:      r.OtpThrottleTiers,
+		AdminLockoutAttempts:  r.AdminLockoutAttempts,
+		AdminLockoutDuration:  r.AdminLockoutDuration,
+		AdminLoginFreeTries:   r.AdminLoginFreeTries,
+		AdminLoginMaxDelay:    r.AdminLoginMaxDelay,
+		SmsAllowedCountries:   r.SmsAllowedCountries,
+		SmsDeniedCountries:    r.SmsDeniedCountries,
+		SmsCountryHourlyLimit: r.SmsCountryHourlyLimit,
+		SmsPrefixHourlyLimit:  r.SmsPrefixHourlyLimit,
+		SmsPrefixLength:       r.SmsPrefixLength,
+		SmsSequentialLimit:    r.SmsSequentialLimit,
+		SmsSequentialWindow:   r.SmsSequentialWindow,
+		SmsCost:               r.SmsCost,
+		SmsCountryCosts:       r.SmsCountryCosts,
+		SmsDailySpendCap:      r.SmsDailySpendCap,
+	}
 }
 
 type GetCurrentAdminResponse struct {
-	ID       uint             `json:"id"`
-	Username string           `json:"username"`
-	Role     models.AdminRole `json:"role"`
+	ID          uint                `json:"id"`
+	Username    string              `json:"username"`
+	Role        models.AdminRole    `json:"role"`
+	Permissions []models.Permission `json:"permissions"`
 }
 
 // AdminHandler handles admin-related HTTP requests.
 type AdminHandler struct {
 	adminService service.AdminService
+	tokenService service.TokenService
 	jwtService   *token.JWTService
 	decoder      *schema.Decoder
 }
 
 // NewAdminHandler creates a new AdminHandler.
-func NewAdminHandler(adminService service.AdminService, jwtService *token.JWTService) *AdminHandler {
+func NewAdminHandler(adminService service.AdminService, tokenService service.TokenService, jwtService *token.JWTService) *AdminHandler {
 	decoder := schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true) // Ignore unknown keys to prevent errors from other query params
+	decoder.RegisterConverter(time.Time{}, parseQueryTime)
 	return &AdminHandler{
 		adminService: adminService,
+		tokenService: tokenService,
 		jwtService:   jwtService,
 		decoder:      decoder,
 	}
@@ -86,14 +269,15 @@ func NewAdminHandler(adminService service.AdminService, jwtService *token.JWTSer
 
 // adminLogin godoc
 // @Summary Admin login
-// @Description Authenticates an admin user and returns a JWT token
+// @Description Authenticates an admin user and returns a JWT token, or an MFA challenge token when the admin has enrolled TOTP
 // @Tags Admin
 // @Accept json
 // @Produce json
 // @Param request body AdminLoginRequest true "Admin credentials"
-// @Success 200 {object} AdminLoginResponse "JWT token for authenticated admin"
+// @Success 200 {object} AdminLoginResponse "JWT and refresh token for authenticated admin, challenge token when MFA is required, or enrollment token when MFA is enforced and the admin has not enrolled"
 // @Failure 400 {string} string "Invalid request format or missing credentials"
 // @Failure 401 {string} string "Invalid username or password"
+// @Failure 429 {string} string "Too many failed logins for the username, see Retry-After header"
 // @Failure 500 {string} string "Internal server error"
 // @Router /auth/admin [post]
 func (h *AdminHandler) adminLogin(w http.ResponseWriter, r *http.Request) {
@@ -109,24 +293,267 @@ func (h *AdminHandler) adminLogin(w http.ResponseWriter, r *http.Request) {
 
 	admin, err := h.adminService.Login(r.Context(), req.Username, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		var throttled *service.ErrAdminLoginThrottled
+		if errors.As(err, &throttled) {
+			writeAdminLoginThrottled(w, throttled)
+		} else {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	mfaRequired, err := h.adminService.CheckMfa(tx, admin)
+	if errors.Is(err, service.ErrMfaEnrollmentRequired) {
+		enrollmentToken, err := h.jwtService.GenerateMfaEnrollmentToken(admin.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate MFA enrollment token", http.StatusInternalServerError)
+			return
+		}
+
+		response := AdminLoginResponse{
+			MfaEnrollmentRequired: true,
+			EnrollmentToken:       enrollmentToken,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if mfaRequired {
+		challengeToken, err := h.jwtService.GenerateMfaChallengeToken(admin.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate MFA challenge", http.StatusInternalServerError)
+			return
+		}
+
+		response := AdminLoginResponse{
+			MfaRequired:    true,
+			ChallengeToken: challengeToken,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	jwtToken, err := h.jwtService.GenerateToken(admin.ID, token.AudianceAdmin)
+	pair, err := h.tokenService.IssueTokens(tx, r, admin.ID, token.AudianceAdmin)
 	if err != nil {
 		http.Error(w, "Failed to generate JWT token", http.StatusInternalServerError)
 		return
 	}
 
 	response := AdminLoginResponse{
-		Token: jwtToken,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// adminMfa godoc
+// @Summary Complete admin MFA
+// @Description Exchanges the challenge token returned by /auth/admin and a TOTP code for an admin JWT token. Each challenge token can be used once.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body AdminMfaRequest true "Challenge token and authenticator app code"
+// @Success 200 {object} AdminLoginResponse "JWT and refresh token for authenticated admin"
+// @Failure 400 {string} string "Invalid request format"
+// @Failure 401 {string} string "Invalid or expired challenge token, or invalid code"
+// @Failure 429 {string} string "Too many failed logins for the admin, see Retry-After header"
+// @Failure 500 {string} string "Internal server error"
+// @Router /auth/admin/mfa [post]
+func (h *AdminHandler) adminMfa(w http.ResponseWriter, r *http.Request) {
+	var req AdminMfaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := h.parseMfaChallenge(req.ChallengeToken, token.AudianceAdminMfa)
+	if !ok {
+		http.Error(w, service.ErrMfaChallengeInvalid.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	admin, err := h.adminService.VerifyMfa(tx, challenge, req.Code)
+	if err != nil {
+		var throttled *service.ErrAdminLoginThrottled
+		if errors.As(err, &throttled) {
+			writeAdminLoginThrottled(w, throttled)
+		} else if errors.Is(err, totp.ErrInvalidCode) || errors.Is(err, service.ErrTotpNotEnrolled) || errors.Is(err, service.ErrMfaChallengeInvalid) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	pair, err := h.tokenService.IssueTokens(tx, r, admin.ID, token.AudianceAdmin)
+	if err != nil {
+		http.Error(w, "Failed to generate JWT token", http.StatusInternalServerError)
+		return
+	}
+
+	response := AdminLoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// adminMfaEnroll godoc
+// @Summary Begin admin TOTP enrollment during login
+// @Description Generates an authenticator app secret for an admin who must use MFA but has not enrolled, using the enrollment token returned by /auth/admin
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body AdminMfaEnrollRequest true "Enrollment token"
+// @Success 200 {object} TotpEnrollmentResponse "Secret and otpauth:// URI to add to an authenticator app"
+// @Failure 400 {string} string "Invalid request format"
+// @Failure 401 {string} string "Invalid or expired enrollment token"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /auth/admin/mfa/enroll [post]
+func (h *AdminHandler) adminMfaEnroll(w http.ResponseWriter, r *http.Request) {
+	var req AdminMfaEnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := h.parseMfaChallenge(req.EnrollmentToken, token.AudianceAdminMfaEnroll)
+	if !ok {
+		http.Error(w, service.ErrMfaChallengeInvalid.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	enrollment, err := h.adminService.BeginMfaEnrollment(tx, challenge)
+	if err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	response := TotpEnrollmentResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// adminMfaEnrollConfirm godoc
+// @Summary Confirm admin TOTP enrollment during login
+// @Description Activates the authenticator app secret from /auth/admin/mfa/enroll with a first code and completes the login. Wrong codes count towards the admin lockout, and the enrollment token cannot be used again once enrollment is confirmed.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body AdminMfaEnrollConfirmRequest true "Enrollment token and current authenticator app code"
+// @Success 200 {object} AdminLoginResponse "JWT and refresh token for authenticated admin"
+// @Failure 400 {string} string "Invalid request format or no pending enrollment"
+// @Failure 401 {string} string "Invalid or expired enrollment token, or invalid code"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 429 {string} string "Too many failed logins for the admin, see Retry-After header"
+// @Failure 500 {string} string "Internal server error"
+// @Router /auth/admin/mfa/enroll/confirm [post]
+func (h *AdminHandler) adminMfaEnrollConfirm(w http.ResponseWriter, r *http.Request) {
+	var req AdminMfaEnrollConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := h.parseMfaChallenge(req.EnrollmentToken, token.AudianceAdminMfaEnroll)
+	if !ok {
+		http.Error(w, service.ErrMfaChallengeInvalid.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	admin, err := h.adminService.CompleteMfaEnrollment(tx, challenge, req.Code)
+	if err != nil {
+		var throttled *service.ErrAdminLoginThrottled
+		if errors.As(err, &throttled) {
+			writeAdminLoginThrottled(w, throttled)
+		} else {
+			writeTotpError(w, err)
+		}
+		return
+	}
+
+	pair, err := h.tokenService.IssueTokens(tx, r, admin.ID, token.AudianceAdmin)
+	if err != nil {
+		http.Error(w, "Failed to generate JWT token", http.StatusInternalServerError)
+		return
+	}
+
+	response := AdminLoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// mfaTokenSubject returns the ID of the admin an MFA challenge or enrollment token was issued to, or an
+// empty string when the token is not valid for audience. Rate limits count MFA attempts by it.
+func (h *AdminHandler) mfaTokenSubject(tokenString string, audience token.Audiance) string {
+	challenge, ok := h.parseMfaChallenge(tokenString, audience)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(challenge.AdminID), 10)
+}
+
+// parseMfaChallenge reads a challenge or enrollment token issued for audience.
+func (h *AdminHandler) parseMfaChallenge(tokenString string, audience token.Audiance) (service.MfaChallenge, bool) {
+	claims, err := h.jwtService.ParseTokenString(tokenString)
+	if err != nil || claims.Audience != audience || claims.SessionID() == "" {
+		return service.MfaChallenge{}, false
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return service.MfaChallenge{}, false
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return service.MfaChallenge{}, false
+	}
+
+	return service.MfaChallenge{
+		AdminID:   claims.ID,
+		ID:        claims.SessionID(),
+		IssuedAt:  issuedAt.UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}, true
+}
+
 // getCurrentAdmin godoc
 // @Summary Get current authenticated admin
 // @Description Returns current admin information for authenticated requests
@@ -140,11 +567,13 @@ func (h *AdminHandler) adminLogin(w http.ResponseWriter, r *http.Request) {
 // @Router /admin/profile [get]
 func (h *AdminHandler) getCurrentAdmin(w http.ResponseWriter, r *http.Request) {
 	admin := middleware.GetAdminFromRequest(r)
+	role := middleware.GetAdminRoleFromRequest(r)
 
 	response := GetCurrentAdminResponse{
-		ID:       admin.ID,
-		Username: admin.Username,
-		Role:     admin.Role,
+		ID:          admin.ID,
+		Username:    admin.Username,
+		Role:        admin.Role,
+		Permissions: role.EffectivePermissions(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -247,7 +676,7 @@ func (h *AdminHandler) getUserByID(w http.ResponseWriter, r *http.Request) {
 
 // updateUserStatus godoc
 // @Summary Update user status
-// @Description Disable or activate a user (sudo/super admin only)
+// @Description Disable or activate a user (requires users:write)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -279,8 +708,10 @@ func (h *AdminHandler) updateUserStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	admin := middleware.GetAdminFromRequest(r)
+
 	tx := middleware.GetTxFromRequest(r)
-	user, err := h.adminService.UpdateUserStatus(tx, uint(userID), req.Status)
+	user, err := h.adminService.UpdateUserStatus(tx, admin, uint(userID), req.Status)
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
 			http.Error(w, "User not found", http.StatusNotFound)
@@ -295,3 +726,254 @@ func (h *AdminHandler) updateUserStatus(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
+
+// beginTotpEnrollment godoc
+// @Summary Begin admin TOTP enrollment
+// @Description Generates a new authenticator app secret for the authenticated admin. The enrollment is active once confirmed with a first code.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {object} TotpEnrollmentResponse "Secret and otpauth:// URI to add to an authenticator app"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/profile/totp [post]
+func (h *AdminHandler) beginTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	tx := middleware.GetTxFromRequest(r)
+	enrollment, err := h.adminService.BeginTotpEnrollment(tx, admin)
+	if err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	response := TotpEnrollmentResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// confirmTotpEnrollment godoc
+// @Summary Confirm admin TOTP enrollment
+// @Description Activates the pending authenticator app secret with a first code. Later logins require the second factor.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body TotpCodeRequest true "Current authenticator app code"
+// @Success 204 "TOTP enabled"
+// @Failure 400 {string} string "Invalid request format or no pending enrollment"
+// @Failure 401 {string} string "Invalid TOTP code or JWT token"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/profile/totp/confirm [post]
+func (h *AdminHandler) confirmTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	var req TotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.ConfirmTotpEnrollment(tx, admin, req.Code); err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// disableTotp godoc
+// @Summary Disable admin TOTP
+// @Description Removes the authenticated admin's authenticator app enrollment. Not allowed while MFA is enforced.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body TotpCodeRequest true "Current authenticator app code"
+// @Success 204 "TOTP disabled"
+// @Failure 400 {string} string "Invalid request format or TOTP not enrolled"
+// @Failure 401 {string} string "Invalid TOTP code or JWT token"
+// @Failure 409 {string} string "MFA is enforced"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/profile/totp [delete]
+func (h *AdminHandler) disableTotp(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	var req TotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.DisableTotp(tx, admin, req.Code); err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateMfaPolicy godoc
+// @Summary Enforce MFA for all admins
+// @Description Requires (or stops requiring) a TOTP second factor for every admin login (requires settings:write). The caller must be enrolled to enable enforcement.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body MfaPolicyRequest true "Whether MFA is required"
+// @Success 200 {object} MfaPolicyResponse "Updated MFA policy"
+// @Failure 400 {string} string "Invalid request format or caller not enrolled"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/mfa-policy [put]
+func (h *AdminHandler) updateMfaPolicy(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	var req MfaPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.SetMfaRequired(tx, admin, req.Required); err != nil {
+		if errors.Is(err, service.ErrTotpNotEnrolled) {
+			http.Error(w, "Enroll TOTP before enforcing MFA", http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := MfaPolicyResponse{
+		Required: req.Required,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// rotateSecretKey godoc
+// @Summary Rotate the JWT secret key
+// @Description Generates a new HS256 secret key with a new kid (requires settings:write). Tokens signed with the previous key keep working for the grace period.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body SecretKeyRotateRequest false "Grace period in minutes for the previous key"
+// @Success 200 {object} SecretKeyRotateResponse "New key id and how long the previous key stays valid"
+// @Failure 400 {string} string "Invalid request format"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/secret-key/rotate [post]
+func (h *AdminHandler) rotateSecretKey(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	var req SecretKeyRotateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	kid, previousValidUntil, err := h.adminService.RotateSecretKey(tx, admin, req.GracePeriod)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := SecretKeyRotateResponse{
+		Kid:                kid,
+		PreviousValidUntil: previousValidUntil,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// getSettings godoc
+// @Summary Get settings
+// @Description Returns the application settings (requires settings:read). The secret key is never returned.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {object} SettingsResponse "Current settings"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/settings [get]
+func (h *AdminHandler) getSettings(w http.ResponseWriter, r *http.Request) {
+	tx := middleware.GetTxFromRequest(r)
+	settings, err := h.adminService.GetSettings(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := SettingsToResponse(settings)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// updateSettings godoc
+// @Summary Update settings
+// @Description Updates the given settings (requires settings:write) and reloads them on every server instance. Use /admin/secret-key/rotate and /admin/mfa-policy for the secret key and MFA enforcement.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body SettingsUpdateRequest true "Settings to change"
+// @Success 200 {object} SettingsResponse "Updated settings"
+// @Failure 400 {string} string "Invalid request format or value out of bounds"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/settings [patch]
+func (h *AdminHandler) updateSettings(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetAdminFromRequest(r)
+
+	var req SettingsUpdateRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	settings, err := h.adminService.UpdateSettings(tx, admin, req.toUpdate())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := SettingsToResponse(settings)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,313 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/password"
+	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/service"
+)
+
+const maxAdminUsernameLength = 64
+
+type AdminResponse struct {
+	ID        uint             `json:"id"`
+	Username  string           `json:"username"`
+	Role      models.AdminRole `json:"role"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func AdminToResponse(a *models.Admin) AdminResponse {
+	return AdminResponse{
+		ID:        a.ID,
+		Username:  a.Username,
+		Role:      a.Role,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+type CreateAdminRequest struct {
+	Username string           `json:"username"`
+	Password string           `json:"password"`
+	Role     models.AdminRole `json:"role"`
+}
+
+func (r *CreateAdminRequest) Validate() error {
+	if err := validateAdminUsername(r.Username); err != nil {
+		return err
+	}
+	if err := validateAdminPassword(r.Password); err != nil {
+		return err
+	}
+	if r.Role < 1 {
+		return errors.New("role is required")
+	}
+	return nil
+}
+
+// UpdateAdminRequest changes only the fields present in the body. Setting a password logs the admin out.
+type UpdateAdminRequest struct {
+	Username *string           `json:"username,omitempty"`
+	Password *string           `json:"password,omitempty"`
+	Role     *models.AdminRole `json:"role,omitempty"`
+}
+
+func (r *UpdateAdminRequest) Validate() error {
+	if r.Username != nil {
+		if err := validateAdminUsername(*r.Username); err != nil {
+			return err
+		}
+	}
+	if r.Password != nil {
+		if err := validateAdminPassword(*r.Password); err != nil {
+			return err
+		}
+	}
+	if r.Role != nil && *r.Role < 1 {
+		return errors.New("invalid role")
+	}
+	return nil
+}
+
+func validateAdminUsername(username string) error {
+	if strings.TrimSpace(username) == "" {
+		return errors.New("username is required")
+	}
+	if len(username) > maxAdminUsernameLength {
+		return errors.New("username cannot exceed 64 characters")
+	}
+	return nil
+}
+
+// validateAdminPassword only checks presence; the password policy is enforced when the password is stored.
+func validateAdminPassword(password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	return nil
+}
+
+// listAdmins godoc
+// @Summary List admins
+// @Description Lists every admin account (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {array} AdminResponse "Admin accounts"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins [get]
+func (h *AdminHandler) listAdmins(w http.ResponseWriter, r *http.Request) {
+	tx := middleware.GetTxFromRequest(r)
+	admins, err := h.adminService.ListAdmins(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]AdminResponse, len(admins))
+	for i := range admins {
+		response[i] = AdminToResponse(&admins[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// createAdmin godoc
+// @Summary Create an admin
+// @Description Creates a new admin account (requires admins:manage). The caller must hold every permission of the role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body CreateAdminRequest true "Username, password and role ID"
+// @Success 201 {object} AdminResponse "Created admin"
+// @Failure 400 {string} string "Invalid request format, validation error, unknown role or password rejected by the policy"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 409 {string} string "Username is already taken"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins [post]
+func (h *AdminHandler) createAdmin(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	var req CreateAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	admin, err := h.adminService.CreateAdmin(tx, actor, req.Username, req.Password, req.Role)
+	if err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	response := AdminToResponse(admin)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// getAdmin godoc
+// @Summary Get an admin
+// @Description Gets an admin account by ID (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Admin ID"
+// @Security BearerAuthAdmin
+// @Success 200 {object} AdminResponse "Admin details"
+// @Failure 400 {string} string "Invalid admin ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Admin not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins/{id} [get]
+func (h *AdminHandler) getAdmin(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := parseAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	admin, err := h.adminService.GetAdmin(tx, adminID)
+	if err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	response := AdminToResponse(admin)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// updateAdmin godoc
+// @Summary Update an admin
+// @Description Changes an admin's username, role or password (requires admins:manage). A new password logs the admin out everywhere. The last super admin cannot be demoted.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Admin ID"
+// @Param request body UpdateAdminRequest true "Fields to change"
+// @Security BearerAuthAdmin
+// @Success 200 {object} AdminResponse "Updated admin"
+// @Failure 400 {string} string "Invalid request format, validation error or password rejected by the policy"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Admin not found"
+// @Failure 409 {string} string "Username is already taken or last super admin"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins/{id} [patch]
+func (h *AdminHandler) updateAdmin(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	adminID, ok := parseAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updates := repository.AdminUpdate{
+		Username:    req.Username,
+		Role:        req.Role,
+		NewPassword: req.Password,
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	admin, err := h.adminService.UpdateAdmin(tx, actor, adminID, updates)
+	if err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	response := AdminToResponse(admin)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// deleteAdmin godoc
+// @Summary Delete an admin
+// @Description Deletes an admin account (requires admins:manage). The last super admin cannot be deleted.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Admin ID"
+// @Security BearerAuthAdmin
+// @Success 204 "Admin deleted"
+// @Failure 400 {string} string "Invalid admin ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Admin not found"
+// @Failure 409 {string} string "Last super admin"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins/{id} [delete]
+func (h *AdminHandler) deleteAdmin(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	adminID, ok := parseAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.DeleteAdmin(tx, actor, adminID); err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseAdminID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	adminID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid admin ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(adminID), true
+}
+
+func writeAdminAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAdminNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrRoleNotFound), errors.Is(err, password.ErrRejected):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPrivilegeEscalation):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrAdminUsernameTaken), errors.Is(err, service.ErrLastSuperAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
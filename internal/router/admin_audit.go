@@ -0,0 +1,149 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+type AuditEventResponse struct {
+	ID         uint                          `json:"id"`
+	CreatedAt  time.Time                     `json:"created_at"`
+	ActorType  models.AuditActorType         `json:"actor_type"`
+	ActorID    *uint                         `json:"actor_id,omitempty"`
+	ActorName  string                        `json:"actor_name,omitempty"`
+	Action     models.AuditAction            `json:"action"`
+	TargetType string                        `json:"target_type,omitempty"`
+	TargetID   string                        `json:"target_id,omitempty"`
+	Changes    map[string]models.AuditChange `json:"changes,omitempty"`
+	IPAddress  string                        `json:"ip_address,omitempty"`
+	UserAgent  string                        `json:"user_agent,omitempty"`
+	RequestID  string                        `json:"request_id,omitempty"`
+	Hash       string                        `json:"hash"`
+}
+
+func AuditEventToResponse(e *models.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		ActorType:  e.ActorType,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Changes:    e.Changes,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		Hash:       e.Hash,
+	}
+}
+
+type SearchAuditEventsResponse struct {
+	Events []AuditEventResponse `json:"events"`
+	Total  int64                `json:"total"`
+}
+
+type VerifyAuditChainResponse struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`
+	BrokenAt *uint `json:"broken_at,omitempty"` // ID of the first altered event
+}
+
+// parseQueryTime lets the query decoder read RFC 3339 timestamps.
+func parseQueryTime(value string) reflect.Value {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(t)
+}
+
+// searchAuditEvents godoc
+// @Summary Search the audit log
+// @Description Lists audit events, newest first by default (requires audit:read)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param actor_type query string false "Actor type" Enums(admin,user,anonymous,system)
+// @Param actor_id query int false "Actor ID"
+// @Param action query string false "Action, e.g. admin.login or user.status_updated"
+// @Param target_type query string false "Target type" Enums(admin,role,user,settings,ip_list,signing_key)
+// @Param target_id query string false "Target ID"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "Only events at or after this RFC 3339 time"
+// @Param to query string false "Only events before this RFC 3339 time"
+// @Param limit query int false "Limit for pagination (max 100)"
+// @Param offset query int false "Offset for pagination"
+// @Param sort_order query string false "Sort order by ID" Enums(asc, desc)
+// @Security BearerAuthAdmin
+// @Success 200 {object} SearchAuditEventsResponse "Matching events with total count"
+// @Failure 400 {string} string "Invalid query parameters"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/audit [get]
+func (h *AdminHandler) searchAuditEvents(w http.ResponseWriter, r *http.Request) {
+	var params models.AuditSearchParams
+	if err := h.decoder.Decode(&params, r.URL.Query()); err != nil {
+		http.Error(w, "Invalid query parameters: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params.SetDefaults()
+
+	tx := middleware.GetTxFromRequest(r)
+	events, total, err := h.adminService.SearchAuditEvents(tx, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := SearchAuditEventsResponse{
+		Events: make([]AuditEventResponse, len(events)),
+		Total:  total,
+	}
+	for i := range events {
+		response.Events[i] = AuditEventToResponse(&events[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// verifyAuditChain godoc
+// @Summary Verify the audit log
+// @Description Recomputes the hash chain of the audit log and reports the first altered event (requires audit:read)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {object} VerifyAuditChainResponse "Verification result"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/audit/verify [get]
+func (h *AdminHandler) verifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	tx := middleware.GetTxFromRequest(r)
+	checked, brokenAt, err := h.adminService.VerifyAuditChain(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := VerifyAuditChainResponse{
+		Valid:   brokenAt == nil,
+		Checked: checked,
+	}
+	if brokenAt != nil {
+		response.BrokenAt = &brokenAt.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
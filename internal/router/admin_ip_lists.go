@@ -0,0 +1,175 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/service"
+)
+
+const maxIPListReasonLength = 255
+
+type IPListEntryResponse struct {
+	Network   string     `json:"network"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"` // Admin username, "cli" or "auto-ban"
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func IPListEntryToResponse(e *redis.IPListEntry) IPListEntryResponse {
+	return IPListEntryResponse{
+		Network:   e.Network,
+		Reason:    e.Reason,
+		CreatedBy: e.CreatedBy,
+		CreatedAt: e.CreatedAt,
+		ExpiresAt: e.ExpiresAt,
+	}
+}
+
+type AddIPListEntryRequest struct {
+	Network   string `json:"network"` // Address or CIDR
+	Reason    string `json:"reason,omitempty"`
+	ExpiresIn *uint  `json:"expires_in,omitempty"` // Seconds, permanent when omitted
+}
+
+func (r *AddIPListEntryRequest) Validate() error {
+	if strings.TrimSpace(r.Network) == "" {
+		return errors.New("network is required")
+	}
+	if len(r.Reason) > maxIPListReasonLength {
+		return errors.New("reason cannot exceed 255 characters")
+	}
+	if r.ExpiresIn != nil && *r.ExpiresIn == 0 {
+		return errors.New("expires_in must be positive")
+	}
+	return nil
+}
+
+// listIPListEntries godoc
+// @Summary List an IP list
+// @Description Lists the active entries of the allow or deny list (requires ip_lists:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param list path string true "List" Enums(allow,deny)
+// @Security BearerAuthAdmin
+// @Success 200 {array} IPListEntryResponse "Entries"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Unknown list"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/ip-lists/{list} [get]
+func (h *AdminHandler) listIPListEntries(w http.ResponseWriter, r *http.Request) {
+	list := redis.IPList(chi.URLParam(r, "list"))
+	entries, err := h.adminService.ListIPListEntries(r.Context(), list)
+	if err != nil {
+		writeIPListError(w, err)
+		return
+	}
+
+	response := make([]IPListEntryResponse, len(entries))
+	for i := range entries {
+		response[i] = IPListEntryToResponse(&entries[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// addIPListEntry godoc
+// @Summary Add an IP list entry
+// @Description Puts an address or CIDR on the allow or deny list, replacing any entry for it (requires ip_lists:manage). Allowed clients skip the deny list and rate limits; denied clients are rejected with 403.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param list path string true "List" Enums(allow,deny)
+// @Param request body AddIPListEntryRequest true "Network, reason and optional expiry"
+// @Security BearerAuthAdmin
+// @Success 201 {object} IPListEntryResponse "Added entry"
+// @Failure 400 {string} string "Invalid request format or validation error"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Unknown list"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/ip-lists/{list} [post]
+func (h *AdminHandler) addIPListEntry(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	var req AddIPListEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	list := redis.IPList(chi.URLParam(r, "list"))
+	entry, err := h.adminService.AddIPListEntry(r.Context(), tx, actor, list, strings.TrimSpace(req.Network), req.Reason, req.ExpiresIn)
+	if err != nil {
+		writeIPListError(w, err)
+		return
+	}
+
+	response := IPListEntryToResponse(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// removeIPListEntry godoc
+// @Summary Remove an IP list entry
+// @Description Takes an address or CIDR off the allow or deny list, such as to lift an automatic ban (requires ip_lists:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param list path string true "List" Enums(allow,deny)
+// @Param network query string true "Address or CIDR of the entry"
+// @Security BearerAuthAdmin
+// @Success 204 "Entry removed"
+// @Failure 400 {string} string "Invalid address or CIDR"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Unknown list or entry not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/ip-lists/{list} [delete]
+func (h *AdminHandler) removeIPListEntry(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	network := strings.TrimSpace(r.URL.Query().Get("network"))
+	if network == "" {
+		http.Error(w, "network is required", http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	list := redis.IPList(chi.URLParam(r, "list"))
+	if err := h.adminService.RemoveIPListEntry(r.Context(), tx, actor, list, network); err != nil {
+		writeIPListError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeIPListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownIPList), errors.Is(err, service.ErrIPListEntryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidNetwork):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
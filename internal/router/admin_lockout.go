@@ -0,0 +1,100 @@
+package router
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/service"
+)
+
+type AdminLockoutResponse struct {
+	Failures      uint       `json:"failures"` // Failed logins since the last success or lockout
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// getAdminLockout godoc
+// @Summary Get an admin's lockout state
+// @Description Returns the failed logins of an admin and whether its username is locked (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Admin ID"
+// @Security BearerAuthAdmin
+// @Success 200 {object} AdminLockoutResponse "Lockout state"
+// @Failure 400 {string} string "Invalid admin ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Admin not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins/{id}/lockout [get]
+func (h *AdminHandler) getAdminLockout(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := parseAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	state, err := h.adminService.GetAdminLockout(r.Context(), tx, adminID)
+	if err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	now := time.Now()
+	response := AdminLockoutResponse{
+		Failures: state.Failures,
+		Locked:   state.Locked(now),
+	}
+	if response.Locked {
+		response.LockedUntil = &state.LockedUntil
+	}
+	if state.NextAttemptAt.After(now) {
+		response.NextAttemptAt = &state.NextAttemptAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// unlockAdmin godoc
+// @Summary Unlock an admin
+// @Description Lifts the lockout of an admin's username and forgets its failed logins (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Admin ID"
+// @Security BearerAuthAdmin
+// @Success 204 "Admin unlocked"
+// @Failure 400 {string} string "Invalid admin ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Admin not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/admins/{id}/lockout [delete]
+func (h *AdminHandler) unlockAdmin(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	adminID, ok := parseAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.UnlockAdmin(r.Context(), tx, actor, adminID); err != nil {
+		writeAdminAccountError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAdminLoginThrottled(w http.ResponseWriter, throttled *service.ErrAdminLoginThrottled) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	http.Error(w, throttled.Error(), http.StatusTooManyRequests)
+}
@@ -0,0 +1,284 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/service"
+)
+
+const maxRoleNameLength = 64
+
+type RoleResponse struct {
+	ID          uint                `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Builtin     bool                `json:"builtin"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+func RoleToResponse(role *models.Role) RoleResponse {
+	return RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: role.EffectivePermissions(),
+	}
+}
+
+type CreateRoleRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+func (r *CreateRoleRequest) Validate() error {
+	if err := validateRoleName(r.Name); err != nil {
+		return err
+	}
+	return validateRolePermissions(r.Permissions)
+}
+
+// UpdateRoleRequest changes only the fields present in the body.
+type UpdateRoleRequest struct {
+	Name        *string             `json:"name,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Permissions []models.Permission `json:"permissions,omitempty"`
+}
+
+func (r *UpdateRoleRequest) Validate() error {
+	if r.Name != nil {
+		if err := validateRoleName(*r.Name); err != nil {
+			return err
+		}
+	}
+	if r.Permissions != nil {
+		return validateRolePermissions(r.Permissions)
+	}
+	return nil
+}
+
+func validateRoleName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("name is required")
+	}
+	if len(name) > maxRoleNameLength {
+		return errors.New("name cannot exceed 64 characters")
+	}
+	return nil
+}
+
+func validateRolePermissions(permissions []models.Permission) error {
+	for _, p := range permissions {
+		if !p.IsValid() {
+			return errors.New("unknown permission: " + string(p))
+		}
+	}
+	return nil
+}
+
+// listPermissions godoc
+// @Summary List permissions
+// @Description Lists every permission that can be granted to a role (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {array} string "Permissions"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Router /admin/permissions [get]
+func (h *AdminHandler) listPermissions(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(models.AllPermissions)
+}
+
+// listRoles godoc
+// @Summary List roles
+// @Description Lists every admin role with its permissions (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {array} RoleResponse "Roles"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/roles [get]
+func (h *AdminHandler) listRoles(w http.ResponseWriter, r *http.Request) {
+	tx := middleware.GetTxFromRequest(r)
+	roles, err := h.adminService.ListRoles(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]RoleResponse, len(roles))
+	for i := range roles {
+		response[i] = RoleToResponse(&roles[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// createRole godoc
+// @Summary Create a role
+// @Description Creates a custom admin role (requires admins:manage). The caller must hold every permission it grants.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Param request body CreateRoleRequest true "Name, description and permissions"
+// @Success 201 {object} RoleResponse "Created role"
+// @Failure 400 {string} string "Invalid request format or validation error"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 409 {string} string "Role name is already taken"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/roles [post]
+func (h *AdminHandler) createRole(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	var req CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	role, err := h.adminService.CreateRole(tx, actor, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	response := RoleToResponse(role)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// updateRole godoc
+// @Summary Update a role
+// @Description Changes a role's name, description or permissions (requires admins:manage). The Super role cannot be changed and built-in roles cannot be renamed.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param request body UpdateRoleRequest true "Fields to change"
+// @Security BearerAuthAdmin
+// @Success 200 {object} RoleResponse "Updated role"
+// @Failure 400 {string} string "Invalid request format or validation error"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Role not found"
+// @Failure 409 {string} string "Role name is already taken or built-in role"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/roles/{id} [patch]
+func (h *AdminHandler) updateRole(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	roleID, ok := parseRoleID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update := models.RoleUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	role, err := h.adminService.UpdateRole(tx, actor, roleID, update)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	response := RoleToResponse(role)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// deleteRole godoc
+// @Summary Delete a role
+// @Description Deletes a custom role that no admin holds (requires admins:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Security BearerAuthAdmin
+// @Success 204 "Role deleted"
+// @Failure 400 {string} string "Invalid role ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Failure 404 {string} string "Role not found"
+// @Failure 409 {string} string "Built-in role or role in use"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/roles/{id} [delete]
+func (h *AdminHandler) deleteRole(w http.ResponseWriter, r *http.Request) {
+	actor := middleware.GetAdminFromRequest(r)
+
+	roleID, ok := parseRoleID(w, r)
+	if !ok {
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.adminService.DeleteRole(tx, actor, roleID); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseRoleID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	roleID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid role ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(roleID), true
+}
+
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidPermission):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPrivilegeEscalation):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrRoleNameTaken), errors.Is(err, service.ErrRoleInUse), errors.Is(err, service.ErrBuiltinRole):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
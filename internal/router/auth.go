@@ -0,0 +1,99 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MoSed3/otp-server/internal/service"
+	"github.com/MoSed3/otp-server/internal/token"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r RefreshTokenRequest) validate() error {
+	if r.RefreshToken == "" {
+		return errors.New("refresh_token is required")
+	}
+	return nil
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthHandler handles token lifecycle HTTP requests shared by users and admins.
+type AuthHandler struct {
+	tokenService service.TokenService
+	jwtService   *token.JWTService
+}
+
+// NewAuthHandler creates a new AuthHandler.
+func NewAuthHandler(tokenService service.TokenService, jwtService *token.JWTService) *AuthHandler {
+	return &AuthHandler{
+		tokenService: tokenService,
+		jwtService:   jwtService,
+	}
+}
+
+// refresh godoc
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes every token issued from the same login.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} TokenResponse "New access and refresh tokens"
+// @Failure 400 {string} string "Invalid request format"
+// @Failure 401 {string} string "Invalid, expired or reused refresh token"
+// @Failure 403 {string} string "User is disabled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.tokenService.Refresh(r.Context(), r, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, service.ErrUserDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// jwks godoc
+// @Summary Get the public signing keys
+// @Description Returns the public keys of the active and retiring signing keys as a JSON Web Key Set, so other services can verify access tokens by their kid header. Also served at /.well-known/jwks.json outside the API base path.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} token.JWKSet "JSON Web Key Set"
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(h.jwtService.JWKS())
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/setting"
+)
+
+type DiagnosticsResponse struct {
+	SettingsVersion  uint64    `json:"settings_version"`
+	SettingsLoadedAt time.Time `json:"settings_loaded_at"`
+	SecretKeyID      string    `json:"secret_key_id"`
+	ServerTime       time.Time `json:"server_time"`
+}
+
+// DiagnosticsHandler reports the runtime state of this server instance.
+type DiagnosticsHandler struct {
+	appSettings *setting.Config
+}
+
+// NewDiagnosticsHandler creates a new DiagnosticsHandler.
+func NewDiagnosticsHandler(appSettings *setting.Config) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		appSettings: appSettings,
+	}
+}
+
+// getDiagnostics godoc
+// @Summary Get instance diagnostics
+// @Description Returns the settings version loaded by the instance serving the request, to check that every replica picked up a change (requires settings:read)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuthAdmin
+// @Success 200 {object} DiagnosticsResponse "Instance diagnostics"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden: Insufficient privileges"
+// @Router /admin/diagnostics [get]
+func (h *DiagnosticsHandler) getDiagnostics(w http.ResponseWriter, r *http.Request) {
+	response := DiagnosticsResponse{
+		SettingsVersion:  h.appSettings.Version(),
+		SettingsLoadedAt: h.appSettings.LoadedAt().UTC(),
+		SecretKeyID:      h.appSettings.SecretKeyID(),
+		ServerTime:       time.Now().UTC(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -12,68 +12,164 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	_ "github.com/MoSed3/otp-server/docs" // Keep this as is
+	"github.com/MoSed3/otp-server/internal/audit"
 	"github.com/MoSed3/otp-server/internal/config"
 	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/encryption"
 	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/otpcode"
+	"github.com/MoSed3/otp-server/internal/password"
 	"github.com/MoSed3/otp-server/internal/redis"
 	"github.com/MoSed3/otp-server/internal/repository"
 	"github.com/MoSed3/otp-server/internal/service"
+	"github.com/MoSed3/otp-server/internal/setting"
 	"github.com/MoSed3/otp-server/internal/token"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 const BasePath = "/api/v1"
 
-func newRouter(database *db.DB, redisCli *redis.Config, jwtService *token.JWTService) chi.Router {
+func newRouter(database *db.DB, redisCli *redis.Config, jwtService *token.JWTService, otpHasher *otpcode.Hasher, dataCipher *encryption.Cipher, totpManager *totp.Manager, passwordPolicy *password.Policy, passwordHasher *password.Hasher, clientIP *middleware.ClientIPResolver, ipFilter *middleware.IPFilter, appSettings *setting.Config) chi.Router {
 	r := chi.NewRouter()
 
 	// Initialize repositories
 	userRepo := repository.NewUser()
 	otpRepo := repository.NewOtp()
-	adminRepo := repository.NewAdmin()
+	otpDeliveryRepo := repository.NewOtpDelivery()
+	outboxRepo := repository.NewOutbox()
+	userTotpRepo := repository.NewUserTotp()
+	adminTotpRepo := repository.NewAdminTotp()
+	settingRepo := repository.NewSetting()
+	refreshTokenRepo := repository.NewRefreshToken()
+	sessionRepo := repository.NewSession()
+	adminRepo := repository.NewAdmin(passwordPolicy, passwordHasher)
+	roleRepo := repository.NewRole()
+	auditRepo := repository.NewAuditEvent()
+
+	auditor := audit.NewRecorder(database, auditRepo)
 
 	// Initialize services
-	userService := service.NewUserService(userRepo, otpRepo, redisCli)
-	adminService := service.NewAdminService(adminRepo, userRepo)
+	userService := service.NewUserService(userRepo, otpRepo, otpDeliveryRepo, outboxRepo, userTotpRepo, redisCli, otpHasher, dataCipher, totpManager, appSettings)
+	adminService := service.NewAdminService(adminRepo, roleRepo, userRepo, outboxRepo, adminTotpRepo, settingRepo, redisCli, totpManager, appSettings, auditRepo, auditor, passwordHasher)
+
+	tokenService := service.NewTokenService(database, refreshTokenRepo, sessionRepo, userRepo, adminRepo, redisCli, jwtService, appSettings)
 
 	// Initialize handlers (controllers)
-	userHandler := NewUserHandler(userService, jwtService)
-	adminHandler := NewAdminHandler(adminService, jwtService)
+	userHandler := NewUserHandler(userService, tokenService, appSettings)
+	adminHandler := NewAdminHandler(adminService, tokenService, jwtService)
+	authHandler := NewAuthHandler(tokenService, jwtService)
+	diagnosticsHandler := NewDiagnosticsHandler(appSettings)
 
 	// Initialize middleware components
-	userAuthenticator := middleware.NewAuthenticator(userRepo, jwtService)
-	adminAuthenticator := middleware.NewAdminAuthenticator(adminRepo, jwtService)
+	sessionValidator := middleware.NewSessionValidator(sessionRepo, redisCli)
+	userAuthenticator := middleware.NewAuthenticator(userRepo, jwtService, sessionValidator)
+	adminAuthenticator := middleware.NewAdminAuthenticator(adminRepo, roleRepo, jwtService, sessionValidator)
 	rateLimiter := middleware.NewRateLimiter(redisCli)
 
 	// Apply logging middleware globally
+	r.Use(cMiddleware.RequestID)
+	r.Use(clientIP.Middleware)
 	r.Use(cMiddleware.Logger)
+	r.Use(middleware.WithRequestInfo)
+	r.Use(ipFilter.Filter)
 	r.Use(middleware.Transaction(database))
 
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
+	// The JWKS lives at the well-known location, and under BasePath where the swagger spec documents it
+	r.Get("/.well-known/jwks.json", authHandler.jwks)
+	r.Get(BasePath+"/.well-known/jwks.json", authHandler.jwks)
 
 	// Auth routes
 	r.Route(BasePath+"/auth", func(r chi.Router) {
-		r.Use(rateLimiter.RateLimit("auth", 5, 60, true))
-		r.Post("/request-otp", userHandler.requestOTP)
-		r.Post("/verify-otp", userHandler.verifyOTP)
-		r.Post("/admin", adminHandler.adminLogin)
+		r.Use(rateLimiter.RateLimitWithBan("auth", redis.RateLimitSlidingWindow, 5, 60, ipFilter))
+		// Per-target limits hold even when an attacker rotates client addresses
+		r.With(rateLimiter.RateLimitBy("otp_phone", middleware.BodyField(func(req *RequestOTPRequest) string { return req.PhoneNumber }, middleware.NormalizePhoneNumber), redis.RateLimitSlidingWindow, 3, 600)).
+			Post("/request-otp", userHandler.requestOTP)
+		r.With(rateLimiter.RateLimitBy("otp_session", middleware.BearerToken, redis.RateLimitSlidingWindow, 5, 300)).
+			Post("/verify-otp", userHandler.verifyOTP)
+		r.With(rateLimiter.RateLimitBy("totp_phone", middleware.BodyField(func(req *TotpLoginRequest) string { return req.PhoneNumber }, middleware.NormalizePhoneNumber), redis.RateLimitSlidingWindow, 5, 300)).
+			Post("/totp-login", userHandler.totpLogin)
+		r.With(rateLimiter.RateLimitBy("admin_username", middleware.BodyField(func(req *AdminLoginRequest) string { return req.Username }, middleware.NormalizeUsername), redis.RateLimitSlidingWindow, 10, 600)).
+			Post("/admin", adminHandler.adminLogin)
+		// Every login issues a new challenge, so these are counted per admin rather than per token
+		r.With(rateLimiter.RateLimitBy("admin_challenge", middleware.BodyField(func(req *AdminMfaRequest) string {
+			return adminHandler.mfaTokenSubject(req.ChallengeToken, token.AudianceAdminMfa)
+		}, nil), redis.RateLimitSlidingWindow, 5, 300)).
+			Post("/admin/mfa", adminHandler.adminMfa)
+		r.With(rateLimiter.RateLimitBy("admin_enrollment", middleware.BodyField(func(req *AdminMfaEnrollConfirmRequest) string {
+			return adminHandler.mfaTokenSubject(req.EnrollmentToken, token.AudianceAdminMfaEnroll)
+		}, nil), redis.RateLimitSlidingWindow, 5, 300)).
+			Post("/admin/mfa/enroll/confirm", adminHandler.adminMfaEnrollConfirm)
+		r.With(rateLimiter.RateLimitBy("admin_enrollment_begin", middleware.BodyField(func(req *AdminMfaEnrollRequest) string {
+			return adminHandler.mfaTokenSubject(req.EnrollmentToken, token.AudianceAdminMfaEnroll)
+		}, nil), redis.RateLimitSlidingWindow, 5, 300)).
+			Post("/admin/mfa/enroll", adminHandler.adminMfaEnroll)
+		r.Post("/refresh", authHandler.refresh)
 	})
 
 	// User routes
-	r.Route(BasePath+"/user/profile", func(r chi.Router) {
+	r.Route(BasePath+"/user", func(r chi.Router) {
 		r.Use(userAuthenticator.Authenticate)
-		r.Use(rateLimiter.RateLimit("user", 30, 60, true))
-		r.Get("/", userHandler.getCurrentUser)
-		r.Put("/", userHandler.updateProfile)
+		r.Use(rateLimiter.RateLimit("user", redis.RateLimitTokenBucket, 30, 60))
+		r.Route("/profile", func(r chi.Router) {
+			r.Get("/", userHandler.getCurrentUser)
+			r.Put("/", userHandler.updateProfile)
+			r.Post("/totp", userHandler.beginTotpEnrollment)
+			r.Post("/totp/confirm", userHandler.confirmTotpEnrollment)
+			r.Delete("/totp", userHandler.disableTotp)
+		})
+		r.Post("/logout", userHandler.logout)
+		r.Get("/sessions", userHandler.listSessions)
+		r.Delete("/sessions/{id}", userHandler.revokeSession)
 	})
 
 	// Admin routes
 	r.Route(BasePath+"/admin", func(r chi.Router) {
 		r.Use(adminAuthenticator.Authenticate)
-		r.Use(rateLimiter.RateLimit("admin", 60, 60, true))
+		r.Use(rateLimiter.RateLimit("admin", redis.RateLimitTokenBucket, 60, 60))
+		// The profile routes only act on the calling admin's own account, so every admin may use them
 		r.Get("/profile", adminHandler.getCurrentAdmin)
-		r.Get("/users", adminHandler.searchUsers)
-		r.Get("/user/{id}", adminHandler.getUserByID)
-		r.Patch("/user/{id}/status", http.HandlerFunc(adminAuthenticator.AuthorizeSudo(http.HandlerFunc(adminHandler.updateUserStatus)).ServeHTTP))
+		r.Post("/profile/totp", adminHandler.beginTotpEnrollment)
+		r.Post("/profile/totp/confirm", adminHandler.confirmTotpEnrollment)
+		r.Delete("/profile/totp", adminHandler.disableTotp)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionSettingsRead)).Get("/diagnostics", diagnosticsHandler.getDiagnostics)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionSettingsRead)).Get("/settings", adminHandler.getSettings)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionSettingsWrite)).Patch("/settings", adminHandler.updateSettings)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionSettingsWrite)).Put("/mfa-policy", adminHandler.updateMfaPolicy)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionSettingsWrite)).Post("/secret-key/rotate", adminHandler.rotateSecretKey)
+		r.Route("/admins", func(r chi.Router) {
+			r.Use(adminAuthenticator.RequirePermission(models.PermissionAdminsManage))
+			r.Get("/", adminHandler.listAdmins)
+			r.Post("/", adminHandler.createAdmin)
+			r.Get("/{id}", adminHandler.getAdmin)
+			r.Patch("/{id}", adminHandler.updateAdmin)
+			r.Delete("/{id}", adminHandler.deleteAdmin)
+			r.Get("/{id}/lockout", adminHandler.getAdminLockout)
+			r.Delete("/{id}/lockout", adminHandler.unlockAdmin)
+		})
+		r.Route("/roles", func(r chi.Router) {
+			r.Use(adminAuthenticator.RequirePermission(models.PermissionAdminsManage))
+			r.Get("/", adminHandler.listRoles)
+			r.Post("/", adminHandler.createRole)
+			r.Patch("/{id}", adminHandler.updateRole)
+			r.Delete("/{id}", adminHandler.deleteRole)
+		})
+		r.With(adminAuthenticator.RequirePermission(models.PermissionAdminsManage)).Get("/permissions", adminHandler.listPermissions)
+		r.Route("/audit", func(r chi.Router) {
+			r.Use(adminAuthenticator.RequirePermission(models.PermissionAuditRead))
+			r.Get("/", adminHandler.searchAuditEvents)
+			r.Get("/verify", adminHandler.verifyAuditChain)
+		})
+		r.Route("/ip-lists/{list}", func(r chi.Router) {
+			r.Use(adminAuthenticator.RequirePermission(models.PermissionIPListsManage))
+			r.Get("/", adminHandler.listIPListEntries)
+			r.Post("/", adminHandler.addIPListEntry)
+			r.Delete("/", adminHandler.removeIPListEntry)
+		})
+		r.With(adminAuthenticator.RequirePermission(models.PermissionUsersRead)).Get("/users", adminHandler.searchUsers)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionUsersRead)).Get("/user/{id}", adminHandler.getUserByID)
+		r.With(adminAuthenticator.RequirePermission(models.PermissionUsersWrite)).Patch("/user/{id}/status", adminHandler.updateUserStatus)
 	})
 
 	return r
@@ -84,9 +180,9 @@ type Config struct {
 	serverConfig config.ServerConfig
 }
 
-func New(serverConfig config.ServerConfig, database *db.DB, redisCli *redis.Config, jwtService *token.JWTService) Config {
+func New(serverConfig config.ServerConfig, database *db.DB, redisCli *redis.Config, jwtService *token.JWTService, otpHasher *otpcode.Hasher, dataCipher *encryption.Cipher, totpManager *totp.Manager, passwordPolicy *password.Policy, passwordHasher *password.Hasher, clientIP *middleware.ClientIPResolver, ipFilter *middleware.IPFilter, appSettings *setting.Config) Config {
 	return Config{
-		router:       newRouter(database, redisCli, jwtService),
+		router:       newRouter(database, redisCli, jwtService, otpHasher, dataCipher, totpManager, passwordPolicy, passwordHasher, clientIP, ipFilter, appSettings),
 		serverConfig: serverConfig,
 	}
 }
@@ -3,14 +3,24 @@ package router
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 
 	"github.com/MoSed3/otp-server/internal/middleware"
 	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/repository"
 	"github.com/MoSed3/otp-server/internal/service"
+	"github.com/MoSed3/otp-server/internal/setting"
 	"github.com/MoSed3/otp-server/internal/token"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 var phoneRegex = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
@@ -27,19 +37,68 @@ type RequestOTPResponse struct {
 	Token string `json:"token"`
 }
 
+// SmsBlockedResponse is returned when no OTP was sent because of the SMS fraud defenses.
+type SmsBlockedResponse struct {
+	Error string                 `json:"error"`
+	Code  service.SmsBlockedCode `json:"code"`
+}
+
 type VerifyOTPRequest struct {
+	Code     string `json:"code,omitempty"`
+	TotpCode string `json:"totp_code,omitempty"`
+}
+
+func (v VerifyOTPRequest) validate(policy models.OtpPolicy) error {
+	switch {
+	case v.Code != "" && v.TotpCode != "":
+		return errors.New("provide either code or totp_code, not both")
+	case v.TotpCode != "":
+		return validateTotpCode(v.TotpCode)
+	default:
+		return policy.ValidateCode(v.Code)
+	}
+}
+
+type TotpLoginRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Code        string `json:"code"`
+}
+
+func (r TotpLoginRequest) validate() error {
+	if !phoneRegex.MatchString(r.PhoneNumber) {
+		return errors.New("phone_number must be in international format (e.g., +1234567890)")
+	}
+	return validateTotpCode(r.Code)
+}
+
+type TotpCodeRequest struct {
 	Code string `json:"code"`
 }
 
-func (v VerifyOTPRequest) validate() error {
-	if len(v.Code) != 6 {
-		return errors.New("code must be exactly 6 characters")
+func (r TotpCodeRequest) validate() error {
+	return validateTotpCode(r.Code)
+}
+
+func validateTotpCode(code string) error {
+	if len(code) != totp.Digits {
+		return fmt.Errorf("totp code must be exactly %d digits", totp.Digits)
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return errors.New("totp code must contain only digits")
+		}
 	}
 	return nil
 }
 
+type TotpEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
 type VerifyOTPResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type UpdateProfileRequest struct {
@@ -75,17 +134,41 @@ func UserToResponse(u *models.User) UserResponse {
 	}
 }
 
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	DeviceInfo string    `json:"device_info"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func SessionToResponse(s *models.Session, currentJti string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID,
+		DeviceInfo: s.DeviceInfo,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.Jti == currentJti,
+	}
+}
+
 // UserHandler handles user-related HTTP requests.
 type UserHandler struct {
-	userService service.UserService
-	jwtService  *token.JWTService
+	userService  service.UserService
+	tokenService service.TokenService
+	appSettings  *setting.Config
 }
 
 // NewUserHandler creates a new UserHandler.
-func NewUserHandler(userService service.UserService, jwtService *token.JWTService) *UserHandler {
+func NewUserHandler(userService service.UserService, tokenService service.TokenService, appSettings *setting.Config) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		jwtService:  jwtService,
+		userService:  userService,
+		tokenService: tokenService,
+		appSettings:  appSettings,
 	}
 }
 
@@ -98,6 +181,10 @@ func NewUserHandler(userService service.UserService, jwtService *token.JWTServic
 // @Param request body RequestOTPRequest true "Phone number in international format"
 // @Success 200 {object} RequestOTPResponse "OTP token generated successfully"
 // @Failure 400 {string} string "Invalid request format or phone number"
+// @Failure 403 {string} string "User is disabled"
+// @Failure 403 {object} SmsBlockedResponse "OTPs cannot be sent to the country of the number (code sms_country_not_allowed)"
+// @Failure 429 {string} string "Too many OTP requests, see Retry-After header"
+// @Failure 429 {object} SmsBlockedResponse "An SMS budget is exhausted, see code and Retry-After header"
 // @Failure 500 {string} string "Internal server error"
 // @Router /auth/request-otp [post]
 func (h *UserHandler) requestOTP(w http.ResponseWriter, r *http.Request) {
@@ -114,9 +201,17 @@ func (h *UserHandler) requestOTP(w http.ResponseWriter, r *http.Request) {
 
 	token, err := h.userService.Login(r.Context(), r, req.PhoneNumber)
 	if err != nil {
-		if errors.Is(err, service.ErrUserDisabled) {
+		var throttled *repository.ErrOtpThrottled
+		var smsBlocked *service.ErrSmsBlocked
+		switch {
+		case errors.As(err, &smsBlocked):
+			writeSmsBlocked(w, smsBlocked)
+		case errors.Is(err, service.ErrUserDisabled):
 			http.Error(w, err.Error(), http.StatusForbidden)
-		} else {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -130,15 +225,31 @@ func (h *UserHandler) requestOTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// writeSmsBlocked reports a refusal by the SMS fraud defenses with its code, so clients can tell it
+// apart from per-user throttling.
+func writeSmsBlocked(w http.ResponseWriter, err *service.ErrSmsBlocked) {
+	status := http.StatusTooManyRequests
+	if err.Code == service.SmsCountryNotAllowed {
+		status = http.StatusForbidden
+	}
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(SmsBlockedResponse{Error: err.Error(), Code: err.Code})
+}
+
 // verifyOTP godoc
 // @Summary Verify OTP code
-// @Description Verifies the OTP code and returns JWT token for authenticated user
+// @Description Verifies the OTP code, or an authenticator app code for users enrolled in TOTP, and returns JWT token for authenticated user
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token from request-otp endpoint"
-// @Param request body VerifyOTPRequest true "6-character OTP code"
-// @Success 200 {object} VerifyOTPResponse "JWT token for authenticated user"
+// @Param request body VerifyOTPRequest true "OTP code (length and alphabet follow the OTP policy) or totp_code"
+// @Success 200 {object} VerifyOTPResponse "JWT and refresh token for authenticated user"
 // @Failure 400 {string} string "Invalid request format or OTP code"
 // @Failure 401 {string} string "Invalid bearer token or OTP code"
 // @Failure 500 {string} string "Internal server error"
@@ -158,29 +269,97 @@ func (h *UserHandler) verifyOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := req.validate(h.appSettings.OtpPolicy()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user *models.User
+	var err error
+	if req.TotpCode != "" {
+		user, err = h.userService.VerifyTOTP(r.Context(), r, uidToken, req.TotpCode)
+	} else {
+		user, err = h.userService.VerifyOTP(r.Context(), r, uidToken, req.Code)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, totp.ErrInvalidCode), errors.Is(err, redis.ErrInvalidCode), errors.Is(err, redis.ErrSessionNotFound):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, service.ErrTotpNotEnrolled):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	pair, err := h.tokenService.IssueTokens(tx, r, user.ID, token.AudianceUser)
+	if err != nil {
+		http.Error(w, "Failed to generate JWT token", http.StatusInternalServerError)
+		return
+	}
+
+	response := VerifyOTPResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// totpLogin godoc
+// @Summary Log in with an authenticator app code
+// @Description Logs in a user enrolled in TOTP with the phone number and a code from the authenticator app, without requesting an OTP, so no SMS is sent
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body TotpLoginRequest true "Phone number in international format and authenticator app code"
+// @Success 200 {object} VerifyOTPResponse "JWT and refresh token for authenticated user"
+// @Failure 400 {string} string "Invalid request format, phone number or code"
+// @Failure 401 {string} string "Invalid phone number or code"
+// @Failure 403 {string} string "User is disabled"
+// @Failure 429 {string} string "Too many attempts, see Retry-After header"
+// @Failure 500 {string} string "Internal server error"
+// @Router /auth/totp-login [post]
+func (h *UserHandler) totpLogin(w http.ResponseWriter, r *http.Request) {
+	var req TotpLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
 	if err := req.validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.userService.VerifyOTP(r.Context(), r, uidToken, req.Code)
+	user, err := h.userService.LoginTOTP(r.Context(), r, req.PhoneNumber, req.Code)
 	if err != nil {
-		if errors.Is(err, service.ErrUserDisabled) {
+		switch {
+		case errors.Is(err, service.ErrUserDisabled):
 			http.Error(w, err.Error(), http.StatusForbidden)
-		} else {
+		case errors.Is(err, totp.ErrInvalidCode):
+			http.Error(w, "Invalid phone number or code", http.StatusUnauthorized)
+		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
-	jwtToken, err := h.jwtService.GenerateToken(user.ID, token.AudianceUser)
+	tx := middleware.GetTxFromRequest(r)
+	pair, err := h.tokenService.IssueTokens(tx, r, user.ID, token.AudianceUser)
 	if err != nil {
 		http.Error(w, "Failed to generate JWT token", http.StatusInternalServerError)
 		return
 	}
 
 	response := VerifyOTPResponse{
-		Token: jwtToken,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -245,3 +424,215 @@ func (h *UserHandler) updateProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
+
+// beginTotpEnrollment godoc
+// @Summary Begin TOTP enrollment
+// @Description Generates a new authenticator app secret for the authenticated user. The enrollment is active once confirmed with a first code.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuthUser
+// @Success 200 {object} TotpEnrollmentResponse "Secret and otpauth:// URI to add to an authenticator app"
+// @Failure 401 {string} string "Unauthorized - invalid or missing JWT token"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/profile/totp [post]
+func (h *UserHandler) beginTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromRequest(r)
+
+	tx := middleware.GetTxFromRequest(r)
+	enrollment, err := h.userService.BeginTotpEnrollment(tx, user)
+	if err != nil {
+		if errors.Is(err, service.ErrTotpAlreadyEnrolled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := TotpEnrollmentResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// confirmTotpEnrollment godoc
+// @Summary Confirm TOTP enrollment
+// @Description Activates the pending authenticator app secret with a first code
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuthUser
+// @Param request body TotpCodeRequest true "Current authenticator app code"
+// @Success 204 "TOTP enabled"
+// @Failure 400 {string} string "Invalid request format or no pending enrollment"
+// @Failure 401 {string} string "Invalid TOTP code or JWT token"
+// @Failure 409 {string} string "TOTP is already enrolled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/profile/totp/confirm [post]
+func (h *UserHandler) confirmTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromRequest(r)
+
+	var req TotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.userService.ConfirmTotpEnrollment(tx, user, req.Code); err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// disableTotp godoc
+// @Summary Disable TOTP
+// @Description Removes the authenticated user's authenticator app enrollment. Requires a current code.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuthUser
+// @Param request body TotpCodeRequest true "Current authenticator app code"
+// @Success 204 "TOTP disabled"
+// @Failure 400 {string} string "Invalid request format or TOTP not enrolled"
+// @Failure 401 {string} string "Invalid TOTP code or JWT token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/profile/totp [delete]
+func (h *UserHandler) disableTotp(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromRequest(r)
+
+	var req TotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.userService.DisableTotp(tx, user, req.Code); err != nil {
+		writeTotpError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logout godoc
+// @Summary Log out
+// @Description Revokes the session of the presented access token together with its refresh token
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuthUser
+// @Success 204 "Logged out"
+// @Failure 401 {string} string "Unauthorized - invalid or missing JWT token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/logout [post]
+func (h *UserHandler) logout(w http.ResponseWriter, r *http.Request) {
+	tx := middleware.GetTxFromRequest(r)
+	if err := h.tokenService.Logout(r.Context(), tx, middleware.GetSessionIDFromRequest(r)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listSessions godoc
+// @Summary List active sessions
+// @Description Returns the devices the authenticated user is signed in on, most recently used first
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuthUser
+// @Success 200 {array} SessionResponse "Active sessions"
+// @Failure 401 {string} string "Unauthorized - invalid or missing JWT token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/sessions [get]
+func (h *UserHandler) listSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromRequest(r)
+
+	tx := middleware.GetTxFromRequest(r)
+	sessions, err := h.tokenService.ListSessions(tx, user.ID, token.AudianceUser)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	currentJti := middleware.GetSessionIDFromRequest(r)
+	response := make([]SessionResponse, len(sessions))
+	for i := range sessions {
+		response[i] = SessionToResponse(&sessions[i], currentJti)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// revokeSession godoc
+// @Summary Revoke a session
+// @Description Signs the authenticated user out of one device
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path int true "Session ID"
+// @Security BearerAuthUser
+// @Success 204 "Session revoked"
+// @Failure 400 {string} string "Invalid session ID"
+// @Failure 401 {string} string "Unauthorized - invalid or missing JWT token"
+// @Failure 404 {string} string "Session not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /user/sessions/{id} [delete]
+func (h *UserHandler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromRequest(r)
+
+	sessionIDStr := chi.URLParam(r, "id")
+	sessionID, err := strconv.ParseUint(sessionIDStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	tx := middleware.GetTxFromRequest(r)
+	if err = h.tokenService.RevokeSession(r.Context(), tx, user.ID, token.AudianceUser, uint(sessionID)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTotpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, totp.ErrInvalidCode), errors.Is(err, service.ErrMfaChallengeInvalid):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrTotpNotEnrolled):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrTotpAlreadyEnrolled), errors.Is(err, service.ErrMfaEnforced):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+)
+
+var (
+	ErrAdminNotFound       = errors.New("admin not found")
+	ErrAdminUsernameTaken  = errors.New("username is already taken")
+	ErrLastSuperAdmin      = errors.New("the last super admin cannot be demoted or deleted")
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrPrivilegeEscalation = errors.New("cannot grant or manage permissions you do not hold")
+)
+
+func (s *AdminServiceImpl) ListAdmins(tx *gorm.DB) ([]models.Admin, error) {
+	return s.adminRepo.ListAll(tx)
+}
+
+func (s *AdminServiceImpl) GetAdmin(tx *gorm.DB, adminID uint) (*models.Admin, error) {
+	admin, err := s.adminRepo.GetByID(tx, adminID)
+	switch {
+	case err != nil:
+		return nil, err
+	case admin == nil:
+		return nil, ErrAdminNotFound
+	}
+	return admin, nil
+}
+
+// CreateAdmin creates an admin with role. The actor must hold every permission of the role.
+func (s *AdminServiceImpl) CreateAdmin(tx *gorm.DB, actor *models.Admin, username, password string, role models.AdminRole) (*models.Admin, error) {
+	if err := s.checkCanAssign(tx, actor, role); err != nil {
+		return nil, err
+	}
+	if err := s.checkUsernameFree(tx, username); err != nil {
+		return nil, err
+	}
+
+	admin, err := s.adminRepo.Create(tx, username, password, role)
+	if err != nil {
+		return nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditAdminCreated), audit.TargetAdmin, admin.ID)
+	event.Changes = map[string]models.AuditChange{
+		"username": {After: admin.Username},
+		"role":     {After: admin.Role},
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s created admin %s with role %d", actor.Username, admin.Username, admin.Role)
+	return admin, nil
+}
+
+// UpdateAdmin changes an admin's username, role or password. Setting a password logs the admin
+// out everywhere, and the last super admin cannot be demoted. The actor must hold every permission
+// of both the admin's current role and the new one.
+func (s *AdminServiceImpl) UpdateAdmin(tx *gorm.DB, actor *models.Admin, adminID uint, updates repository.AdminUpdate) (*models.Admin, error) {
+	admin, err := s.GetAdmin(tx, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkCanAssign(tx, actor, admin.Role); err != nil {
+		return nil, err
+	}
+	if updates.Role != nil {
+		if err = s.checkCanAssign(tx, actor, *updates.Role); err != nil {
+			return nil, err
+		}
+	}
+
+	if updates.Username != nil && *updates.Username != admin.Username {
+		if err = s.checkUsernameFree(tx, *updates.Username); err != nil {
+			return nil, err
+		}
+	}
+	if updates.Role != nil && admin.Role == models.RoleSuperAdmin && *updates.Role != models.RoleSuperAdmin {
+		if err = s.checkNotLastSuperAdmin(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.adminRepo.Update(tx, admin.ID, updates); err != nil {
+		return nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditAdminUpdated), audit.TargetAdmin, admin.ID)
+	event.Changes = make(map[string]models.AuditChange)
+	if updates.Username != nil && *updates.Username != admin.Username {
+		event.Changes["username"] = models.AuditChange{Before: admin.Username, After: *updates.Username}
+	}
+	if updates.Role != nil && *updates.Role != admin.Role {
+		event.Changes["role"] = models.AuditChange{Before: admin.Role, After: *updates.Role}
+	}
+	if updates.NewPassword != nil {
+		event.Changes["password"] = models.AuditChange{Before: audit.Redacted, After: audit.Redacted}
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s updated admin %s: Username=%t, Role=%t, Password=%t", actor.Username, admin.Username,
+		updates.Username != nil, updates.Role != nil, updates.NewPassword != nil)
+	return s.GetAdmin(tx, admin.ID)
+}
+
+// DeleteAdmin removes an admin. The last super admin cannot be deleted.
+func (s *AdminServiceImpl) DeleteAdmin(tx *gorm.DB, actor *models.Admin, adminID uint) error {
+	admin, err := s.GetAdmin(tx, adminID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.checkCanAssign(tx, actor, admin.Role); err != nil {
+		return err
+	}
+
+	if admin.Role == models.RoleSuperAdmin {
+		if err = s.checkNotLastSuperAdmin(tx); err != nil {
+			return err
+		}
+	}
+
+	if err = s.adminRepo.Delete(tx, admin.ID); err != nil {
+		return err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditAdminDeleted), audit.TargetAdmin, admin.ID)
+	event.Changes = map[string]models.AuditChange{
+		"username": {Before: admin.Username},
+		"role":     {Before: admin.Role},
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return err
+	}
+
+	log.Printf("Admin %s deleted admin %s", actor.Username, admin.Username)
+	return nil
+}
+
+// checkUsernameFree fails if username belongs to an admin, deleted admins included.
+func (s *AdminServiceImpl) checkUsernameFree(tx *gorm.DB, username string) error {
+	taken, err := s.adminRepo.UsernameExists(tx, username)
+	switch {
+	case err != nil:
+		return err
+	case taken:
+		return ErrAdminUsernameTaken
+	}
+	return nil
+}
+
+// checkNotLastSuperAdmin fails if removing one super admin would leave none. The super admin rows
+// stay locked until the transaction ends so two super admins cannot demote each other concurrently.
+func (s *AdminServiceImpl) checkNotLastSuperAdmin(tx *gorm.DB) error {
+	supers, err := s.adminRepo.ListByRoleForUpdate(tx, models.RoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	if len(supers) <= 1 {
+		return ErrLastSuperAdmin
+	}
+	return nil
+}
+
+// checkCanAssign fails unless role exists and actor holds every permission it grants.
+func (s *AdminServiceImpl) checkCanAssign(tx *gorm.DB, actor *models.Admin, roleID models.AdminRole) error {
+	role, err := s.roleRepo.GetByID(tx, uint(roleID))
+	switch {
+	case err != nil:
+		return err
+	case role == nil:
+		return ErrRoleNotFound
+	}
+	return s.checkCanGrant(tx, actor, role.EffectivePermissions())
+}
+
+// checkCanGrant fails unless actor holds every permission in perms, so admins cannot escalate their own privileges.
+func (s *AdminServiceImpl) checkCanGrant(tx *gorm.DB, actor *models.Admin, perms []models.Permission) error {
+	actorRole, err := s.roleRepo.GetByID(tx, uint(actor.Role))
+	switch {
+	case err != nil:
+		return err
+	case actorRole == nil || !actorRole.HasPermissions(perms...):
+		return ErrPrivilegeEscalation
+	}
+	return nil
+}
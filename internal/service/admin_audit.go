@@ -0,0 +1,17 @@
+package service
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+func (s *AdminServiceImpl) SearchAuditEvents(tx *gorm.DB, params models.AuditSearchParams) ([]models.AuditEvent, int64, error) {
+	return s.auditRepo.Search(tx, params)
+}
+
+// VerifyAuditChain recomputes the audit hash chain. It returns the number of intact events and the
+// first event that was altered, or nil when the whole chain is intact.
+func (s *AdminServiceImpl) VerifyAuditChain(tx *gorm.DB) (int64, *models.AuditEvent, error) {
+	return s.auditRepo.Verify(tx)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+var (
+	ErrUnknownIPList       = errors.New("unknown IP list, use allow or deny")
+	ErrInvalidNetwork      = errors.New("invalid address or CIDR")
+	ErrIPListEntryNotFound = errors.New("IP list entry not found")
+)
+
+// ListIPListEntries returns the entries of list that have not expired.
+func (s *AdminServiceImpl) ListIPListEntries(ctx context.Context, list redis.IPList) ([]redis.IPListEntry, error) {
+	if !list.IsValid() {
+		return nil, ErrUnknownIPList
+	}
+	return s.redisCli.GetIPListEntries(ctx, list)
+}
+
+// AddIPListEntry puts network on list, replacing any entry for it, once the audit event has committed.
+// The entry expires after expiresIn seconds, or never when expiresIn is nil.
+func (s *AdminServiceImpl) AddIPListEntry(ctx context.Context, tx *gorm.DB, actor *models.Admin, list redis.IPList, network, reason string, expiresIn *uint) (*redis.IPListEntry, error) {
+	if !list.IsValid() {
+		return nil, ErrUnknownIPList
+	}
+	_, normalized, err := redis.ParseNetwork(network)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNetwork, network)
+	}
+
+	now := time.Now().UTC()
+	entry := redis.IPListEntry{
+		Network:   normalized,
+		Reason:    reason,
+		CreatedBy: actor.Username,
+		CreatedAt: now,
+	}
+	if expiresIn != nil {
+		expiresAt := now.Add(time.Duration(*expiresIn) * time.Second)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	event := audit.OnNetwork(audit.ByAdmin(actor, models.AuditIPListAdded), string(list), normalized)
+	event.Changes = map[string]models.AuditChange{
+		"reason":     {After: entry.Reason},
+		"expires_at": {After: entry.ExpiresAt},
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+	middleware.AfterCommit(tx, func() {
+		if err := s.redisCli.AddIPListEntry(context.WithoutCancel(ctx), list, entry); err != nil {
+			log.Printf("Failed to add %s to the %s list: %v", normalized, list, err)
+			return
+		}
+		log.Printf("Admin %s added %s to the %s list", actor.Username, normalized, list)
+	})
+	return &entry, nil
+}
+
+// RemoveIPListEntry takes network off list once the audit event has committed.
+func (s *AdminServiceImpl) RemoveIPListEntry(ctx context.Context, tx *gorm.DB, actor *models.Admin, list redis.IPList, network string) error {
+	if !list.IsValid() {
+		return ErrUnknownIPList
+	}
+	_, normalized, err := redis.ParseNetwork(network)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidNetwork, network)
+	}
+
+	listed, err := s.redisCli.HasIPListEntry(ctx, list, normalized)
+	if err != nil {
+		return err
+	}
+	if !listed {
+		return ErrIPListEntryNotFound
+	}
+
+	if err = s.auditor.Record(tx, audit.OnNetwork(audit.ByAdmin(actor, models.AuditIPListRemoved), string(list), normalized)); err != nil {
+		return err
+	}
+	middleware.AfterCommit(tx, func() {
+		if _, err := s.redisCli.RemoveIPListEntry(context.WithoutCancel(ctx), list, normalized); err != nil {
+			log.Printf("Failed to remove %s from the %s list: %v", normalized, list, err)
+			return
+		}
+		log.Printf("Admin %s removed %s from the %s list", actor.Username, normalized, list)
+	})
+	return nil
+}
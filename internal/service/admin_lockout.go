@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+// ErrAdminLoginThrottled is returned when a username must wait after failed logins or is locked out.
+type ErrAdminLoginThrottled struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ErrAdminLoginThrottled) Error() string {
+	return fmt.Sprintf("%s, retry after %d seconds", e.Reason, int(e.RetryAfter.Seconds()))
+}
+
+// reserveLoginAttempt rejects a login for username while it is locked or inside its delay, and otherwise
+// reserves the attempt so parallel attempts are counted too. The check runs for unknown usernames as
+// well, so lockouts do not reveal which admins exist. A reserved attempt ends with countLoginFailure,
+// releaseLoginAttempt or resetLoginFailures.
+func (s *AdminServiceImpl) reserveLoginAttempt(ctx context.Context, username string) error {
+	retryAfter, locked, err := s.redisCli.ReserveAdminLoginAttempt(ctx, username, s.appSettings.AdminLockoutPolicy())
+	switch {
+	case err != nil:
+		return err
+	case locked:
+		return &ErrAdminLoginThrottled{Reason: "account is temporarily locked", RetryAfter: max(retryAfter, time.Second)}
+	case retryAfter > 0:
+		return &ErrAdminLoginThrottled{Reason: "too many failed login attempts", RetryAfter: max(retryAfter, time.Second)}
+	}
+	return nil
+}
+
+// releaseLoginAttempt ends a reserved attempt that neither failed nor completed the login, such as a
+// correct password that still needs the second factor.
+func (s *AdminServiceImpl) releaseLoginAttempt(ctx context.Context, username string) {
+	if err := s.redisCli.ReleaseAdminLoginAttempt(context.WithoutCancel(ctx), username); err != nil {
+		log.Printf("Failed to release login attempt for admin %s: %v", username, err)
+	}
+}
+
+// resetLoginFailures ends a reserved attempt that completed the login and forgets the failed logins of username.
+func (s *AdminServiceImpl) resetLoginFailures(ctx context.Context, username string) {
+	if err := s.redisCli.ResetAdminLoginFailures(context.WithoutCancel(ctx), username); err != nil {
+		log.Printf("Failed to reset failed logins for admin %s: %v", username, err)
+	}
+}
+
+// countLoginFailure counts a failed password or MFA code for username and audits the lockout it
+// may cause. admin is nil when the username does not exist.
+func (s *AdminServiceImpl) countLoginFailure(ctx context.Context, username string, admin *models.Admin) {
+	state, err := s.redisCli.RecordAdminLoginFailure(ctx, username, s.appSettings.AdminLockoutPolicy())
+	if err != nil {
+		log.Printf("Failed to record failed login for admin %s: %v", username, err)
+		return
+	}
+	if state.LockedUntil.IsZero() {
+		return
+	}
+
+	log.Printf("Admin username %s locked out until %s", username, state.LockedUntil.Format(time.RFC3339))
+	event := audit.ByAnonymous(username, models.AuditAdminLockedOut)
+	if admin != nil {
+		audit.On(event, audit.TargetAdmin, admin.ID)
+	}
+	event.Changes = map[string]models.AuditChange{"locked_until": {After: state.LockedUntil}}
+	s.auditor.RecordDetached(ctx, event)
+}
+
+// GetAdminLockout returns the failed login state of an admin.
+func (s *AdminServiceImpl) GetAdminLockout(ctx context.Context, tx *gorm.DB, adminID uint) (*redis.AdminLoginState, error) {
+	admin, err := s.GetAdmin(tx, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.redisCli.GetAdminLoginState(ctx, admin.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UnlockAdmin lifts the lockout of an admin and forgets its failed logins. The actor must hold
+// every permission of the admin's role.
+func (s *AdminServiceImpl) UnlockAdmin(ctx context.Context, tx *gorm.DB, actor *models.Admin, adminID uint) error {
+	admin, err := s.GetAdmin(tx, adminID)
+	if err != nil {
+		return err
+	}
+	if err = s.checkCanAssign(tx, actor, admin.Role); err != nil {
+		return err
+	}
+
+	if err = s.auditor.Record(tx, audit.On(audit.ByAdmin(actor, models.AuditAdminUnlocked), audit.TargetAdmin, admin.ID)); err != nil {
+		return err
+	}
+	if err = s.redisCli.ResetAdminLoginFailures(ctx, admin.Username); err != nil {
+		return err
+	}
+
+	log.Printf("Admin %s unlocked admin %s", actor.Username, admin.Username)
+	return nil
+}
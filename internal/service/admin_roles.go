@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+var (
+	ErrRoleNameTaken     = errors.New("role name is already taken")
+	ErrRoleInUse         = errors.New("role is assigned to admins")
+	ErrBuiltinRole       = errors.New("built-in role cannot be changed")
+	ErrInvalidPermission = errors.New("unknown permission")
+)
+
+func (s *AdminServiceImpl) ListRoles(tx *gorm.DB) ([]models.Role, error) {
+	return s.roleRepo.ListAll(tx)
+}
+
+// CreateRole creates a custom role. The actor must hold every permission granted by it.
+func (s *AdminServiceImpl) CreateRole(tx *gorm.DB, actor *models.Admin, name, description string, permissions []models.Permission) (*models.Role, error) {
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+	if err := s.checkCanGrant(tx, actor, permissions); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleNameFree(tx, name); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.Create(tx, role); err != nil {
+		return nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditRoleCreated), audit.TargetRole, role.ID)
+	event.Changes = map[string]models.AuditChange{
+		"name":        {After: role.Name},
+		"permissions": {After: role.Permissions},
+	}
+	if err := s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s created role %s with permissions %v", actor.Username, role.Name, role.Permissions)
+	return role, nil
+}
+
+// UpdateRole changes a role. The Super role is immutable, and the actor must hold every permission
+// of the role both before and after the change.
+func (s *AdminServiceImpl) UpdateRole(tx *gorm.DB, actor *models.Admin, roleID uint, update models.RoleUpdate) (*models.Role, error) {
+	role, err := s.getMutableRole(tx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkCanGrant(tx, actor, role.Permissions); err != nil {
+		return nil, err
+	}
+	before := *role
+
+	if update.Name != nil && *update.Name != role.Name {
+		if role.Builtin {
+			return nil, ErrBuiltinRole
+		}
+		if err = s.checkRoleNameFree(tx, *update.Name); err != nil {
+			return nil, err
+		}
+		role.Name = *update.Name
+	}
+	if update.Description != nil {
+		role.Description = *update.Description
+	}
+	if update.Permissions != nil {
+		if err = validatePermissions(update.Permissions); err != nil {
+			return nil, err
+		}
+		if err = s.checkCanGrant(tx, actor, update.Permissions); err != nil {
+			return nil, err
+		}
+		role.Permissions = update.Permissions
+	}
+
+	if err = s.roleRepo.Update(tx, role); err != nil {
+		return nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditRoleUpdated), audit.TargetRole, role.ID)
+	event.Changes = audit.Diff(before, *role, "CreatedAt", "UpdatedAt")
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s updated role %s with permissions %v", actor.Username, role.Name, role.Permissions)
+	return role, nil
+}
+
+// DeleteRole removes a custom role that is not assigned to any admin.
+func (s *AdminServiceImpl) DeleteRole(tx *gorm.DB, actor *models.Admin, roleID uint) error {
+	role, err := s.getMutableRole(tx, roleID)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+	if err = s.checkCanGrant(tx, actor, role.Permissions); err != nil {
+		return err
+	}
+
+	count, err := s.roleRepo.CountAdmins(tx, role.ID)
+	switch {
+	case err != nil:
+		return err
+	case count > 0:
+		return ErrRoleInUse
+	}
+
+	if err = s.roleRepo.Delete(tx, role); err != nil {
+		return err
+	}
+
+	event := audit.On(audit.ByAdmin(actor, models.AuditRoleDeleted), audit.TargetRole, role.ID)
+	event.Changes = map[string]models.AuditChange{
+		"name":        {Before: role.Name},
+		"permissions": {Before: role.Permissions},
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return err
+	}
+
+	log.Printf("Admin %s deleted role %s", actor.Username, role.Name)
+	return nil
+}
+
+func (s *AdminServiceImpl) getMutableRole(tx *gorm.DB, roleID uint) (*models.Role, error) {
+	role, err := s.roleRepo.GetByID(tx, roleID)
+	switch {
+	case err != nil:
+		return nil, err
+	case role == nil:
+		return nil, ErrRoleNotFound
+	case role.ID == uint(models.RoleSuperAdmin):
+		return nil, ErrBuiltinRole
+	}
+	return role, nil
+}
+
+func (s *AdminServiceImpl) checkRoleNameFree(tx *gorm.DB, name string) error {
+	existing, err := s.roleRepo.GetByName(tx, name)
+	switch {
+	case err != nil:
+		return err
+	case existing != nil:
+		return ErrRoleNameTaken
+	}
+	return nil
+}
+
+func validatePermissions(permissions []models.Permission) error {
+	for _, p := range permissions {
+		if !p.IsValid() {
+			return ErrInvalidPermission
+		}
+	}
+	return nil
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"log"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/MoSed3/otp-server/internal/audit"
 	"github.com/MoSed3/otp-server/internal/middleware"
 	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/password"
+	"github.com/MoSed3/otp-server/internal/redis"
 	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -18,49 +23,156 @@ var ErrUserNotFound = errors.New("user not found")
 // AdminService defines the interface for admin-related business logic.
 type AdminService interface {
 	Login(ctx context.Context, username, password string) (*models.Admin, error)
+	GetAdminLockout(ctx context.Context, tx *gorm.DB, adminID uint) (*redis.AdminLoginState, error)
+	UnlockAdmin(ctx context.Context, tx *gorm.DB, actor *models.Admin, adminID uint) error
 	SearchUsers(tx *gorm.DB, params models.UserSearchParams) ([]models.User, int64, error)
 	GetUserByID(tx *gorm.DB, userID uint) (*models.User, error)
-	UpdateUserStatus(tx *gorm.DB, userID uint, status models.UserStatus) (*models.User, error)
+	UpdateUserStatus(tx *gorm.DB, admin *models.Admin, userID uint, status models.UserStatus) (*models.User, error)
+	CheckMfa(tx *gorm.DB, admin *models.Admin) (bool, error)
+	VerifyMfa(tx *gorm.DB, challenge MfaChallenge, code string) (*models.Admin, error)
+	BeginMfaEnrollment(tx *gorm.DB, challenge MfaChallenge) (*totp.Enrollment, error)
+	CompleteMfaEnrollment(tx *gorm.DB, challenge MfaChallenge, code string) (*models.Admin, error)
+	BeginTotpEnrollment(tx *gorm.DB, admin *models.Admin) (*totp.Enrollment, error)
+	ConfirmTotpEnrollment(tx *gorm.DB, admin *models.Admin, code string) error
+	DisableTotp(tx *gorm.DB, admin *models.Admin, code string) error
+	SetMfaRequired(tx *gorm.DB, admin *models.Admin, required bool) error
+	RotateSecretKey(tx *gorm.DB, admin *models.Admin, gracePeriod *uint) (string, *time.Time, error)
+	GetSettings(tx *gorm.DB) (*models.Setting, error)
+	UpdateSettings(tx *gorm.DB, admin *models.Admin, update models.SettingUpdate) (*models.Setting, error)
+	ListAdmins(tx *gorm.DB) ([]models.Admin, error)
+	GetAdmin(tx *gorm.DB, adminID uint) (*models.Admin, error)
+	CreateAdmin(tx *gorm.DB, actor *models.Admin, username, password string, role models.AdminRole) (*models.Admin, error)
+	UpdateAdmin(tx *gorm.DB, actor *models.Admin, adminID uint, updates repository.AdminUpdate) (*models.Admin, error)
+	DeleteAdmin(tx *gorm.DB, actor *models.Admin, adminID uint) error
+	ListRoles(tx *gorm.DB) ([]models.Role, error)
+	CreateRole(tx *gorm.DB, actor *models.Admin, name, description string, permissions []models.Permission) (*models.Role, error)
+	UpdateRole(tx *gorm.DB, actor *models.Admin, roleID uint, update models.RoleUpdate) (*models.Role, error)
+	DeleteRole(tx *gorm.DB, actor *models.Admin, roleID uint) error
+	SearchAuditEvents(tx *gorm.DB, params models.AuditSearchParams) ([]models.AuditEvent, int64, error)
+	VerifyAuditChain(tx *gorm.DB) (int64, *models.AuditEvent, error)
+	ListIPListEntries(ctx context.Context, list redis.IPList) ([]redis.IPListEntry, error)
+	AddIPListEntry(ctx context.Context, tx *gorm.DB, actor *models.Admin, list redis.IPList, network, reason string, expiresIn *uint) (*redis.IPListEntry, error)
+	RemoveIPListEntry(ctx context.Context, tx *gorm.DB, actor *models.Admin, list redis.IPList, network string) error
 }
 
 // AdminServiceImpl implements AdminService.
 type AdminServiceImpl struct {
-	adminRepo repository.Admin
-	userRepo  repository.User
+	adminRepo      repository.Admin
+	roleRepo       repository.Role
+	userRepo       repository.User
+	outboxRepo     repository.Outbox
+	adminTotpRepo  repository.AdminTotp
+	settingRepo    repository.Setting
+	redisCli       *redis.Config
+	totpManager    *totp.Manager
+	appSettings    *setting.Config
+	auditRepo      repository.AuditEvent
+	auditor        *audit.Recorder
+	passwordHasher *password.Hasher
 }
 
 // NewAdminService creates a new instance of AdminService.
-func NewAdminService(adminRepo repository.Admin, userRepo repository.User) AdminService {
+func NewAdminService(adminRepo repository.Admin, roleRepo repository.Role, userRepo repository.User, outboxRepo repository.Outbox,
+	adminTotpRepo repository.AdminTotp, settingRepo repository.Setting, redisCli *redis.Config, totpManager *totp.Manager, appSettings *setting.Config,
+	auditRepo repository.AuditEvent, auditor *audit.Recorder, passwordHasher *password.Hasher) AdminService {
 	return &AdminServiceImpl{
-		adminRepo: adminRepo,
-		userRepo:  userRepo,
+		adminRepo:      adminRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+		outboxRepo:     outboxRepo,
+		adminTotpRepo:  adminTotpRepo,
+		settingRepo:    settingRepo,
+		redisCli:       redisCli,
+		totpManager:    totpManager,
+		appSettings:    appSettings,
+		auditRepo:      auditRepo,
+		auditor:        auditor,
+		passwordHasher: passwordHasher,
 	}
 }
 
-func (s *AdminServiceImpl) Login(ctx context.Context, username, password string) (*models.Admin, error) {
+func (s *AdminServiceImpl) Login(ctx context.Context, username, plainPassword string) (*models.Admin, error) {
 	log.Printf("Admin login attempt for username: %s", username)
 
 	tx := middleware.GetTxFromContext(ctx)
 
+	if err := s.reserveLoginAttempt(ctx, username); err != nil {
+		log.Printf("Admin login rejected for username %s: %v", username, err)
+		return nil, err
+	}
+
 	admin, err := s.adminRepo.GetByUsername(tx, username)
 	if err != nil {
 		log.Printf("Failed to get admin by username %s: %v", username, err)
+		s.releaseLoginAttempt(ctx, username)
 		return nil, errors.New("invalid username or password")
 	}
 	if admin == nil {
 		log.Printf("Admin not found for username: %s", username)
+		s.passwordHasher.SimulateVerify(plainPassword)
+		s.auditor.RecordDetached(ctx, audit.ByAnonymous(username, models.AuditAdminLoginFailed))
+		s.countLoginFailure(ctx, username, nil)
 		return nil, errors.New("invalid username or password")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(admin.HashedPassword), []byte(password)); err != nil {
-		log.Printf("Password mismatch for admin %s: %v", username, err)
+	match, err := s.passwordHasher.Verify(admin.HashedPassword, plainPassword)
+	if err != nil {
+		log.Printf("Failed to verify password of admin %s: %v", username, err)
+	}
+	if !match {
+		log.Printf("Password mismatch for admin %s", username)
+		s.auditor.RecordDetached(ctx, audit.On(audit.ByAnonymous(username, models.AuditAdminLoginFailed), audit.TargetAdmin, admin.ID))
+		s.countLoginFailure(ctx, username, admin)
 		return nil, errors.New("invalid username or password")
 	}
 
+	// The failed logins are only forgotten once the login is complete, so guessing the second
+	// factor after a correct password still counts towards the lockout.
+	// The login is only audited as complete once no second factor is pending.
+	action := models.AuditAdminLogin
+	mfaRequired, err := s.CheckMfa(tx, admin)
+	switch {
+	case err != nil && !errors.Is(err, ErrMfaEnrollmentRequired):
+		s.releaseLoginAttempt(ctx, username)
+		return nil, err
+	case err != nil, mfaRequired:
+		s.releaseLoginAttempt(ctx, username)
+		action = models.AuditAdminLoginPendingMfa
+	default:
+		s.resetLoginFailures(ctx, username)
+	}
+
+	if err = s.auditor.Record(tx, audit.On(audit.ByAdmin(admin, action), audit.TargetAdmin, admin.ID)); err != nil {
+		return nil, err
+	}
+	if err = s.rehashPassword(tx, admin, plainPassword); err != nil {
+		return nil, err
+	}
+
 	log.Printf("Admin %s authenticated successfully", username)
 	return admin, nil
 }
 
+// rehashPassword replaces the stored hash of admin when it uses another algorithm or weaker
+// parameters than configured. It runs after a successful login, the only time the password is known.
+func (s *AdminServiceImpl) rehashPassword(tx *gorm.DB, admin *models.Admin, plainPassword string) error {
+	if !s.passwordHasher.NeedsRehash(admin.HashedPassword) {
+		return nil
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(plainPassword)
+	if err != nil {
+		return err
+	}
+	if err = s.adminRepo.UpdatePasswordHash(tx, admin.ID, hashedPassword); err != nil {
+		return err
+	}
+	admin.HashedPassword = hashedPassword
+
+	log.Printf("Rehashed password of admin %s", admin.Username)
+	return nil
+}
+
 func (s *AdminServiceImpl) SearchUsers(tx *gorm.DB, params models.UserSearchParams) ([]models.User, int64, error) {
 	users, total, err := s.userRepo.Search(tx, params)
 	if err != nil {
@@ -80,7 +192,7 @@ func (s *AdminServiceImpl) GetUserByID(tx *gorm.DB, userID uint) (*models.User,
 	return user, nil
 }
 
-func (s *AdminServiceImpl) UpdateUserStatus(tx *gorm.DB, userID uint, status models.UserStatus) (*models.User, error) {
+func (s *AdminServiceImpl) UpdateUserStatus(tx *gorm.DB, admin *models.Admin, userID uint, status models.UserStatus) (*models.User, error) {
 	user, err := s.userRepo.GetByID(tx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -89,9 +201,54 @@ func (s *AdminServiceImpl) UpdateUserStatus(tx *gorm.DB, userID uint, status mod
 		return nil, err
 	}
 
+	previousStatus := user.Status
 	user.Status = status
 	if err = s.userRepo.UpdateStatus(tx, user); err != nil {
 		return nil, err
 	}
+
+	if status == models.UserStatusDisabled && previousStatus != models.UserStatusDisabled {
+		if _, err = s.outboxRepo.Add(tx, models.EventUserDisabled, map[string]uint{"user_id": user.ID}); err != nil {
+			return nil, err
+		}
+	}
+
+	event := audit.On(audit.ByAdmin(admin, models.AuditUserStatusUpdated), audit.TargetUser, user.ID)
+	event.Changes = map[string]models.AuditChange{"status": {Before: previousStatus, After: status}}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
+
+// RotateSecretKey replaces the HS256 secret key. Tokens signed with the old key stay valid for
+// gracePeriod minutes, or the configured default when gracePeriod is nil. It returns the new kid and,
+// unless the grace period is zero, when the previous key stops being accepted.
+func (s *AdminServiceImpl) RotateSecretKey(tx *gorm.DB, admin *models.Admin, gracePeriod *uint) (string, *time.Time, error) {
+	grace := s.appSettings.SecretKeyGracePeriod()
+	if gracePeriod != nil {
+		grace = *gracePeriod
+	}
+
+	previousKid := s.appSettings.SecretKeyID()
+	settings, err := s.settingRepo.RotateSecretKey(tx, time.Duration(grace)*time.Minute)
+	if err != nil {
+		return "", nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(admin, models.AuditSecretKeyRotated), audit.TargetSettings, settings.ID)
+	event.Changes = map[string]models.AuditChange{
+		"secret_key_id": {Before: previousKid, After: settings.SecretKeyID},
+		"grace_period":  {After: grace},
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return "", nil, err
+	}
+	middleware.AfterCommit(tx, func() { s.appSettings.Apply(settings) })
+	log.Printf("Admin %s rotated the secret key: Kid=%s, GracePeriod=%dm", admin.Username, settings.SecretKeyID, grace)
+
+	if grace == 0 {
+		return settings.SecretKeyID, nil, nil
+	}
+	return settings.SecretKeyID, &settings.PreviousSecretKeys[0].ExpiresAt, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+)
+
+var ErrInvalidSettings = errors.New("invalid settings")
+
+func (s *AdminServiceImpl) GetSettings(tx *gorm.DB) (*models.Setting, error) {
+	return s.settingRepo.Get(tx)
+}
+
+// UpdateSettings applies a partial update to the settings. Once the transaction commits, they are
+// installed in this process and the new version is announced to the other instances.
+func (s *AdminServiceImpl) UpdateSettings(tx *gorm.DB, admin *models.Admin, update models.SettingUpdate) (*models.Setting, error) {
+	settings, err := s.settingRepo.GetForUpdate(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *settings
+	update.ApplyTo(settings)
+	if err = settings.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+
+	if err = s.settingRepo.Update(tx, settings); err != nil {
+		return nil, err
+	}
+
+	event := audit.On(audit.ByAdmin(admin, models.AuditSettingsUpdated), audit.TargetSettings, settings.ID)
+	event.Changes = audit.Diff(before, *settings, "SecretKey", "PreviousSecretKeys")
+	if err = s.auditor.Record(tx, event); err != nil {
+		return nil, err
+	}
+	middleware.AfterCommit(tx, func() { s.appSettings.Apply(settings) })
+	log.Printf("Admin %s updated the settings to version %d", admin.Username, settings.Version)
+
+	return settings, nil
+}
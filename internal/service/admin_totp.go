@@ -0,0 +1,302 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/audit"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/totp"
+)
+
+var (
+	ErrMfaEnrollmentRequired = errors.New("MFA is required: enroll an authenticator app before logging in")
+	ErrMfaEnforced           = errors.New("MFA is enforced for all admins and cannot be disabled")
+	ErrMfaChallengeInvalid   = errors.New("invalid or expired challenge token")
+)
+
+// MfaChallenge is a challenge or enrollment token issued to an admin after a successful password check.
+type MfaChallenge struct {
+	AdminID   uint
+	ID        string // jti
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// challengeAdmin returns the admin challenge was issued to. Challenges issued before the admin's
+// password was last reset are rejected, like admin tokens.
+func (s *AdminServiceImpl) challengeAdmin(tx *gorm.DB, challenge MfaChallenge) (*models.Admin, error) {
+	admin, err := s.adminRepo.GetByID(tx, challenge.AdminID)
+	switch {
+	case err != nil:
+		return nil, err
+	case admin == nil:
+		return nil, ErrMfaChallengeInvalid
+	case admin.PasswordResetAt.Valid && challenge.IssuedAt.Before(admin.PasswordResetAt.Time):
+		return nil, ErrMfaChallengeInvalid
+	}
+	return admin, nil
+}
+
+// CheckMfa reports whether admin must complete a TOTP challenge after the password check.
+// It returns ErrMfaEnrollmentRequired when MFA is enforced but admin has not enrolled yet.
+func (s *AdminServiceImpl) CheckMfa(tx *gorm.DB, admin *models.Admin) (bool, error) {
+	secret, err := s.adminTotpRepo.GetByAdminID(tx, admin.ID)
+	switch {
+	case err != nil:
+		return false, err
+	case secret != nil && secret.ConfirmedAt.Valid:
+		return true, nil
+	case s.appSettings.AdminMfaRequired():
+		return false, ErrMfaEnrollmentRequired
+	default:
+		return false, nil
+	}
+}
+
+// VerifyMfa completes an admin login with an authenticator app code. A challenge can be answered
+// only once, so a wrong code needs a new password check, and wrong codes count towards the lockout
+// of the admin's username like wrong passwords.
+func (s *AdminServiceImpl) VerifyMfa(tx *gorm.DB, challenge MfaChallenge, code string) (*models.Admin, error) {
+	ctx := tx.Statement.Context
+
+	admin, err := s.challengeAdmin(tx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.reserveLoginAttempt(ctx, admin.Username); err != nil {
+		return nil, err
+	}
+
+	firstUse, err := s.redisCli.UseMfaChallenge(ctx, challenge.ID, time.Until(challenge.ExpiresAt))
+	switch {
+	case err != nil:
+		s.releaseLoginAttempt(ctx, admin.Username)
+		return nil, err
+	case !firstUse:
+		log.Printf("Reused MFA challenge for admin %s", admin.Username)
+		s.releaseLoginAttempt(ctx, admin.Username)
+		return nil, ErrMfaChallengeInvalid
+	}
+
+	secret, err := s.confirmedTotpSecret(tx, admin.ID)
+	if err != nil {
+		s.releaseLoginAttempt(ctx, admin.Username)
+		return nil, err
+	}
+
+	if err = s.useTotpCode(tx, secret, code); err != nil {
+		log.Printf("Invalid MFA code for admin %s", admin.Username)
+		s.auditor.RecordDetached(ctx, audit.On(audit.ByAdmin(admin, models.AuditAdminMfaFailed), audit.TargetAdmin, admin.ID))
+		s.countLoginFailure(ctx, admin.Username, admin)
+		return nil, err
+	}
+	s.resetLoginFailures(ctx, admin.Username)
+
+	if err = s.recordMfaLogin(tx, admin); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s completed MFA successfully", admin.Username)
+	return admin, nil
+}
+
+// recordMfaLogin audits the second factor of admin and the login it completes.
+func (s *AdminServiceImpl) recordMfaLogin(tx *gorm.DB, admin *models.Admin) error {
+	if err := s.auditor.Record(tx, audit.On(audit.ByAdmin(admin, models.AuditAdminMfaVerified), audit.TargetAdmin, admin.ID)); err != nil {
+		return err
+	}
+	return s.auditor.Record(tx, audit.On(audit.ByAdmin(admin, models.AuditAdminLogin), audit.TargetAdmin, admin.ID))
+}
+
+// BeginMfaEnrollment starts TOTP enrollment for the admin an enrollment token was issued to.
+func (s *AdminServiceImpl) BeginMfaEnrollment(tx *gorm.DB, challenge MfaChallenge) (*totp.Enrollment, error) {
+	admin, err := s.challengeAdmin(tx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return s.BeginTotpEnrollment(tx, admin)
+}
+
+// CompleteMfaEnrollment confirms the TOTP enrollment of the admin an enrollment token was issued to.
+// The password was checked when the token was issued and the code proves the new second factor, so
+// the login is complete. Wrong codes count towards the lockout of the admin's username like in
+// VerifyMfa, and the token is used up once the enrollment is confirmed.
+func (s *AdminServiceImpl) CompleteMfaEnrollment(tx *gorm.DB, challenge MfaChallenge, code string) (*models.Admin, error) {
+	ctx := tx.Statement.Context
+
+	admin, err := s.challengeAdmin(tx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.reserveLoginAttempt(ctx, admin.Username); err != nil {
+		return nil, err
+	}
+
+	if err = s.ConfirmTotpEnrollment(tx, admin, code); err != nil {
+		if !errors.Is(err, totp.ErrInvalidCode) {
+			s.releaseLoginAttempt(ctx, admin.Username)
+			return nil, err
+		}
+		log.Printf("Invalid MFA enrollment code for admin %s", admin.Username)
+		s.auditor.RecordDetached(ctx, audit.On(audit.ByAdmin(admin, models.AuditAdminMfaFailed), audit.TargetAdmin, admin.ID))
+		s.countLoginFailure(ctx, admin.Username, admin)
+		return nil, err
+	}
+
+	firstUse, err := s.redisCli.UseMfaChallenge(ctx, challenge.ID, time.Until(challenge.ExpiresAt))
+	switch {
+	case err != nil:
+		s.releaseLoginAttempt(ctx, admin.Username)
+		return nil, err
+	case !firstUse:
+		log.Printf("Reused MFA enrollment token for admin %s", admin.Username)
+		s.releaseLoginAttempt(ctx, admin.Username)
+		return nil, ErrMfaChallengeInvalid
+	}
+	s.resetLoginFailures(ctx, admin.Username)
+	if err = s.recordMfaLogin(tx, admin); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %s enrolled TOTP and completed MFA", admin.Username)
+	return admin, nil
+}
+
+func (s *AdminServiceImpl) BeginTotpEnrollment(tx *gorm.DB, admin *models.Admin) (*totp.Enrollment, error) {
+	existing, err := s.adminTotpRepo.GetByAdminID(tx, admin.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ConfirmedAt.Valid {
+		return nil, ErrTotpAlreadyEnrolled
+	}
+
+	enrollment, err := s.totpManager.Enroll(admin.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.adminTotpRepo.Create(tx, admin.ID, enrollment.EncryptedSecret); err != nil {
+		return nil, err
+	}
+	log.Printf("TOTP enrollment started for admin %s", admin.Username)
+
+	return enrollment, nil
+}
+
+func (s *AdminServiceImpl) ConfirmTotpEnrollment(tx *gorm.DB, admin *models.Admin, code string) error {
+	secret, err := s.adminTotpRepo.GetByAdminID(tx, admin.ID)
+	switch {
+	case err != nil:
+		return err
+	case secret == nil:
+		return ErrTotpNotEnrolled
+	case secret.ConfirmedAt.Valid:
+		return ErrTotpAlreadyEnrolled
+	}
+
+	step, err := s.totpManager.Verify(secret.EncryptedSecret, code, secret.LastUsedStep)
+	if err != nil {
+		return err
+	}
+
+	if err = s.adminTotpRepo.Confirm(tx, secret, step); err != nil {
+		return err
+	}
+	if err = s.auditor.Record(tx, audit.On(audit.ByAdmin(admin, models.AuditAdminTotpEnrolled), audit.TargetAdmin, admin.ID)); err != nil {
+		return err
+	}
+	log.Printf("TOTP enrollment confirmed for admin %s", admin.Username)
+
+	return nil
+}
+
+func (s *AdminServiceImpl) DisableTotp(tx *gorm.DB, admin *models.Admin, code string) error {
+	if s.appSettings.AdminMfaRequired() {
+		return ErrMfaEnforced
+	}
+
+	secret, err := s.confirmedTotpSecret(tx, admin.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.useTotpCode(tx, secret, code); err != nil {
+		return err
+	}
+
+	if err = s.adminTotpRepo.DeleteByAdminID(tx, admin.ID); err != nil {
+		return err
+	}
+	if err = s.auditor.Record(tx, audit.On(audit.ByAdmin(admin, models.AuditAdminTotpDisabled), audit.TargetAdmin, admin.ID)); err != nil {
+		return err
+	}
+	log.Printf("TOTP disabled for admin %s", admin.Username)
+
+	return nil
+}
+
+// SetMfaRequired enforces or relaxes MFA for every admin. To avoid locking themselves out,
+// the admin enabling enforcement must already be enrolled.
+func (s *AdminServiceImpl) SetMfaRequired(tx *gorm.DB, admin *models.Admin, required bool) error {
+	if required {
+		if _, err := s.confirmedTotpSecret(tx, admin.ID); err != nil {
+			return err
+		}
+	}
+
+	settings, err := s.settingRepo.GetForUpdate(tx)
+	if err != nil {
+		return err
+	}
+
+	event := audit.On(audit.ByAdmin(admin, models.AuditMfaPolicyUpdated), audit.TargetSettings, settings.ID)
+	event.Changes = map[string]models.AuditChange{"admin_mfa_required": {Before: settings.AdminMfaRequired, After: required}}
+
+	settings.AdminMfaRequired = required
+	if err = s.settingRepo.Update(tx, settings); err != nil {
+		return err
+	}
+	if err = s.auditor.Record(tx, event); err != nil {
+		return err
+	}
+	middleware.AfterCommit(tx, func() { s.appSettings.Apply(settings) })
+	log.Printf("Admin %s set MFA required to %t", admin.Username, required)
+
+	return nil
+}
+
+func (s *AdminServiceImpl) confirmedTotpSecret(tx *gorm.DB, adminID uint) (*models.AdminTotpSecret, error) {
+	secret, err := s.adminTotpRepo.GetByAdminID(tx, adminID)
+	switch {
+	case err != nil:
+		return nil, err
+	case secret == nil || !secret.ConfirmedAt.Valid:
+		return nil, ErrTotpNotEnrolled
+	}
+	return secret, nil
+}
+
+// useTotpCode verifies code and consumes its time-step so it cannot be replayed.
+func (s *AdminServiceImpl) useTotpCode(tx *gorm.DB, secret *models.AdminTotpSecret, code string) error {
+	step, err := s.totpManager.Verify(secret.EncryptedSecret, code, secret.LastUsedStep)
+	if err != nil {
+		return err
+	}
+
+	used, err := s.adminTotpRepo.UseStep(tx, secret, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return totp.ErrInvalidCode
+	}
+	return nil
+}
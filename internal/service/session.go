@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/token"
+)
+
+// ListSessions returns the signed-in devices of a subject, most recently used first.
+func (s *TokenServiceImpl) ListSessions(tx *gorm.DB, subjectID uint, audience token.Audiance) ([]models.Session, error) {
+	return s.sessionRepo.ListActive(tx, subjectID, audience.Int())
+}
+
+// RevokeSession signs out one of the subject's devices.
+func (s *TokenServiceImpl) RevokeSession(ctx context.Context, tx *gorm.DB, subjectID uint, audience token.Audiance, sessionID uint) error {
+	session, err := s.sessionRepo.GetByIDForSubject(tx, sessionID, subjectID, audience.Int())
+	if err != nil {
+		return err
+	}
+	if session == nil || !session.IsActive() {
+		return ErrSessionNotFound
+	}
+	return s.revoke(ctx, tx, session)
+}
+
+// Logout revokes the session an access token was issued for.
+func (s *TokenServiceImpl) Logout(ctx context.Context, tx *gorm.DB, jti string) error {
+	session, err := s.sessionRepo.GetByJti(tx, jti)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt.Valid {
+		return nil
+	}
+	return s.revoke(ctx, tx, session)
+}
+
+// revokeFamily revokes the session owning a refresh token family, if it is still active.
+func (s *TokenServiceImpl) revokeFamily(ctx context.Context, tx *gorm.DB, familyID string) error {
+	session, err := s.sessionRepo.GetByJti(tx, familyID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.RevokedAt.Valid {
+		return s.refreshTokenRepo.RevokeFamily(tx, familyID)
+	}
+	return s.revoke(ctx, tx, session)
+}
+
+func (s *TokenServiceImpl) revoke(ctx context.Context, tx *gorm.DB, session *models.Session) error {
+	if err := s.sessionRepo.Revoke(tx, session); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(tx, session.Jti); err != nil {
+		return err
+	}
+
+	// Access tokens are rejected through the cache until they expire on their own.
+	ttl := time.Duration(s.appSettings.AccessTokenExpire()) * time.Minute
+	if err := s.redisCli.MarkSessionRevoked(ctx, session.Jti, ttl); err != nil {
+		log.Printf("Failed to cache revoked session: SessionID=%d, Error=%v", session.ID, err)
+	}
+	return nil
+}
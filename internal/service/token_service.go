@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+	"github.com/MoSed3/otp-server/internal/token"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, the login has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+const maxDeviceInfoLength = 255
+
+// TokenPair is an access token with the refresh token used to renew it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// TokenService defines the interface for issuing and rotating tokens.
+type TokenService interface {
+	IssueTokens(tx *gorm.DB, r *http.Request, subjectID uint, audience token.Audiance) (*TokenPair, error)
+	Refresh(ctx context.Context, r *http.Request, refreshToken string) (*TokenPair, error)
+	ListSessions(tx *gorm.DB, subjectID uint, audience token.Audiance) ([]models.Session, error)
+	RevokeSession(ctx context.Context, tx *gorm.DB, subjectID uint, audience token.Audiance, sessionID uint) error
+	Logout(ctx context.Context, tx *gorm.DB, jti string) error
+}
+
+// TokenServiceImpl implements TokenService.
+type TokenServiceImpl struct {
+	database         *db.DB
+	refreshTokenRepo repository.RefreshToken
+	sessionRepo      repository.Session
+	userRepo         repository.User
+	adminRepo        repository.Admin
+	redisCli         *redis.Config
+	jwtService       *token.JWTService
+	appSettings      *setting.Config
+}
+
+// NewTokenService creates a new instance of TokenServiceImpl.
+func NewTokenService(database *db.DB, refreshTokenRepo repository.RefreshToken, sessionRepo repository.Session, userRepo repository.User,
+	adminRepo repository.Admin, redisCli *redis.Config, jwtService *token.JWTService, appSettings *setting.Config) *TokenServiceImpl {
+	return &TokenServiceImpl{
+		database:         database,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		userRepo:         userRepo,
+		adminRepo:        adminRepo,
+		redisCli:         redisCli,
+		jwtService:       jwtService,
+		appSettings:      appSettings,
+	}
+}
+
+// IssueTokens opens a new session and starts its refresh token family for a fresh login.
+func (s *TokenServiceImpl) IssueTokens(tx *gorm.DB, r *http.Request, subjectID uint, audience token.Audiance) (*TokenPair, error) {
+	now := time.Now().UTC()
+	session := &models.Session{
+		Jti:        uuid.New().String(),
+		SubjectID:  subjectID,
+		Audience:   audience.Int(),
+		DeviceInfo: deviceInfo(r),
+		IPAddress:  middleware.GetClientIP(r),
+		LastSeenAt: now,
+		ExpiresAt:  s.refreshTokenExpiresAt(),
+	}
+	if err := s.sessionRepo.Create(tx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issue(tx, r, &models.RefreshToken{
+		FamilyID:        session.Jti,
+		SubjectID:       subjectID,
+		Audience:        audience.Int(),
+		AuthenticatedAt: now,
+	})
+}
+
+// Refresh rotates refreshToken. It runs in its own transaction so that revoking a family
+// on reuse is persisted even though the request itself fails.
+func (s *TokenServiceImpl) Refresh(ctx context.Context, r *http.Request, refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := s.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := s.refreshTokenRepo.GetByHashForUpdate(tx, hashRefreshToken(refreshToken))
+		switch {
+		case err != nil:
+			return err
+		case current == nil, current.RevokedAt.Valid, time.Now().UTC().After(current.ExpiresAt):
+			return ErrInvalidRefreshToken
+		case current.RotatedAt.Valid:
+			log.Printf("Refresh token reuse detected: FamilyID=%s, SubjectID=%d", current.FamilyID, current.SubjectID)
+			reused = true
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		session, err := s.sessionRepo.GetByJti(tx, current.FamilyID)
+		switch {
+		case err != nil:
+			return err
+		case session == nil, session.RevokedAt.Valid:
+			return ErrInvalidRefreshToken
+		}
+
+		if err = s.checkSubject(tx, current); err != nil {
+			return err
+		}
+
+		if err = s.refreshTokenRepo.MarkRotated(tx, current); err != nil {
+			return err
+		}
+
+		pair, err = s.issue(tx, r, &models.RefreshToken{
+			FamilyID:        current.FamilyID,
+			ParentID:        &current.ID,
+			SubjectID:       current.SubjectID,
+			Audience:        current.Audience,
+			AuthenticatedAt: current.AuthenticatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.sessionRepo.Touch(tx, session, middleware.GetClientIP(r), deviceInfo(r), s.refreshTokenExpiresAt())
+	})
+
+	switch {
+	case err != nil:
+		return nil, err
+	case reused:
+		return nil, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// checkSubject makes sure the token owner may still be issued tokens.
+func (s *TokenServiceImpl) checkSubject(tx *gorm.DB, rt *models.RefreshToken) error {
+	switch token.Audiance(rt.Audience) {
+	case token.AudianceUser:
+		user, err := s.userRepo.GetByID(tx, rt.SubjectID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if user.Status == models.UserStatusDisabled {
+			return ErrUserDisabled
+		}
+	case token.AudianceAdmin:
+		admin, err := s.adminRepo.GetByID(tx, rt.SubjectID)
+		if err != nil {
+			return err
+		}
+		if admin == nil || (admin.PasswordResetAt.Valid && rt.AuthenticatedAt.Before(admin.PasswordResetAt.Time)) {
+			return ErrInvalidRefreshToken
+		}
+	default:
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func (s *TokenServiceImpl) issue(tx *gorm.DB, r *http.Request, rt *models.RefreshToken) (*TokenPair, error) {
+	accessToken, err := s.jwtService.GenerateToken(rt.SubjectID, token.Audiance(rt.Audience), rt.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rt.TokenHash = hashRefreshToken(raw)
+	rt.DeviceInfo = deviceInfo(r)
+	rt.IPAddress = middleware.GetClientIP(r)
+	rt.ExpiresAt = s.refreshTokenExpiresAt()
+	if err = s.refreshTokenRepo.Create(tx, rt); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+	}, nil
+}
+
+func (s *TokenServiceImpl) refreshTokenExpiresAt() time.Time {
+	return time.Now().UTC().Add(time.Duration(s.appSettings.RefreshTokenExpire()) * time.Minute)
+}
+
+func deviceInfo(r *http.Request) string {
+	info := r.UserAgent()
+	if len(info) > maxDeviceInfoLength {
+		info = info[:maxDeviceInfoLength]
+	}
+	return info
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
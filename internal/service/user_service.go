@@ -2,16 +2,23 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"gorm.io/gorm"
 
+	"github.com/MoSed3/otp-server/internal/encryption"
 	"github.com/MoSed3/otp-server/internal/middleware"
 	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/otpcode"
 	"github.com/MoSed3/otp-server/internal/redis"
 	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+	"github.com/MoSed3/otp-server/internal/totp"
 )
 
 var (
@@ -24,27 +31,59 @@ type UserService interface {
 	VerifyOTP(ctx context.Context, r *http.Request, token, code string) (*models.User, error)
 	UpdateProfile(tx *gorm.DB, user *models.User, firstName, lastName string) error
 	GetUserByID(tx *gorm.DB, id uint) (*models.User, error)
+	BeginTotpEnrollment(tx *gorm.DB, user *models.User) (*totp.Enrollment, error)
+	ConfirmTotpEnrollment(tx *gorm.DB, user *models.User, code string) error
+	DisableTotp(tx *gorm.DB, user *models.User, code string) error
+	VerifyTOTP(ctx context.Context, r *http.Request, token, code string) (*models.User, error)
+	LoginTOTP(ctx context.Context, r *http.Request, phoneNumber, code string) (*models.User, error)
 }
 
 // UserServiceImpl implements UserService.
 type UserServiceImpl struct {
-	userRepo repository.User
-	otpRepo  repository.Otp
-	redisCli *redis.Config
+	userRepo     repository.User
+	otpRepo      repository.Otp
+	deliveryRepo repository.OtpDelivery
+	outboxRepo   repository.Outbox
+	userTotpRepo repository.UserTotp
+	redisCli     *redis.Config
+	otpHasher    *otpcode.Hasher
+	otpCipher    *encryption.Cipher
+	totpManager  *totp.Manager
+	appSettings  *setting.Config
 }
 
 // NewUserService creates a new instance of UserServiceImpl.
-func NewUserService(userRepo repository.User, otpRepo repository.Otp, redisCli *redis.Config) *UserServiceImpl {
+func NewUserService(userRepo repository.User, otpRepo repository.Otp, deliveryRepo repository.OtpDelivery, outboxRepo repository.Outbox,
+	userTotpRepo repository.UserTotp, redisCli *redis.Config, otpHasher *otpcode.Hasher, otpCipher *encryption.Cipher, totpManager *totp.Manager,
+	appSettings *setting.Config) *UserServiceImpl {
 	return &UserServiceImpl{
-		userRepo: userRepo,
-		otpRepo:  otpRepo,
-		redisCli: redisCli,
+		userRepo:     userRepo,
+		otpRepo:      otpRepo,
+		deliveryRepo: deliveryRepo,
+		outboxRepo:   outboxRepo,
+		userTotpRepo: userTotpRepo,
+		redisCli:     redisCli,
+		otpHasher:    otpHasher,
+		otpCipher:    otpCipher,
+		totpManager:  totpManager,
+		appSettings:  appSettings,
 	}
 }
 
-func (s *UserServiceImpl) Login(ctx context.Context, r *http.Request, phoneNumber string) (string, error) {
+func (s *UserServiceImpl) Login(ctx context.Context, r *http.Request, phoneNumber string) (token string, err error) {
 	log.Printf("Login attempt for phone number: %s", phoneNumber)
 
+	// Checked before the user exists, so pumped numbers never reach the database.
+	budget, err := s.reserveSms(ctx, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			s.releaseSms(ctx, budget)
+		}
+	}()
+
 	tx := middleware.GetTxFromRequest(r)
 
 	user, err := s.userRepo.GetOrCreateByPhoneNumber(tx, phoneNumber)
@@ -58,34 +97,58 @@ func (s *UserServiceImpl) Login(ctx context.Context, r *http.Request, phoneNumbe
 		return "", ErrUserDisabled
 	}
 
-	otp, err := s.otpRepo.Create(tx, user)
+	policy := s.appSettings.OtpPolicy()
+	code := otpcode.Generate(policy)
+	codeHash := s.otpHasher.Hash(code)
+
+	otp, err := s.otpRepo.Create(tx, user, codeHash, s.appSettings.OtpThrottlePolicy())
 	if err != nil {
 		log.Printf("Failed to create OTP for user %d: %v", user.ID, err)
 		return "", err
 	}
-	log.Printf("OTP created successfully: UserID=%d, OtpID=%d, Code=%s", user.ID, otp.ID, otp.Code)
+	log.Printf("OTP created successfully: UserID=%d, OtpID=%d", user.ID, otp.ID)
+
+	codeCiphertext, err := s.otpCipher.Encrypt(code)
+	if err != nil {
+		return "", err
+	}
+	metadata := map[string]string{
+		"user_id": strconv.FormatUint(uint64(user.ID), 10),
+		"otp_id":  strconv.FormatUint(uint64(otp.ID), 10),
+	}
+	// Queued in the request transaction, so nothing is sent unless the OTP is committed.
+	if _, err = s.deliveryRepo.Enqueue(tx, otp, phoneNumber, codeCiphertext, metadata); err != nil {
+		log.Printf("Failed to enqueue OTP delivery: UserID=%d, OtpID=%d, Error=%v", user.ID, otp.ID, err)
+		return "", err
+	}
+	log.Printf("OTP delivery enqueued: UserID=%d, OtpID=%d", user.ID, otp.ID)
+
+	if _, err = s.outboxRepo.Add(tx, models.EventOtpRequested, map[string]uint{"user_id": user.ID, "otp_id": otp.ID}); err != nil {
+		log.Printf("Failed to record OTP requested event: OtpID=%d, Error=%v", otp.ID, err)
+		return "", err
+	}
 
-	token, err := s.redisCli.CreateUserLoginSession(ctx, otp.ID, otp.Code)
+	token, err = s.redisCli.CreateUserLoginSession(ctx, otp.ID, codeHash, policy)
 	if err != nil {
 		log.Printf("Failed to create login session for OtpID %d: %v", otp.ID, err)
 		return "", err
 	}
-	log.Printf("Login session created successfully: UserID=%d, Token=%s", user.ID, token)
+	log.Printf("Login session created successfully: UserID=%d, OtpID=%d, Session=%s", user.ID, otp.ID, sessionLogID(token))
 
 	return token, nil
 }
 
 func (s *UserServiceImpl) VerifyOTP(ctx context.Context, r *http.Request, token, code string) (*models.User, error) {
-	log.Printf("OTP verification attempt: Token=%s", token)
+	log.Printf("OTP verification attempt: Session=%s", sessionLogID(token))
 
 	tx := middleware.GetTxFromRequest(r)
 
-	otpID, err := s.redisCli.CheckUserLoginCode(ctx, token, code)
+	otpID, err := s.redisCli.CheckUserLoginCode(ctx, token, s.otpHasher.Hash(code), s.appSettings.OtpPolicy())
 	if err != nil {
-		log.Printf("Invalid OTP verification attempt: Token=%s, Error=%v", token, err)
+		log.Printf("Invalid OTP verification attempt: Session=%s, Error=%v", sessionLogID(token), err)
 		return nil, err
 	}
-	log.Printf("OTP code verified successfully: Token=%s, OtpID=%d", token, otpID)
+	log.Printf("OTP code verified successfully: OtpID=%d", otpID)
 
 	otp, err := s.otpRepo.GetByID(tx, otpID)
 	if err != nil {
@@ -110,6 +173,11 @@ func (s *UserServiceImpl) VerifyOTP(ctx context.Context, r *http.Request, token,
 		return nil, ErrUserDisabled
 	}
 
+	if _, err = s.outboxRepo.Add(tx, models.EventOtpVerified, map[string]any{"user_id": user.ID, "otp_id": otp.ID, "factor": "sms"}); err != nil {
+		log.Printf("Failed to record OTP verified event: OtpID=%d, Error=%v", otp.ID, err)
+		return nil, err
+	}
+
 	log.Printf("OTP verification completed successfully: UserID=%d, Phone=%s", user.ID, user.PhoneNumber)
 
 	return user, nil
@@ -122,3 +190,10 @@ func (s *UserServiceImpl) UpdateProfile(tx *gorm.DB, user *models.User, firstNam
 func (s *UserServiceImpl) GetUserByID(tx *gorm.DB, id uint) (*models.User, error) {
 	return s.userRepo.GetByID(tx, id)
 }
+
+// sessionLogID identifies a login session in logs without revealing its bearer token, which
+// together with a code is enough to complete the login.
+func sessionLogID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/phone"
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+// SmsBlockedCode tells clients why no OTP text message was sent to a number.
+type SmsBlockedCode string
+
+const (
+	SmsCountryNotAllowed     SmsBlockedCode = "sms_country_not_allowed"
+	SmsCountryBudgetExceeded SmsBlockedCode = "sms_country_budget_exceeded"
+	SmsPrefixBudgetExceeded  SmsBlockedCode = "sms_prefix_budget_exceeded"
+	SmsSequentialNumbers     SmsBlockedCode = "sms_sequential_numbers"
+	SmsDailySpendCapReached  SmsBlockedCode = "sms_daily_spend_cap_reached"
+)
+
+// ErrSmsBlocked is returned when the SMS pumping and toll fraud defenses refuse to send an OTP to a number.
+type ErrSmsBlocked struct {
+	Code       SmsBlockedCode
+	Reason     string
+	RetryAfter time.Duration // Zero when retrying will not help
+}
+
+func (e *ErrSmsBlocked) Error() string {
+	if e.RetryAfter <= 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s, retry after %d seconds", e.Reason, int(e.RetryAfter.Seconds()))
+}
+
+var smsBudgetRejections = map[redis.SmsBudgetRejection]*ErrSmsBlocked{
+	redis.SmsCountryBudgetExhausted: {Code: SmsCountryBudgetExceeded, Reason: "too many verification codes sent to this country"},
+	redis.SmsPrefixBudgetExhausted:  {Code: SmsPrefixBudgetExceeded, Reason: "too many verification codes sent to numbers like this one"},
+	redis.SmsDailySpendCapReached:   {Code: SmsDailySpendCapReached, Reason: "verification codes are unavailable for the rest of the day"},
+	redis.SmsSequentialBurst:        {Code: SmsSequentialNumbers, Reason: "too many verification codes sent to consecutive numbers"},
+}
+
+// reserveSms checks phoneNumber against the SMS fraud policy and counts a message to it against the
+// SMS budgets. The budget must be released if the OTP is not sent after all.
+func (s *UserServiceImpl) reserveSms(ctx context.Context, phoneNumber string) (redis.SmsBudget, error) {
+	policy := s.appSettings.SmsFraudPolicy()
+	country := phone.CallingCode(phoneNumber)
+
+	if !policy.NumberAllowed(phoneNumber) {
+		log.Printf("OTP refused for phone %s: its calling code or prefix is not allowed", phoneNumber)
+		return redis.SmsBudget{}, &ErrSmsBlocked{Code: SmsCountryNotAllowed, Reason: "verification codes cannot be sent to this country"}
+	}
+
+	budget, err := s.redisCli.ReserveSmsBudget(ctx, phone.Digits(phoneNumber), country, policy)
+	if err != nil {
+		return redis.SmsBudget{}, err
+	}
+	if rejected, ok := smsBudgetRejections[budget.Rejection]; ok {
+		log.Printf("OTP refused for phone %s: %s", phoneNumber, rejected.Code)
+		return budget, &ErrSmsBlocked{Code: rejected.Code, Reason: rejected.Reason, RetryAfter: budget.RetryAfter}
+	}
+	return budget, nil
+}
+
+// releaseSms gives back a budget reserved for an OTP that was not sent.
+func (s *UserServiceImpl) releaseSms(ctx context.Context, budget redis.SmsBudget) {
+	if err := s.redisCli.ReleaseSmsBudget(context.WithoutCancel(ctx), budget); err != nil {
+		log.Printf("Failed to release SMS budget: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+
+	"github.com/MoSed3/otp-server/internal/middleware"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/redis"
+	"github.com/MoSed3/otp-server/internal/totp"
+)
+
+var (
+	ErrTotpNotEnrolled     = errors.New("TOTP is not enrolled")
+	ErrTotpAlreadyEnrolled = errors.New("TOTP is already enrolled")
+)
+
+func (s *UserServiceImpl) BeginTotpEnrollment(tx *gorm.DB, user *models.User) (*totp.Enrollment, error) {
+	existing, err := s.userTotpRepo.GetByUserID(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ConfirmedAt.Valid {
+		return nil, ErrTotpAlreadyEnrolled
+	}
+
+	enrollment, err := s.totpManager.Enroll(user.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.userTotpRepo.Create(tx, user.ID, enrollment.EncryptedSecret); err != nil {
+		return nil, err
+	}
+	log.Printf("TOTP enrollment started: UserID=%d", user.ID)
+
+	return enrollment, nil
+}
+
+func (s *UserServiceImpl) ConfirmTotpEnrollment(tx *gorm.DB, user *models.User, code string) error {
+	secret, err := s.userTotpRepo.GetByUserID(tx, user.ID)
+	switch {
+	case err != nil:
+		return err
+	case secret == nil:
+		return ErrTotpNotEnrolled
+	case secret.ConfirmedAt.Valid:
+		return ErrTotpAlreadyEnrolled
+	}
+
+	step, err := s.totpManager.Verify(secret.EncryptedSecret, code, secret.LastUsedStep)
+	if err != nil {
+		return err
+	}
+
+	if err = s.userTotpRepo.Confirm(tx, secret, step); err != nil {
+		return err
+	}
+	log.Printf("TOTP enrollment confirmed: UserID=%d", user.ID)
+
+	return nil
+}
+
+func (s *UserServiceImpl) DisableTotp(tx *gorm.DB, user *models.User, code string) error {
+	secret, err := s.confirmedTotpSecret(tx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.useTotpCode(tx, secret, code); err != nil {
+		return err
+	}
+
+	if err = s.userTotpRepo.DeleteByUserID(tx, user.ID); err != nil {
+		return err
+	}
+	log.Printf("TOTP disabled: UserID=%d", user.ID)
+
+	return nil
+}
+
+// VerifyTOTP completes a login session with an authenticator app code instead of the delivered OTP.
+func (s *UserServiceImpl) VerifyTOTP(ctx context.Context, r *http.Request, token, code string) (*models.User, error) {
+	log.Printf("TOTP verification attempt: Session=%s", sessionLogID(token))
+
+	tx := middleware.GetTxFromRequest(r)
+	policy := s.appSettings.OtpPolicy()
+
+	session, err := s.redisCli.IncreaseUserLoginTries(ctx, token, policy)
+	if err != nil {
+		log.Printf("Invalid TOTP verification attempt: Session=%s, Error=%v", sessionLogID(token), err)
+		return nil, err
+	}
+
+	user, err := s.otpRepo.GetUserByOtpID(tx, session.OtpID)
+	if err != nil {
+		log.Printf("Failed to retrieve user by OTP: OtpID=%d, Error=%v", session.OtpID, err)
+		return nil, err
+	}
+
+	secret, err := s.confirmedTotpSecret(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.useTotpCode(tx, secret, code); err != nil {
+		log.Printf("Invalid TOTP code: OtpID=%d, UserID=%d", session.OtpID, user.ID)
+		return nil, err
+	}
+
+	otp, err := s.otpRepo.GetByID(tx, session.OtpID)
+	if err != nil {
+		return nil, err
+	}
+	if err = otp.Waste(tx); err != nil {
+		log.Printf("Failed to mark OTP as used: OtpID=%d, Error=%v", otp.ID, err)
+		return nil, err
+	}
+
+	if user.Status == models.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	if _, err = s.outboxRepo.Add(tx, models.EventOtpVerified, map[string]any{"user_id": user.ID, "otp_id": otp.ID, "factor": "totp"}); err != nil {
+		log.Printf("Failed to record OTP verified event: OtpID=%d, Error=%v", otp.ID, err)
+		return nil, err
+	}
+	session.State = redis.LoginStateSuccess
+	if err = s.redisCli.SetUserLoginSession(ctx, token, session, policy.SessionTTL); err != nil {
+		return nil, err
+	}
+
+	log.Printf("TOTP verification completed successfully: UserID=%d", user.ID)
+	return user, nil
+}
+
+// LoginTOTP logs a user enrolled in TOTP in with an authenticator app code alone, without a login
+// session from Login, so no OTP is issued or sent. Unknown numbers, users without TOTP and wrong
+// codes all fail with totp.ErrInvalidCode, so the response does not reveal who is enrolled.
+func (s *UserServiceImpl) LoginTOTP(ctx context.Context, r *http.Request, phoneNumber, code string) (*models.User, error) {
+	log.Printf("TOTP login attempt for phone number: %s", phoneNumber)
+
+	tx := middleware.GetTxFromRequest(r)
+
+	user, err := s.userRepo.GetByPhoneNumber(tx, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, totp.ErrInvalidCode
+	}
+
+	secret, err := s.confirmedTotpSecret(tx, user.ID)
+	switch {
+	case errors.Is(err, ErrTotpNotEnrolled):
+		return nil, totp.ErrInvalidCode
+	case err != nil:
+		return nil, err
+	}
+
+	if err = s.useTotpCode(tx, secret, code); err != nil {
+		log.Printf("Invalid TOTP login code: UserID=%d", user.ID)
+		return nil, err
+	}
+
+	if user.Status == models.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	if _, err = s.outboxRepo.Add(tx, models.EventOtpVerified, map[string]any{"user_id": user.ID, "factor": "totp"}); err != nil {
+		log.Printf("Failed to record OTP verified event: UserID=%d, Error=%v", user.ID, err)
+		return nil, err
+	}
+	log.Printf("TOTP login completed successfully: UserID=%d", user.ID)
+	return user, nil
+}
+
+func (s *UserServiceImpl) confirmedTotpSecret(tx *gorm.DB, userID uint) (*models.UserTotpSecret, error) {
+	secret, err := s.userTotpRepo.GetByUserID(tx, userID)
+	switch {
+	case err != nil:
+		return nil, err
+	case secret == nil || !secret.ConfirmedAt.Valid:
+		return nil, ErrTotpNotEnrolled
+	}
+	return secret, nil
+}
+
+// useTotpCode verifies code and consumes its time-step so it cannot be replayed.
+func (s *UserServiceImpl) useTotpCode(tx *gorm.DB, secret *models.UserTotpSecret, code string) error {
+	step, err := s.totpManager.Verify(secret.EncryptedSecret, code, secret.LastUsedStep)
+	if err != nil {
+		return err
+	}
+
+	used, err := s.userTotpRepo.UseStep(tx, secret, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return totp.ErrInvalidCode
+	}
+	return nil
+}
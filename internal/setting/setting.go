@@ -11,11 +11,27 @@ import (
 	"github.com/MoSed3/otp-server/internal/repository"
 )
 
+// missReloadDelay throttles reloads triggered by tokens carrying an unknown secret kid.
+const missReloadDelay = 5 * time.Second
+
 // Config holds the application settings.
 type Config struct {
-	mutex             sync.RWMutex
-	secretKey         []byte
-	accessTokenExpire uint
+	database             *db.DB
+	publish              func(version uint64)
+	mutex                sync.RWMutex
+	lastReload           time.Time
+	version              uint64
+	secretKey            []byte
+	secretKeyID          string
+	previousSecretKeys   []models.PreviousSecretKey
+	secretKeyGracePeriod uint
+	accessTokenExpire    uint
+	refreshTokenExpire   uint
+	otpPolicy            models.OtpPolicy
+	otpThrottlePolicy    models.OtpThrottlePolicy
+	adminMfaRequired     bool
+	adminLockoutPolicy   models.AdminLockoutPolicy
+	smsFraudPolicy       models.SmsFraudPolicy
 }
 
 // New creates and initializes a new settings configuration.
@@ -23,29 +39,78 @@ func New() *Config {
 	return &Config{}
 }
 
-// Update updates the settings with new values.
+// Update updates the settings with new values. Settings older than the installed ones are ignored,
+// so a reload that read the row before a newer version was applied cannot put the old values back.
 func (c *Config) Update(s *models.Setting) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.lastReload = time.Now()
+	if s.Version < c.version {
+		return
+	}
+	c.version = s.Version
 	c.accessTokenExpire = s.AccessTokenExpire
+	c.refreshTokenExpire = s.RefreshTokenExpire
 	c.secretKey = []byte(s.SecretKey)
+	c.secretKeyID = s.SecretKeyID
+	c.previousSecretKeys = s.PreviousSecretKeys
+	c.secretKeyGracePeriod = s.SecretKeyGracePeriod
+	c.otpPolicy = s.OtpPolicy()
+	c.otpThrottlePolicy = s.OtpThrottlePolicy()
+	c.adminMfaRequired = s.AdminMfaRequired
+	c.adminLockoutPolicy = s.AdminLockoutPolicy()
+	c.smsFraudPolicy = s.SmsFraudPolicy()
+}
+
+// Apply installs settings changed by this process and announces the new version to the other instances.
+// It must only be called once the change has committed.
+func (c *Config) Apply(s *models.Setting) {
+	c.Update(s)
+	if c.publish != nil {
+		c.publish(s.Version)
+	}
+}
+
+func (c *Config) setPublisher(publish func(version uint64)) {
+	c.publish = publish
 }
 
 // Init initializes the settings by loading them from the database.
 func (c *Config) Init(database *db.DB) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	c.database = database
+	if err := c.Reload(context.Background()); err != nil {
+		log.Fatalf("Error while getting settings from db: %v", err)
+	}
+}
+
+// Reload reads the settings from the database again, picking up changes made by other processes.
+func (c *Config) Reload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	tx := database.GetTransaction(ctx)
 	settingRepo := repository.NewSetting()
-
-	s, err := settingRepo.Get(tx)
+	s, err := settingRepo.Get(c.database.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Error while getting settings from db: %v", err)
+		return err
 	}
 
 	c.Update(s)
+	return nil
+}
+
+// Version returns the version of the loaded settings.
+func (c *Config) Version() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.version
+}
+
+// LoadedAt returns when the settings were last loaded.
+func (c *Config) LoadedAt() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.lastReload
 }
 
 // SecretKey returns the application's secret key.
@@ -55,9 +120,98 @@ func (c *Config) SecretKey() []byte {
 	return c.secretKey
 }
 
+// SecretKeyID returns the kid of the primary secret key.
+func (c *Config) SecretKeyID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.secretKeyID
+}
+
+// SecretKeyGracePeriod returns how long, in minutes, a rotated-out secret key keeps verifying tokens by default.
+func (c *Config) SecretKeyGracePeriod() uint {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.secretKeyGracePeriod
+}
+
+// VerificationKey returns the secret identified by kid: the primary key or a previous key still in its
+// grace period. An unknown kid triggers a throttled reload in case another process rotated the key.
+func (c *Config) VerificationKey(kid string) []byte {
+	key, stale := c.verificationKey(kid)
+	if key != nil || !stale || c.database == nil {
+		return key
+	}
+
+	if err := c.Reload(context.Background()); err != nil {
+		log.Printf("Failed to reload settings: %v", err)
+		return nil
+	}
+	key, _ = c.verificationKey(kid)
+	return key
+}
+
+func (c *Config) verificationKey(kid string) ([]byte, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	stale := time.Since(c.lastReload) > missReloadDelay
+	if kid == c.secretKeyID {
+		return c.secretKey, stale
+	}
+	now := time.Now().UTC()
+	for _, p := range c.previousSecretKeys {
+		if p.Kid == kid && now.Before(p.ExpiresAt) {
+			return []byte(p.Key), stale
+		}
+	}
+	return nil, stale
+}
+
 // AccessTokenExpire returns the access token expiration time in minutes.
 func (c *Config) AccessTokenExpire() uint {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return c.accessTokenExpire
 }
+
+// RefreshTokenExpire returns the refresh token expiration time in minutes.
+func (c *Config) RefreshTokenExpire() uint {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.refreshTokenExpire
+}
+
+// OtpPolicy returns the policy used to generate and verify OTP codes.
+func (c *Config) OtpPolicy() models.OtpPolicy {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.otpPolicy
+}
+
+// OtpThrottlePolicy returns the policy limiting how often OTPs are issued to a user.
+func (c *Config) OtpThrottlePolicy() models.OtpThrottlePolicy {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.otpThrottlePolicy
+}
+
+// AdminMfaRequired reports whether every admin must complete a TOTP second factor to log in.
+func (c *Config) AdminMfaRequired() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.adminMfaRequired
+}
+
+// AdminLockoutPolicy returns the policy that delays and locks out repeated failed admin logins.
+func (c *Config) AdminLockoutPolicy() models.AdminLockoutPolicy {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.adminLockoutPolicy
+}
+
+// SmsFraudPolicy returns the policy limiting where and how many OTP text messages are sent.
+func (c *Config) SmsFraudPolicy() models.SmsFraudPolicy {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.smsFraudPolicy
+}
@@ -0,0 +1,119 @@
+package setting
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/redis"
+)
+
+// Channel is the Redis channel settings versions are announced on.
+const Channel = "settings:changed"
+
+const (
+	// pollInterval is the fallback reload period, covering announcements missed while disconnected.
+	pollInterval = 30 * time.Second
+	// reloadRetries and reloadRetryDelay give the announcing transaction time to commit.
+	reloadRetries    = 5
+	reloadRetryDelay = 200 * time.Millisecond
+)
+
+// Watcher keeps a Config in sync with the settings row across server instances. Changes applied
+// through Config.Apply are announced on Channel and every other instance reloads from the database.
+type Watcher struct {
+	config   *Config
+	redisCli *redis.Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a new Watcher and makes config announce its changes through it.
+func NewWatcher(config *Config, redisCli *redis.Config) *Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Watcher{
+		config:   config,
+		redisCli: redisCli,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	config.setPublisher(func(version uint64) {
+		if err := Publish(w.ctx, redisCli, version); err != nil {
+			log.Printf("Failed to announce settings version %d: %v", version, err)
+		}
+	})
+	return w
+}
+
+// Publish announces that the settings were changed to version.
+func Publish(ctx context.Context, redisCli *redis.Config, version uint64) error {
+	return redisCli.Publish(ctx, Channel, strconv.FormatUint(version, 10))
+}
+
+// Start listens for announcements and periodically reloads the settings.
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go w.run()
+	log.Println("Settings watcher started")
+}
+
+// Stop ends the watcher.
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.wg.Wait()
+	log.Println("Settings watcher stopped")
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	announcements := w.redisCli.Subscribe(w.ctx, Channel)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case payload, ok := <-announcements:
+			if !ok {
+				return
+			}
+			version, err := strconv.ParseUint(payload, 10, 64)
+			if err != nil {
+				log.Printf("Ignoring invalid settings announcement %q", payload)
+				continue
+			}
+			w.reloadUntil(version)
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// reloadUntil reloads the settings until at least version is loaded.
+func (w *Watcher) reloadUntil(version uint64) {
+	for i := 0; i < reloadRetries && w.config.Version() < version; i++ {
+		if i > 0 {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(reloadRetryDelay):
+			}
+		}
+		w.reload()
+	}
+	if w.config.Version() >= version {
+		log.Printf("Settings reloaded at version %d", w.config.Version())
+	}
+}
+
+func (w *Watcher) reload() {
+	if err := w.config.Reload(w.ctx); err != nil {
+		log.Printf("Failed to reload settings: %v", err)
+	}
+}
@@ -8,44 +8,93 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	"github.com/MoSed3/otp-server/internal/setting"
 )
 
 // JWTService provides methods for JWT token generation and parsing.
+// Tokens are signed with the active asymmetric key of keySet, or with the HS256 secret from settings
+// when no signing key has been created. Once a signing key is active, HS256 tokens are only accepted
+// for the secret key grace period.
 type JWTService struct {
 	appSettings *setting.Config
+	keySet      *KeySet
 }
 
 // NewJWTService creates a new JWTService instance.
-func NewJWTService(appSettings *setting.Config) *JWTService {
+func NewJWTService(appSettings *setting.Config, keySet *KeySet) *JWTService {
 	return &JWTService{
 		appSettings: appSettings,
+		keySet:      keySet,
 	}
 }
 
+// validMethods are the algorithms accepted when parsing a token.
+var validMethods = []string{jwt.SigningMethodHS256.Alg(), AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}
+
 type Claims struct {
 	jwt.RegisteredClaims
 	ID       uint     `json:"id"`
 	Audience Audiance `json:"aud"`
 }
 
-func (s *JWTService) GenerateToken(id uint, audiance Audiance) (string, error) {
+// SessionID returns the jti claim, which identifies the server-side session the token belongs to.
+func (c *Claims) SessionID() string {
+	return c.RegisteredClaims.ID
+}
+
+const (
+	// MfaChallengeExpire is how long an admin has to complete the second factor after a successful password check.
+	MfaChallengeExpire = 5 * time.Minute
+	// MfaEnrollmentExpire is how long an admin who must use MFA but has not enrolled has to enroll after a
+	// successful password check.
+	MfaEnrollmentExpire = 15 * time.Minute
+)
+
+func (s *JWTService) GenerateToken(id uint, audiance Audiance, sessionID string) (string, error) {
 	expireDuration := s.appSettings.AccessTokenExpire()
+	return s.generateToken(id, audiance, sessionID, time.Duration(expireDuration)*time.Minute)
+}
+
+// GenerateMfaChallengeToken issues a short-lived token that can only be exchanged for an admin token at
+// /auth/admin/mfa. Its jti lets the exchange use it only once.
+func (s *JWTService) GenerateMfaChallengeToken(adminID uint) (string, error) {
+	return s.generateToken(adminID, AudianceAdminMfa, uuid.NewString(), MfaChallengeExpire)
+}
+
+// GenerateMfaEnrollmentToken issues a short-lived token that only lets an admin enroll an authenticator
+// app at /auth/admin/mfa/enroll, and exchange the confirmed enrollment for an admin token.
+func (s *JWTService) GenerateMfaEnrollmentToken(adminID uint) (string, error) {
+	return s.generateToken(adminID, AudianceAdminMfaEnroll, uuid.NewString(), MfaEnrollmentExpire)
+}
+
+func (s *JWTService) generateToken(id uint, audiance Audiance, sessionID string, expire time.Duration) (string, error) {
 	now := time.Now().UTC()
 
 	claims := &Claims{
 		ID: id,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(expireDuration) * time.Minute)),
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(expire)),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
 		Audience: audiance,
 	}
 
-	// Sign the token with the retrieved key
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.appSettings.SecretKey())
+	var tokenString string
+	var err error
+	if key := s.keySet.active(); key != nil {
+		token := jwt.NewWithClaims(key.method, claims)
+		token.Header["kid"] = key.kid
+		tokenString, err = token.SignedString(key.private)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		if kid := s.appSettings.SecretKeyID(); kid != "" {
+			token.Header["kid"] = kid
+		}
+		tokenString, err = token.SignedString(s.appSettings.SecretKey())
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to sign JWT token: %v", err)
 	}
@@ -68,13 +117,12 @@ func (s *JWTService) ParseTokenFromHeader(r *http.Request, headerName string) (*
 		return nil, errors.New("invalid authorization header format: missing Bearer prefix")
 	}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	tokenBytes := s.appSettings.SecretKey()
+	return s.ParseTokenString(strings.TrimPrefix(authHeader, "Bearer "))
+}
 
+func (s *JWTService) ParseTokenString(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return tokenBytes, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey, jwt.WithValidMethods(validMethods))
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
@@ -83,3 +131,43 @@ func (s *JWTService) ParseTokenFromHeader(r *http.Request, headerName string) (*
 	}
 	return claims, nil
 }
+
+// verificationKey picks the key a token is verified with from its alg and kid headers.
+func (s *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if !s.acceptsHMAC() {
+			return nil, errors.New("HS256 tokens are no longer accepted")
+		}
+		secret := s.appSettings.VerificationKey(kid)
+		if secret == nil {
+			return nil, fmt.Errorf("unknown or expired secret key %q", kid)
+		}
+		return secret, nil
+	}
+
+	key := s.keySet.lookup(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if key.method.Alg() != token.Method.Alg() {
+		return nil, errors.New("signing algorithm does not match key")
+	}
+	return key.public, nil
+}
+
+// acceptsHMAC reports whether HS256 tokens still verify: while no signing key is active, and for the
+// secret key grace period after the first signing key replaced the HS256 secret.
+func (s *JWTService) acceptsHMAC() bool {
+	since := s.keySet.activeSince()
+	if since.IsZero() {
+		return true
+	}
+	grace := time.Duration(s.appSettings.SecretKeyGracePeriod()) * time.Minute
+	return time.Now().Before(since.Add(grace))
+}
+
+// JWKS returns the public signing keys for /.well-known/jwks.json.
+func (s *JWTService) JWKS() JWKSet {
+	return s.keySet.JWKS()
+}
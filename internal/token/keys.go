@@ -0,0 +1,387 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/encryption"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+)
+
+// Supported asymmetric signing algorithms.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+
+	keySetReloadInterval = time.Minute
+	// keySetMissReloadDelay throttles reloads triggered by tokens carrying an unknown kid.
+	keySetMissReloadDelay = 5 * time.Second
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+// signingKey is a parsed models.SigningKey.
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	algorithm string
+}
+
+// KeySet caches the usable signing keys and keeps them in sync with the database,
+// so keys rotated by another process are picked up without a restart.
+// Private keys are stored encrypted with cipher.
+type KeySet struct {
+	database       *db.DB
+	signingKeyRepo repository.SigningKey
+	cipher         *encryption.Cipher
+
+	mutex      sync.RWMutex
+	current    *signingKey
+	keys       map[string]*signingKey
+	since      time.Time // Creation of the first signing key, zero when none is active
+	lastReload time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewKeySet creates a new KeySet.
+func NewKeySet(database *db.DB, signingKeyRepo repository.SigningKey, cipher *encryption.Cipher) *KeySet {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KeySet{
+		database:       database,
+		signingKeyRepo: signingKeyRepo,
+		cipher:         cipher,
+		keys:           map[string]*signingKey{},
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Init encrypts the private keys stored before they were encrypted at rest, then loads the keys from the database.
+func (k *KeySet) Init() {
+	if err := k.encryptPlaintextKeys(context.Background()); err != nil {
+		log.Fatalf("Error while encrypting signing keys: %v", err)
+	}
+	if err := k.Reload(context.Background()); err != nil {
+		log.Fatalf("Error while loading signing keys from db: %v", err)
+	}
+}
+
+// Start periodically reloads the keys.
+func (k *KeySet) Start() {
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		ticker := time.NewTicker(keySetReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := k.Reload(k.ctx); err != nil {
+					log.Printf("Failed to reload signing keys: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic reload.
+func (k *KeySet) Stop() {
+	k.cancel()
+	k.wg.Wait()
+}
+
+// Reload replaces the cached keys with the active and retiring keys from the database.
+func (k *KeySet) Reload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	rows, err := k.signingKeyRepo.ListAll(k.database.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	var active *signingKey
+	var since time.Time
+	keys := make(map[string]*signingKey, len(rows))
+	for i := range rows {
+		// Retired keys count too, so retiring the first key does not start the HS256 grace period again
+		if since.IsZero() || rows[i].CreatedAt.Before(since) {
+			since = rows[i].CreatedAt
+		}
+		if rows[i].Status == models.SigningKeyRetired {
+			continue
+		}
+
+		key, err := k.parseSigningKey(&rows[i])
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", rows[i].Kid, err)
+		}
+		keys[key.kid] = key
+		if rows[i].Status == models.SigningKeyActive {
+			active = key
+		}
+	}
+	if active == nil {
+		since = time.Time{}
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.current = active
+	k.keys = keys
+	k.since = since
+	k.lastReload = time.Now()
+	return nil
+}
+
+// active returns the key new tokens are signed with, or nil if none is configured.
+func (k *KeySet) active() *signingKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.current
+}
+
+// activeSince returns when the first signing key replaced the HS256 secret, or the zero time while
+// tokens are still signed with it.
+func (k *KeySet) activeSince() time.Time {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.since
+}
+
+// encryptPlaintextKeys encrypts the private keys still stored as PEM. Only rows that are unchanged
+// are updated, so instances starting together do not encrypt a key twice.
+func (k *KeySet) encryptPlaintextKeys(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx := k.database.WithContext(ctx)
+	rows, err := k.signingKeyRepo.ListAll(tx)
+	if err != nil {
+		return err
+	}
+
+	for i := range rows {
+		if !isPlaintextPEM(rows[i].PrivateKey) {
+			continue
+		}
+		encrypted, err := k.cipher.Encrypt(rows[i].PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err = k.signingKeyRepo.ReplacePrivateKey(tx, &rows[i], encrypted); err != nil {
+			return fmt.Errorf("signing key %s: %w", rows[i].Kid, err)
+		}
+		log.Printf("Encrypted private key of signing key %s", rows[i].Kid)
+	}
+	return nil
+}
+
+func isPlaintextPEM(privateKey string) bool {
+	return strings.HasPrefix(privateKey, "-----BEGIN")
+}
+
+// lookup returns the key identified by kid. An unknown kid triggers a throttled reload
+// in case the key was rotated in after the last periodic reload.
+func (k *KeySet) lookup(kid string) *signingKey {
+	k.mutex.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.lastReload) > keySetMissReloadDelay
+	k.mutex.RUnlock()
+	if ok || !stale {
+		return key
+	}
+
+	if err := k.Reload(k.ctx); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return nil
+	}
+
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.keys[kid]
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public part of every usable key.
+func (k *KeySet) JWKS() JWKSet {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	return set
+}
+
+func (s *signingKey) jwk() JWK {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: s.kid, Use: "sig", Alg: s.algorithm}
+
+	switch pub := s.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(pub.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// Uncompressed point: 0x04 || X || Y
+		point, _ := pub.ECDH()
+		raw := point.Bytes()[1:]
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = encode(raw[:len(raw)/2])
+		jwk.Y = encode(raw[len(raw)/2:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encode(pub)
+	}
+	return jwk
+}
+
+// GenerateSigningKey creates a new key pair for algorithm, ready to be stored as the active key.
+// The private key is encrypted with cipher.
+func GenerateSigningKey(algorithm string, cipher *encryption.Cipher) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	privatePEM, err := cipher.Encrypt(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	if err != nil {
+		return nil, err
+	}
+
+	kid := make([]byte, 12)
+	if _, err = rand.Read(kid); err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		Kid:        base64.RawURLEncoding.EncodeToString(kid),
+		Algorithm:  algorithm,
+		PrivateKey: privatePEM,
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		Status:     models.SigningKeyActive,
+	}, nil
+}
+
+func (k *KeySet) parseSigningKey(m *models.SigningKey) (*signingKey, error) {
+	method := jwt.GetSigningMethod(m.Algorithm)
+	if method == nil {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	privatePEM, err := k.cipher.Decrypt(m.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if m.Algorithm != AlgorithmRS256 {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		private = key
+	case *ecdsa.PrivateKey:
+		if m.Algorithm != AlgorithmES256 || key.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		private = key
+	case ed25519.PrivateKey:
+		if m.Algorithm != AlgorithmEdDSA {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		private = key
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &signingKey{
+		kid:       m.Kid,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		algorithm: m.Algorithm,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	gormtests "gorm.io/gorm/utils/tests"
+
+	"github.com/MoSed3/otp-server/internal/db"
+	"github.com/MoSed3/otp-server/internal/encryption"
+	"github.com/MoSed3/otp-server/internal/models"
+	"github.com/MoSed3/otp-server/internal/repository"
+	"github.com/MoSed3/otp-server/internal/setting"
+)
+
+// fakeSigningKeyRepo serves ListAll from memory; the other methods are not used by KeySet.Reload.
+type fakeSigningKeyRepo struct {
+	repository.SigningKey
+	keys []models.SigningKey
+}
+
+func (r *fakeSigningKeyRepo) ListAll(*gorm.DB) ([]models.SigningKey, error) {
+	return r.keys, nil
+}
+
+func TestAcceptsHMAC(t *testing.T) {
+	cipher, err := encryption.NewCipher("test-key")
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	gormDB, err := gorm.Open(gormtests.DummyDialector{}, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+
+	newKey := func(status models.SigningKeyStatus, age time.Duration) models.SigningKey {
+		key, err := GenerateSigningKey(AlgorithmEdDSA, cipher)
+		if err != nil {
+			t.Fatalf("GenerateSigningKey: %v", err)
+		}
+		key.Status = status
+		key.CreatedAt = time.Now().Add(-age)
+		return *key
+	}
+
+	tests := []struct {
+		name string
+		keys []models.SigningKey
+		want bool
+	}{
+		{
+			name: "no signing key",
+			want: true,
+		},
+		{
+			name: "first key within the grace period",
+			keys: []models.SigningKey{newKey(models.SigningKeyActive, time.Minute)},
+			want: true,
+		},
+		{
+			name: "first key past the grace period",
+			keys: []models.SigningKey{newKey(models.SigningKeyActive, 2*time.Hour)},
+			want: false,
+		},
+		{
+			name: "rotated key keeps the first key's cutover",
+			keys: []models.SigningKey{newKey(models.SigningKeyRetiring, 2*time.Hour), newKey(models.SigningKeyActive, time.Minute)},
+			want: false,
+		},
+		{
+			name: "retiring the first key does not restart the grace period",
+			keys: []models.SigningKey{newKey(models.SigningKeyRetired, 2*time.Hour), newKey(models.SigningKeyActive, time.Minute)},
+			want: false,
+		},
+		{
+			name: "every key retired signs with HS256 again",
+			keys: []models.SigningKey{newKey(models.SigningKeyRetired, 2*time.Hour)},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keySet := NewKeySet(&db.DB{DB: gormDB}, &fakeSigningKeyRepo{keys: tt.keys}, cipher)
+			if err := keySet.Reload(context.Background()); err != nil {
+				t.Fatalf("Reload: %v", err)
+			}
+
+			appSettings := setting.New()
+			appSettings.Update(&models.Setting{SecretKeyGracePeriod: 60})
+			jwtService := NewJWTService(appSettings, keySet)
+
+			if got := jwtService.acceptsHMAC(); got != tt.want {
+				t.Errorf("acceptsHMAC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
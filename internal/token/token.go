@@ -7,6 +7,8 @@ type Audiance int
 const (
 	AudianceAdmin Audiance = iota + 1
 	AudianceUser
+	AudianceAdminMfa
+	AudianceAdminMfaEnroll
 )
 
 func (a Audiance) String() string {
@@ -15,6 +17,10 @@ func (a Audiance) String() string {
 		return "admin"
 	case AudianceUser:
 		return "user"
+	case AudianceAdminMfa:
+		return "admin_mfa"
+	case AudianceAdminMfaEnroll:
+		return "admin_mfa_enroll"
 	default:
 		return ""
 	}
@@ -30,6 +36,10 @@ func ParseAudiance(aud string) (Audiance, error) {
 		return AudianceAdmin, nil
 	case "user":
 		return AudianceUser, nil
+	case "admin_mfa":
+		return AudianceAdminMfa, nil
+	case "admin_mfa_enroll":
+		return AudianceAdminMfaEnroll, nil
 	default:
 		return -1, errors.New("invalid audiance")
 	}
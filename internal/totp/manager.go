@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MoSed3/otp-server/internal/encryption"
+)
+
+// skew is the number of time-steps of clock drift accepted in each direction.
+const skew = 1
+
+var ErrInvalidCode = errors.New("invalid TOTP code")
+
+// Enrollment holds a freshly generated secret. Only EncryptedSecret should be persisted.
+type Enrollment struct {
+	Secret          string
+	URI             string
+	EncryptedSecret string
+}
+
+// Manager generates and verifies TOTP secrets that are stored encrypted.
+type Manager struct {
+	cipher *encryption.Cipher
+	issuer string
+}
+
+// NewManager creates a new Manager.
+func NewManager(cipher *encryption.Cipher, issuer string) *Manager {
+	return &Manager{cipher: cipher, issuer: issuer}
+}
+
+// Enroll generates a new secret for account.
+func (m *Manager) Enroll(account string) (*Enrollment, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := m.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret:          secret,
+		URI:             URI(m.issuer, account, secret),
+		EncryptedSecret: encrypted,
+	}, nil
+}
+
+// Verify checks code against encryptedSecret and returns the matched time-step.
+// Steps at or before lastUsedStep are rejected.
+func (m *Manager) Verify(encryptedSecret, code string, lastUsedStep int64) (int64, error) {
+	secret, err := m.cipher.Decrypt(encryptedSecret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok := Validate(secret, code, time.Now().UTC(), skew, lastUsedStep)
+	if !ok {
+		return 0, ErrInvalidCode
+	}
+	return step, nil
+}
@@ -0,0 +1,19 @@
+CREATE TABLE otp_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    otp_id BIGINT NOT NULL,
+    phone_number VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    metadata TEXT,
+    status INT NOT NULL DEFAULT 1,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT fk_otp_deliveries_otp FOREIGN KEY (otp_id) REFERENCES user_otps(id)
+);
+
+CREATE UNIQUE INDEX idx_otp_deliveries_otp_id ON otp_deliveries (otp_id);
+CREATE INDEX idx_otp_deliveries_due ON otp_deliveries (status, next_attempt_at);
@@ -0,0 +1,14 @@
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    payload TEXT NOT NULL,
+    delivered_to TEXT,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_events_type ON outbox_events (type);
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at);
@@ -0,0 +1 @@
+ALTER TABLE user_otps RENAME COLUMN code_hash TO code;
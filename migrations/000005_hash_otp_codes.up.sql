@@ -0,0 +1,7 @@
+-- Existing codes were stored in plaintext: expire them and drop their values.
+UPDATE user_otps SET used_at = NOW() WHERE used_at IS NULL;
+UPDATE user_otps SET code = '';
+ALTER TABLE user_otps RENAME COLUMN code TO code_hash;
+
+UPDATE otp_deliveries SET status = 3, last_error = 'invalidated by code hashing migration' WHERE status = 1;
+UPDATE otp_deliveries SET message = '';
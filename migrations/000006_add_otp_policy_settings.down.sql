@@ -0,0 +1,4 @@
+ALTER TABLE settings DROP COLUMN otp_session_ttl;
+ALTER TABLE settings DROP COLUMN otp_max_attempts;
+ALTER TABLE settings DROP COLUMN otp_numeric_only;
+ALTER TABLE settings DROP COLUMN otp_length;
@@ -0,0 +1,4 @@
+ALTER TABLE settings ADD COLUMN otp_length BIGINT NOT NULL DEFAULT 6;
+ALTER TABLE settings ADD COLUMN otp_numeric_only BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE settings ADD COLUMN otp_max_attempts BIGINT NOT NULL DEFAULT 3;
+ALTER TABLE settings ADD COLUMN otp_session_ttl BIGINT NOT NULL DEFAULT 180;
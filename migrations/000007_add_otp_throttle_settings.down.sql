@@ -0,0 +1,2 @@
+ALTER TABLE settings DROP COLUMN otp_throttle_tiers;
+ALTER TABLE settings DROP COLUMN otp_cooldown;
@@ -0,0 +1,4 @@
+ALTER TABLE settings ADD COLUMN otp_cooldown BIGINT NOT NULL DEFAULT 120;
+ALTER TABLE settings ADD COLUMN otp_throttle_tiers TEXT;
+
+UPDATE settings SET otp_throttle_tiers = '[{"window":600,"limit":3},{"window":86400,"limit":10}]';
@@ -0,0 +1,3 @@
+ALTER TABLE settings DROP COLUMN admin_mfa_required;
+
+DROP TABLE IF EXISTS admin_totp_secrets;
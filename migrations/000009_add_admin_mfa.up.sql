@@ -0,0 +1,15 @@
+CREATE TABLE admin_totp_secrets (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    admin_id BIGINT NOT NULL,
+    encrypted_secret TEXT NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT fk_admin_totp_secrets_admin FOREIGN KEY (admin_id) REFERENCES admins(id)
+);
+
+CREATE UNIQUE INDEX idx_admin_totp_secrets_admin_id ON admin_totp_secrets (admin_id);
+
+ALTER TABLE settings ADD COLUMN admin_mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE settings DROP COLUMN refresh_token_expire;
+
+DROP TABLE IF EXISTS refresh_tokens;
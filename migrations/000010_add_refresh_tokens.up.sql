@@ -0,0 +1,23 @@
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    token_hash TEXT NOT NULL,
+    family_id TEXT NOT NULL,
+    parent_id BIGINT,
+    subject_id BIGINT NOT NULL,
+    audience BIGINT NOT NULL,
+    authenticated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    device_info TEXT,
+    ip_address TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_parent_id ON refresh_tokens (parent_id);
+
+ALTER TABLE settings ADD COLUMN refresh_token_expire BIGINT NOT NULL DEFAULT 43200;
@@ -0,0 +1,20 @@
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    jti TEXT NOT NULL,
+    subject_id BIGINT NOT NULL,
+    audience BIGINT NOT NULL,
+    device_info TEXT,
+    ip_address TEXT,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_sessions_jti ON sessions (jti);
+CREATE INDEX idx_sessions_subject ON sessions (subject_id, audience);
+
+-- Refresh token families issued before sessions existed have no session to belong to.
+UPDATE refresh_tokens SET revoked_at = NOW() WHERE revoked_at IS NULL;
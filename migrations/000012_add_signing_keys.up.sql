@@ -0,0 +1,17 @@
+CREATE TABLE signing_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    kid TEXT NOT NULL,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    status BIGINT NOT NULL DEFAULT 1,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_signing_keys_kid ON signing_keys (kid);
+CREATE INDEX idx_signing_keys_status ON signing_keys (status);
+-- At most one key signs new tokens.
+CREATE UNIQUE INDEX idx_signing_keys_single_active ON signing_keys (status) WHERE status = 1;
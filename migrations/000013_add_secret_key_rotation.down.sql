@@ -0,0 +1,3 @@
+ALTER TABLE settings DROP COLUMN secret_key_grace_period;
+ALTER TABLE settings DROP COLUMN previous_secret_keys;
+ALTER TABLE settings DROP COLUMN secret_key_id;
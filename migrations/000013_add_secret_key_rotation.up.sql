@@ -0,0 +1,3 @@
+ALTER TABLE settings ADD COLUMN secret_key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE settings ADD COLUMN previous_secret_keys TEXT;
+ALTER TABLE settings ADD COLUMN secret_key_grace_period BIGINT NOT NULL DEFAULT 1440;
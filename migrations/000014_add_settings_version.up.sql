@@ -0,0 +1 @@
+ALTER TABLE settings ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
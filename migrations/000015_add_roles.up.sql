@@ -0,0 +1,13 @@
+CREATE TABLE roles (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    name TEXT NOT NULL,
+    description TEXT,
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    permissions TEXT
+);
+
+CREATE UNIQUE INDEX idx_roles_name ON roles (name);
+
+-- The built-in roles are created by the server on startup.
@@ -0,0 +1,4 @@
+ALTER TABLE settings DROP COLUMN admin_login_max_delay;
+ALTER TABLE settings DROP COLUMN admin_login_free_tries;
+ALTER TABLE settings DROP COLUMN admin_lockout_duration;
+ALTER TABLE settings DROP COLUMN admin_lockout_attempts;
@@ -0,0 +1,4 @@
+ALTER TABLE settings ADD COLUMN admin_lockout_attempts BIGINT NOT NULL DEFAULT 10;
+ALTER TABLE settings ADD COLUMN admin_lockout_duration BIGINT NOT NULL DEFAULT 900;
+ALTER TABLE settings ADD COLUMN admin_login_free_tries BIGINT NOT NULL DEFAULT 3;
+ALTER TABLE settings ADD COLUMN admin_login_max_delay BIGINT NOT NULL DEFAULT 30;
@@ -0,0 +1,9 @@
+CREATE TABLE admin_password_histories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    admin_id BIGINT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    CONSTRAINT fk_admin_password_histories_admin FOREIGN KEY (admin_id) REFERENCES admins(id)
+);
+
+CREATE INDEX idx_admin_password_histories_admin_id ON admin_password_histories (admin_id);
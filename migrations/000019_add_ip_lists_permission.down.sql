@@ -0,0 +1,7 @@
+UPDATE roles
+SET permissions = REPLACE(REPLACE(permissions, ',"ip_lists:manage"', ''), '"ip_lists:manage",', '')
+WHERE permissions LIKE '%"ip_lists:manage"%';
+
+UPDATE roles
+SET permissions = REPLACE(permissions, '"ip_lists:manage"', '')
+WHERE permissions LIKE '%"ip_lists:manage"%';
@@ -0,0 +1,3 @@
+UPDATE roles
+SET permissions = '["users:read","users:write","settings:read","settings:write","admins:manage","audit:read","ip_lists:manage"]'
+WHERE id = 1 AND builtin;
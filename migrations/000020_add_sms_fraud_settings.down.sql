@@ -0,0 +1,10 @@
+ALTER TABLE settings DROP COLUMN sms_daily_spend_cap;
+ALTER TABLE settings DROP COLUMN sms_country_costs;
+ALTER TABLE settings DROP COLUMN sms_cost;
+ALTER TABLE settings DROP COLUMN sms_sequential_window;
+ALTER TABLE settings DROP COLUMN sms_sequential_limit;
+ALTER TABLE settings DROP COLUMN sms_prefix_length;
+ALTER TABLE settings DROP COLUMN sms_prefix_hourly_limit;
+ALTER TABLE settings DROP COLUMN sms_country_hourly_limit;
+ALTER TABLE settings DROP COLUMN sms_denied_countries;
+ALTER TABLE settings DROP COLUMN sms_allowed_countries;
@@ -0,0 +1,10 @@
+ALTER TABLE settings ADD COLUMN sms_allowed_countries TEXT;
+ALTER TABLE settings ADD COLUMN sms_denied_countries TEXT;
+ALTER TABLE settings ADD COLUMN sms_country_hourly_limit BIGINT NOT NULL DEFAULT 200;
+ALTER TABLE settings ADD COLUMN sms_prefix_hourly_limit BIGINT NOT NULL DEFAULT 20;
+ALTER TABLE settings ADD COLUMN sms_prefix_length BIGINT NOT NULL DEFAULT 7;
+ALTER TABLE settings ADD COLUMN sms_sequential_limit BIGINT NOT NULL DEFAULT 5;
+ALTER TABLE settings ADD COLUMN sms_sequential_window BIGINT NOT NULL DEFAULT 600;
+ALTER TABLE settings ADD COLUMN sms_cost BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE settings ADD COLUMN sms_country_costs TEXT;
+ALTER TABLE settings ADD COLUMN sms_daily_spend_cap BIGINT NOT NULL DEFAULT 10000;
@@ -0,0 +1,3 @@
+ALTER TABLE otp_deliveries ADD COLUMN message TEXT NOT NULL DEFAULT '';
+UPDATE otp_deliveries SET status = 3, last_error = 'invalidated by code encryption rollback' WHERE status = 1;
+ALTER TABLE otp_deliveries DROP COLUMN code_ciphertext;
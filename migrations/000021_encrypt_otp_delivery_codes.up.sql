@@ -0,0 +1,4 @@
+-- Queued messages carried the plaintext code: expire the pending ones and drop the column.
+ALTER TABLE otp_deliveries ADD COLUMN code_ciphertext TEXT NOT NULL DEFAULT '';
+UPDATE otp_deliveries SET status = 3, last_error = 'invalidated by code encryption migration' WHERE status = 1;
+ALTER TABLE otp_deliveries DROP COLUMN message;